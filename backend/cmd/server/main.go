@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	adaptercache "invoice-scan/backend/internal/adapters/cache"
+	"invoice-scan/backend/internal/adapters/repo"
+	adapterstorage "invoice-scan/backend/internal/adapters/storage"
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/internal/handlers"
+	"invoice-scan/backend/internal/middleware"
+	"invoice-scan/backend/internal/worker"
+	"invoice-scan/backend/pkg/config"
+	pkgextraction "invoice-scan/backend/pkg/extraction"
+	"invoice-scan/backend/pkg/imageproc"
+	"invoice-scan/backend/pkg/s3"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dsn := getDSN()
+	gormDB, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	invoiceRepo := repo.NewInvoiceGormRepo(gormDB)
+	jobRepo := repo.NewJobGormRepo(gormDB)
+	txManager := repo.NewGormTransactionManager(gormDB)
+
+	blobRepo := repo.NewBlobGormRepo(gormDB)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		migrateStorage(invoiceRepo, blobRepo, os.Args[2:])
+		return
+	}
+
+	fileStorage, err := getFileStorage()
+	if err != nil {
+		log.Fatalf("Failed to create file storage: %v", err)
+	}
+	// thumbnails are found by the path of their image, so they bypass
+	// deduplication; ones deleted with an image still shared are made again
+	// on request
+	thumbnails, err := adapterstorage.NewThumbnailStorage(fileStorage, config.GetIntSliceWithDefaultValue("storage.thumbnails.sizes", []int{256}))
+	if err != nil {
+		log.Fatalf("Failed to create thumbnail storage: %v", err)
+	}
+
+	uploadPath := config.GetStringWithDefaultValue("storage.upload_path", "./uploads")
+
+	router := gin.Default()
+
+	corsOrigins := config.GetStringSlice("cors.origin")
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = corsOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "HEAD"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Cache-Control", "X-File-Name", "X-Tenant-ID"}
+	corsConfig.ExposeHeaders = []string{"Content-Length", "Content-Type"}
+	corsConfig.AllowCredentials = false
+	corsConfig.MaxAge = 12 * time.Hour
+	router.Use(cors.New(corsConfig))
+	router.Use(gin.Recovery())
+
+	// uploads are named after their invoice and never rewritten
+	router.Group("/uploads", middleware.Immutable()).Static("/", uploadPath)
+	router.StaticFile("/ssl/rootCA.pem", "./ssl/rootCA.pem")
+
+	prompts, err := pkgextraction.NewPromptStore(
+		config.GetString("extraction.prompts_dir"),
+		config.GetString("extraction.prompt_version"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to load extraction prompts: %v", err)
+	}
+
+	extractionProvider := config.GetStringWithDefaultValue("extraction.provider", pkgextraction.ProviderGemini)
+	extractionConfig := getExtractionConfig(extractionProvider)
+	extractionConfig.Prompts = prompts
+	extractionService, err := pkgextraction.New(extractionProvider, extractionConfig)
+	if err != nil {
+		log.Fatalf("Failed to create extraction service: %v", err)
+	}
+	if recordDir := config.GetString("extraction.record_dir"); recordDir != "" {
+		extractionService, err = pkgextraction.NewRecordingExtraction(extractionService, recordDir)
+		if err != nil {
+			log.Fatalf("Failed to record extractions: %v", err)
+		}
+	}
+
+	resultCache, err := getResultCache(gormDB)
+	if err != nil {
+		log.Fatalf("Failed to create extraction cache: %v", err)
+	}
+	if resultCache != nil {
+		extractionService = pkgextraction.NewCachedExtraction(extractionService, resultCache, pkgextraction.CacheConfig{
+			Provider: extractionProvider,
+			Model:    extractionConfig.Model,
+			Prompts:  prompts,
+			TTL:      config.GetDurationWithDefaultValue("extraction.cache.ttl", 24*time.Hour),
+		})
+	}
+	extractionService = pkgextraction.NewTaxCodeExtraction(extractionService)
+	defer func() {
+		if err := extractionService.Close(); err != nil {
+			log.Printf("Error closing extraction service: %v", err)
+		}
+	}()
+
+	retryPolicy := job.RetryPolicy{
+		MaxAttempts: config.GetIntWithDefaultValue("worker.retry.max_attempts", 5),
+		BaseDelay:   config.GetDurationWithDefaultValue("worker.retry.base_delay", 10*time.Second),
+		MaxDelay:    config.GetDurationWithDefaultValue("worker.retry.max_delay", 10*time.Minute),
+		Jitter:      config.GetFloat64WithDefaultValue("worker.retry.jitter", 0.2),
+	}
+
+	// recover invoices a previous run left behind before workers start claiming
+	reaper := worker.NewReaper(invoiceRepo, jobRepo, worker.ReaperConfig{
+		Interval:    config.GetDurationWithDefaultValue("worker.recovery.interval", time.Minute),
+		StaleAfter:  config.GetDurationWithDefaultValue("worker.recovery.stale_after", 15*time.Minute),
+		BatchSize:   config.GetIntWithDefaultValue("worker.recovery.batch_size", 100),
+		MaxAttempts: retryPolicy.MaxAttempts,
+	})
+	if config.GetBoolWithDefaultValue("worker.recovery.enabled", true) {
+		if _, err := reaper.Sweep(context.Background(), time.Now()); err != nil {
+			log.Printf("Startup recovery sweep failed: %v", err)
+		}
+		reaper.Start(context.Background())
+	}
+
+	workerPool := worker.NewPool(jobRepo, worker.Config{
+		Concurrency:   config.GetIntWithDefaultValue("worker.concurrency", 4),
+		PollInterval:  config.GetDurationWithDefaultValue("worker.poll_interval", time.Second),
+		LeaseDuration: config.GetDurationWithDefaultValue("worker.lease_duration", 30*time.Second),
+		Retry:         retryPolicy,
+	})
+	usageRepo := repo.NewExtractionAttemptGormRepo(gormDB)
+	pricing := getPricing()
+
+	workerPool.Register(invoice.JobTypeExtraction, worker.NewExtractionHandler(invoiceRepo, txManager, fileStorage, extractionService, usageRepo, pricing))
+	workerPool.Start(context.Background())
+
+	preprocessor, err := getPreprocessor()
+	if err != nil {
+		log.Fatalf("Failed to create image preprocessor: %v", err)
+	}
+
+	extractHandler := handlers.NewExtractHandler(extractionService, preprocessor, usageRepo, pricing)
+	usageHandler := handlers.NewUsageHandler(usageRepo, invoiceRepo)
+	// with storage.dedup, identical files are stored once whichever invoices
+	// upload them
+	var invoiceFiles domainstorage.FileStorage = fileStorage
+	if config.GetBool("storage.dedup") {
+		invoiceFiles = adapterstorage.NewDedupStorage(fileStorage, blobRepo)
+	}
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceRepo, invoiceFiles, jobRepo, txManager, preprocessor, thumbnails)
+	jobHandler := handlers.NewJobHandler(jobRepo, invoiceRepo, txManager)
+
+	v1 := router.Group("/api/v1")
+	{
+		v1.GET("/health", healthHandler)
+		v1.GET("/extraction/limits", limitsHandler)
+		v1.POST("/extract", extractHandler.Extract)
+		v1.POST("/invoices/upload", invoiceHandler.Upload)
+		v1.GET("/invoices", invoiceHandler.List)
+		v1.GET("/invoices/:id", invoiceHandler.GetByID)
+		v1.GET("/invoices/:id/thumbnail", invoiceHandler.Thumbnail)
+		v1.PUT("/invoices/:id", invoiceHandler.Update)
+		v1.DELETE("/invoices/:id", invoiceHandler.Delete)
+		v1.POST("/invoices/:id/pages", invoiceHandler.AddPages)
+		v1.POST("/invoices/:id/extract", invoiceHandler.Reprocess)
+		v1.GET("/invoices/:id/revisions", invoiceHandler.ListRevisions)
+		v1.GET("/invoices/:id/attempts", usageHandler.ListAttempts)
+		v1.GET("/usage", usageHandler.Summarize)
+		v1.GET("/jobs/dead-letters", jobHandler.ListDeadLetters)
+		v1.POST("/jobs/:id/requeue", jobHandler.Requeue)
+		if resultCache != nil {
+			v1.DELETE("/extraction-cache/:image_hash", handlers.NewCacheHandler(resultCache).InvalidateImage)
+		}
+	}
+
+	host := config.GetStringWithDefaultValue("server.host", "localhost")
+	port := config.GetStringWithDefaultValue("server.port", "3001")
+	sslEnabled := config.GetBoolWithDefaultValue("server.ssl.enabled", false)
+	sslCertFile := config.GetStringWithDefaultValue("server.ssl.cert_file", "./ssl/cert.pem")
+	sslKeyFile := config.GetStringWithDefaultValue("server.ssl.key_file", "./ssl/key.pem")
+
+	addr := fmt.Sprintf("%s:%s", host, port)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: router,
+	}
+
+	go func() {
+		if sslEnabled {
+			log.Printf("Server starting on https://%s", addr)
+			if err := srv.ListenAndServeTLS(sslCertFile, sslKeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to start HTTPS server: %v", err)
+			}
+		} else {
+			log.Printf("Server starting on http://%s", addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatalf("Server forced to shutdown: %v", err)
+	}
+
+	reaper.Stop()
+	workerPool.Stop()
+
+	log.Println("Server exited")
+}
+
+func getDSN() string {
+	dbUser := config.GetStringWithDefaultValue("database.user", "root")
+	dbPassword := config.GetStringWithDefaultValue("database.password", "")
+	dbHost := config.GetStringWithDefaultValue("database.host", "localhost")
+	dbPort := config.GetStringWithDefaultValue("database.port", "3306")
+	dbName := config.GetStringWithDefaultValue("database.name", "invoice_scan")
+
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+}
+
+// getFileStorage creates the file storage selected by storage.backend: local
+// (default), which serves files from storage.upload_path, or s3
+func getFileStorage() (domainstorage.FileStorage, error) {
+	switch backend := config.GetStringWithDefaultValue("storage.backend", "local"); backend {
+	case "local", "":
+		return getLocalStorage()
+	case "s3":
+		return getS3Storage()
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// getLocalStorage creates the local file storage. Without storage.base_url
+// its URLs are relative to the site.
+func getLocalStorage() (*adapterstorage.LocalStorage, error) {
+	return adapterstorage.NewLocalStorage(
+		config.GetStringWithDefaultValue("storage.upload_path", "./uploads"),
+		config.GetString("storage.base_url"),
+	)
+}
+
+func getS3Storage() (*adapterstorage.S3Storage, error) {
+	return adapterstorage.NewS3Storage(adapterstorage.S3StorageConfig{
+		S3: s3.Config{
+			Endpoint:  config.GetString("storage.s3.endpoint"),
+			Region:    config.GetString("storage.s3.region"),
+			Bucket:    config.GetString("storage.s3.bucket"),
+			AccessKey: config.GetString("storage.s3.access_key"),
+			SecretKey: config.GetString("storage.s3.secret_key"),
+			PathStyle: config.GetBoolWithDefaultValue("storage.s3.path_style", true),
+		},
+		PublicEndpoint: config.GetString("storage.s3.public_endpoint"),
+		Prefix:         config.GetStringWithDefaultValue("storage.s3.prefix", "uploads/"),
+		URLExpiry:      config.GetDurationWithDefaultValue("storage.s3.url_expiry", adapterstorage.DefaultS3URLExpiry),
+		PartSize:       config.GetIntWithDefaultValue("storage.s3.part_size_mb", adapterstorage.DefaultS3PartSize>>20) << 20,
+	})
+}
+
+// getResultCache creates the extraction result cache selected by
+// extraction.cache.backend: memory (default), db or none
+func getResultCache(db *gorm.DB) (invoice.ResultCache, error) {
+	switch backend := config.GetStringWithDefaultValue("extraction.cache.backend", "memory"); backend {
+	case "memory":
+		return adaptercache.NewMemoryResultCache(config.GetIntWithDefaultValue("extraction.cache.size", 1000)), nil
+	case "db":
+		return repo.NewExtractionCacheGormRepo(db), nil
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", backend)
+	}
+}
+
+// getPreprocessor creates the pipeline uploaded photos go through before they
+// are stored and extracted. No steps disables preprocessing.
+func getPreprocessor() (invoice.ImagePreprocessor, error) {
+	steps := config.GetStringSliceWithDefaultValue("extraction.preprocess.steps", []string{imageproc.StepOrient, imageproc.StepDownscale})
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	return imageproc.NewPipeline(imageproc.Config{
+		Steps:        steps,
+		MaxDimension: config.GetIntWithDefaultValue("extraction.preprocess.max_dimension", imageproc.DefaultMaxDimension),
+		Quality:      config.GetIntWithDefaultValue("extraction.preprocess.jpeg_quality", imageproc.DefaultQuality),
+		MaxSkew:      config.GetFloat64WithDefaultValue("extraction.preprocess.max_skew", imageproc.DefaultMaxSkew),
+	})
+}
+
+// getPricing returns the built-in model prices overridden by the ones listed
+// in extraction.pricing
+func getPricing() invoice.Pricing {
+	var prices []struct {
+		Model            string  `json:"model" mapstructure:"model"`
+		InputPerMillion  float64 `json:"input_per_million" mapstructure:"input_per_million"`
+		OutputPerMillion float64 `json:"output_per_million" mapstructure:"output_per_million"`
+	}
+	config.UnmarshalKey("extraction.pricing", &prices)
+
+	pricing := make(invoice.Pricing, len(pkgextraction.DefaultPricing)+len(prices))
+	for model, price := range pkgextraction.DefaultPricing {
+		pricing[model] = price
+	}
+	for _, price := range prices {
+		pricing[price.Model] = invoice.ModelPrice{
+			InputPerMillion:  price.InputPerMillion,
+			OutputPerMillion: price.OutputPerMillion,
+		}
+	}
+	return pricing
+}
+
+// getExtractionConfig reads the config of the provider selected by
+// extraction.provider. extraction.model overrides the model of that provider.
+func getExtractionConfig(provider string) pkgextraction.ProviderConfig {
+	cfg := getProviderConfig(provider)
+	if model := config.GetString("extraction.model"); model != "" {
+		cfg.Model = model
+	}
+	return cfg
+}
+
+// getProviderConfig reads the settings of an extraction provider from the
+// section named after it, e.g. gemini.api_key or tesseract.language. The
+// composite provider lists the providers it combines in composite.providers.
+func getProviderConfig(provider string) pkgextraction.ProviderConfig {
+	cfg := pkgextraction.ProviderConfig{
+		Name:           provider,
+		Model:          config.GetString(provider + ".model"),
+		APIKey:         config.GetString(provider + ".api_key"),
+		BaseURL:        config.GetString(provider + ".base_url"),
+		Timeout:        config.GetDurationWithDefaultValue(provider+".timeout", 90*time.Second),
+		MaxTokens:      config.GetInt(provider + ".max_tokens"),
+		JSONMode:       config.GetBool(provider + ".json_mode"),
+		Command:        config.GetString(provider + ".command"),
+		Language:       config.GetString(provider + ".language"),
+		FixturesDir:    config.GetString(provider + ".fixtures_dir"),
+		DefaultFixture: config.GetString(provider + ".default_fixture"),
+		Limits: pkgextraction.LimitConfig{
+			RequestsPerMinute: config.GetInt(provider + ".rate_limit.requests_per_minute"),
+			TokensPerMinute:   config.GetInt(provider + ".rate_limit.tokens_per_minute"),
+			EstimatedTokens:   config.GetInt(provider + ".rate_limit.estimated_tokens"),
+			MaxConcurrency:    config.GetInt(provider + ".rate_limit.max_concurrency"),
+		},
+		// PDF documents are rasterized the same way for every provider
+		PDF: pkgextraction.PDFConfig{
+			Command:  config.GetString("extraction.pdf.command"),
+			DPI:      config.GetInt("extraction.pdf.dpi"),
+			MaxPages: config.GetInt("extraction.pdf.max_pages"),
+		},
+	}
+
+	if provider == pkgextraction.ProviderReplay && cfg.FixturesDir == "" {
+		cfg.FixturesDir = "./fixtures/extractions"
+	}
+
+	if provider == pkgextraction.ProviderComposite {
+		cfg.Mode = config.GetString(provider + ".mode")
+		for _, child := range config.GetStringSlice(provider + ".providers") {
+			if child == pkgextraction.ProviderComposite {
+				// rejected by the composite provider, do not recurse
+				cfg.Providers = append(cfg.Providers, pkgextraction.ProviderConfig{Name: child})
+				continue
+			}
+			cfg.Providers = append(cfg.Providers, getProviderConfig(child))
+		}
+	}
+
+	return cfg
+}
+
+func healthHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// limitsHandler reports the rate limiter state of every limited provider
+func limitsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"limiters":  pkgextraction.LimiterStates(),
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
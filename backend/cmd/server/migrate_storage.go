@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"invoice-scan/backend/internal/adapters/repo"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/config"
+)
+
+// migrateStorage copies the files of the local upload directory into the S3
+// bucket, thumbnails included, and points the invoices and pages at their
+// objects. The local files are left in place until the move is checked.
+// Files already in the bucket are copied again, so an interrupted run can be
+// repeated.
+//
+//	server migrate-storage [-dry-run]
+func migrateStorage(invoiceRepo *repo.InvoiceGormRepo, blobRepo *repo.BlobGormRepo, args []string) {
+	flags := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the files to copy without copying them or changing the database")
+	flags.Parse(args)
+
+	if backend := config.GetString("storage.backend"); backend != "s3" {
+		log.Fatalf("storage.backend must be s3 to migrate the uploads, got %q", backend)
+	}
+	target, err := getS3Storage()
+	if err != nil {
+		log.Fatalf("Failed to create file storage: %v", err)
+	}
+
+	uploadPath := config.GetStringWithDefaultValue("storage.upload_path", "./uploads")
+	entries, err := os.ReadDir(uploadPath)
+	if err != nil {
+		log.Fatalf("Failed to read upload directory: %v", err)
+	}
+
+	ctx := context.Background()
+	keys := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		data, err := os.ReadFile(filepath.Join(uploadPath, name))
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		if !domainstorage.IsSupportedContentType(contentType) {
+			log.Printf("Skipping %s: unsupported content type %s", name, contentType)
+			continue
+		}
+
+		if *dryRun {
+			log.Printf("Would copy %s (%d bytes)", name, len(data))
+			keys[name] = name
+			continue
+		}
+		key, err := target.Save(ctx, name, data, contentType)
+		if err != nil {
+			log.Fatalf("Failed to copy %s: %v", name, err)
+		}
+		keys[name] = key
+	}
+	if *dryRun {
+		log.Printf("Would copy %d files from %s", len(keys), uploadPath)
+		return
+	}
+	log.Printf("Copied %d files from %s", len(keys), uploadPath)
+
+	// the paths stored by the local storage end with the name of the file
+	rewrite := func(path string) (string, bool) {
+		key, ok := keys[filepath.Base(path)]
+		return key, ok && key != path
+	}
+	changed, err := invoiceRepo.RewritePaths(ctx, rewrite)
+	if err != nil {
+		log.Fatalf("Failed to rewrite image paths: %v", err)
+	}
+	log.Printf("Rewrote the image paths of %d rows", changed)
+
+	// deduplicated files are found by the paths of their blobs
+	changed, err = blobRepo.RewritePaths(ctx, rewrite)
+	if err != nil {
+		log.Fatalf("Failed to rewrite blob paths: %v", err)
+	}
+	log.Printf("Rewrote the paths of %d blobs", changed)
+}
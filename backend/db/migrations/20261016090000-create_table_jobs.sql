@@ -0,0 +1,21 @@
+
+-- +migrate Up
+CREATE TABLE jobs (
+                      id VARCHAR(26) NOT NULL PRIMARY KEY,
+                      type VARCHAR(50) NOT NULL,
+                      payload JSON,
+                      status VARCHAR(20) NOT NULL DEFAULT 'queued',
+                      attempts INT NOT NULL DEFAULT 0,
+                      last_error TEXT,
+                      next_run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                      locked_by VARCHAR(100),
+                      locked_until TIMESTAMP NULL,
+                      heartbeat_at TIMESTAMP NULL,
+                      created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                      updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+                      INDEX idx_jobs_status_next_run_at (status, next_run_at),
+                      INDEX idx_jobs_status_locked_until (status, locked_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- +migrate Down
+DROP TABLE IF EXISTS jobs;
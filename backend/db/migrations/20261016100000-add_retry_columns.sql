@@ -0,0 +1,12 @@
+
+-- +migrate Up
+ALTER TABLE invoices
+    ADD COLUMN extraction_attempts INT NOT NULL DEFAULT 0 AFTER error_message;
+
+UPDATE jobs SET status = 'dead' WHERE status = 'failed';
+
+-- +migrate Down
+UPDATE jobs SET status = 'failed' WHERE status = 'dead';
+
+ALTER TABLE invoices
+    DROP COLUMN extraction_attempts;
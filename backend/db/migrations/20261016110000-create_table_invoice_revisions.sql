@@ -0,0 +1,14 @@
+
+-- +migrate Up
+CREATE TABLE invoice_revisions (
+                                   id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                                   invoice_id VARCHAR(26) NOT NULL,
+                                   revision INT NOT NULL,
+                                   extracted_data JSON,
+                                   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                                   UNIQUE KEY uk_invoice_revisions_invoice_revision (invoice_id, revision),
+                                   CONSTRAINT fk_invoice_revisions_invoice FOREIGN KEY (invoice_id) REFERENCES invoices (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- +migrate Down
+DROP TABLE IF EXISTS invoice_revisions;
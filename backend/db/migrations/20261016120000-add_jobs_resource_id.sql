@@ -0,0 +1,17 @@
+-- +migrate Up
+ALTER TABLE jobs
+    ADD COLUMN resource_id VARCHAR(26) NULL AFTER type,
+    ADD INDEX idx_jobs_type_resource_id (type, resource_id);
+
+UPDATE jobs
+SET resource_id = JSON_UNQUOTE(JSON_EXTRACT(payload, '$.invoice_id'))
+WHERE type = 'invoice.extraction';
+
+CREATE INDEX idx_invoices_status_updated_at ON invoices (status, updated_at);
+
+-- +migrate Down
+DROP INDEX idx_invoices_status_updated_at ON invoices;
+
+ALTER TABLE jobs
+    DROP INDEX idx_jobs_type_resource_id,
+    DROP COLUMN resource_id;
@@ -0,0 +1,23 @@
+-- +migrate Up
+ALTER TABLE invoices
+    ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '' AFTER status,
+    ADD COLUMN prompt_version VARCHAR(64) NOT NULL DEFAULT '' AFTER extracted_data,
+    ADD INDEX idx_invoices_tenant_id (tenant_id),
+    ADD INDEX idx_invoices_prompt_version (prompt_version);
+
+ALTER TABLE invoice_revisions
+    ADD COLUMN prompt_version VARCHAR(64) NOT NULL DEFAULT '' AFTER extracted_data;
+
+-- everything extracted so far used the only prompt there was
+UPDATE invoices SET prompt_version = 'v1' WHERE extracted_data IS NOT NULL;
+UPDATE invoice_revisions SET prompt_version = 'v1';
+
+-- +migrate Down
+ALTER TABLE invoice_revisions
+    DROP COLUMN prompt_version;
+
+ALTER TABLE invoices
+    DROP INDEX idx_invoices_prompt_version,
+    DROP INDEX idx_invoices_tenant_id,
+    DROP COLUMN prompt_version,
+    DROP COLUMN tenant_id;
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE extraction_cache (
+                                  cache_key CHAR(64) NOT NULL PRIMARY KEY,
+                                  image_hash CHAR(64) NOT NULL,
+                                  provider VARCHAR(64) NOT NULL,
+                                  model VARCHAR(128) NOT NULL DEFAULT '',
+                                  prompt_version VARCHAR(64) NOT NULL DEFAULT '',
+                                  extracted_data JSON NOT NULL,
+                                  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                  expires_at TIMESTAMP NULL,
+                                  INDEX idx_extraction_cache_image_hash (image_hash),
+                                  INDEX idx_extraction_cache_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- +migrate Down
+DROP TABLE IF EXISTS extraction_cache;
@@ -0,0 +1,25 @@
+-- +migrate Up
+CREATE TABLE extraction_attempts (
+                                     id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                                     invoice_id VARCHAR(26) NULL,
+                                     job_id VARCHAR(26) NOT NULL DEFAULT '',
+                                     tenant_id VARCHAR(64) NOT NULL DEFAULT '',
+                                     provider VARCHAR(64) NOT NULL,
+                                     model VARCHAR(128) NOT NULL DEFAULT '',
+                                     prompt_tokens INT NOT NULL DEFAULT 0,
+                                     output_tokens INT NOT NULL DEFAULT 0,
+                                     total_tokens INT NOT NULL DEFAULT 0,
+                                     latency_ms BIGINT NOT NULL DEFAULT 0,
+                                     cost_usd DECIMAL(14, 8) NOT NULL DEFAULT 0,
+                                     succeeded BOOLEAN NOT NULL DEFAULT FALSE,
+                                     error_message TEXT,
+                                     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                     INDEX idx_extraction_attempts_invoice_id (invoice_id),
+                                     INDEX idx_extraction_attempts_created_at (created_at),
+                                     INDEX idx_extraction_attempts_tenant_created_at (tenant_id, created_at),
+                                     -- keep the cost of deleted invoices
+                                     CONSTRAINT fk_extraction_attempts_invoice FOREIGN KEY (invoice_id) REFERENCES invoices (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- +migrate Down
+DROP TABLE IF EXISTS extraction_attempts;
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE invoices
+    ADD COLUMN page_count INT NOT NULL DEFAULT 1 AFTER prompt_version;
+
+-- +migrate Down
+ALTER TABLE invoices
+    DROP COLUMN page_count;
@@ -0,0 +1,18 @@
+-- +migrate Up
+CREATE TABLE invoice_pages (
+                               id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+                               invoice_id VARCHAR(26) NOT NULL,
+                               page_number INT NOT NULL,
+                               image_path VARCHAR(500) NOT NULL,
+                               mime_type VARCHAR(100) NOT NULL DEFAULT '',
+                               created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               UNIQUE KEY uk_invoice_pages_invoice_page (invoice_id, page_number),
+                               CONSTRAINT fk_invoice_pages_invoice FOREIGN KEY (invoice_id) REFERENCES invoices (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- every existing invoice is a single page, the worker detects its MIME type
+INSERT INTO invoice_pages (invoice_id, page_number, image_path, created_at)
+SELECT id, 1, image_path, created_at FROM invoices;
+
+-- +migrate Down
+DROP TABLE IF EXISTS invoice_pages;
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE invoice_pages
+    ADD COLUMN original_path VARCHAR(500) NOT NULL DEFAULT '' AFTER image_path;
+
+-- +migrate Down
+ALTER TABLE invoice_pages
+    DROP COLUMN original_path;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE blobs (
+                       hash CHAR(64) NOT NULL PRIMARY KEY,
+                       path VARCHAR(500) NOT NULL,
+                       ref_count INT NOT NULL DEFAULT 1,
+                       created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                       updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+                       UNIQUE KEY uk_blobs_path (path)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE invoice_pages
+    ADD COLUMN image_hash CHAR(64) NOT NULL DEFAULT '' AFTER original_path,
+    ADD INDEX idx_invoice_pages_image_hash (image_hash);
+
+-- +migrate Down
+ALTER TABLE invoice_pages
+    DROP INDEX idx_invoice_pages_image_hash,
+    DROP COLUMN image_hash;
+
+DROP TABLE IF EXISTS blobs;
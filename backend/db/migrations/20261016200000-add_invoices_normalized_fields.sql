@@ -0,0 +1,41 @@
+-- +migrate Up
+-- fields normalized from extracted_data, filled when invoices are extracted
+-- or edited
+ALTER TABLE invoices
+    ADD COLUMN invoice_number VARCHAR(100) NOT NULL DEFAULT '' AFTER prompt_version,
+    ADD COLUMN issue_date DATE NULL AFTER invoice_number,
+    ADD COLUMN seller_name VARCHAR(255) NOT NULL DEFAULT '' AFTER issue_date,
+    ADD COLUMN seller_tax_code VARCHAR(20) NOT NULL DEFAULT '' AFTER seller_name,
+    ADD COLUMN buyer_name VARCHAR(255) NOT NULL DEFAULT '' AFTER seller_tax_code,
+    ADD COLUMN buyer_tax_code VARCHAR(20) NOT NULL DEFAULT '' AFTER buyer_name,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT '' AFTER buyer_tax_code,
+    ADD COLUMN subtotal DECIMAL(20, 4) NULL AFTER currency,
+    ADD COLUMN vat_rate DECIMAL(7, 4) NULL AFTER subtotal,
+    ADD COLUMN vat DECIMAL(20, 4) NULL AFTER vat_rate,
+    ADD COLUMN total DECIMAL(20, 4) NULL AFTER vat,
+    ADD COLUMN line_items JSON NULL AFTER total,
+    ADD INDEX idx_invoices_invoice_number (invoice_number),
+    ADD INDEX idx_invoices_issue_date (issue_date),
+    ADD INDEX idx_invoices_seller_tax_code (seller_tax_code),
+    ADD INDEX idx_invoices_buyer_tax_code (buyer_tax_code),
+    ADD INDEX idx_invoices_total (total);
+
+-- +migrate Down
+ALTER TABLE invoices
+    DROP INDEX idx_invoices_invoice_number,
+    DROP INDEX idx_invoices_issue_date,
+    DROP INDEX idx_invoices_seller_tax_code,
+    DROP INDEX idx_invoices_buyer_tax_code,
+    DROP INDEX idx_invoices_total,
+    DROP COLUMN invoice_number,
+    DROP COLUMN issue_date,
+    DROP COLUMN seller_name,
+    DROP COLUMN seller_tax_code,
+    DROP COLUMN buyer_name,
+    DROP COLUMN buyer_tax_code,
+    DROP COLUMN currency,
+    DROP COLUMN subtotal,
+    DROP COLUMN vat_rate,
+    DROP COLUMN vat,
+    DROP COLUMN total,
+    DROP COLUMN line_items;
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- inconsistencies found in the normalized fields, which flag invoices for
+-- review
+ALTER TABLE invoices
+    ADD COLUMN findings JSON NULL AFTER line_items;
+
+-- +migrate Down
+UPDATE invoices SET status = 'completed' WHERE status = 'needs_review';
+ALTER TABLE invoices
+    DROP COLUMN findings;
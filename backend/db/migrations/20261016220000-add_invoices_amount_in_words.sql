@@ -0,0 +1,8 @@
+-- +migrate Up
+-- the total as printed in words, checked against the total
+ALTER TABLE invoices
+    ADD COLUMN amount_in_words VARCHAR(500) NOT NULL DEFAULT '' AFTER total;
+
+-- +migrate Down
+ALTER TABLE invoices
+    DROP COLUMN amount_in_words;
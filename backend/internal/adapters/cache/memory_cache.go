@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+var _ invoice.ResultCache = (*MemoryResultCache)(nil)
+
+type memoryEntry struct {
+	key       invoice.CacheKey
+	data      []byte
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+// MemoryResultCache keeps up to capacity extraction results in memory and
+// evicts the least recently used one when full. Results are stored encoded so
+// callers never share them.
+type MemoryResultCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewMemoryResultCache(capacity int) *MemoryResultCache {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &MemoryResultCache{
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryResultCache) Get(ctx context.Context, key invoice.CacheKey) (*invoice.CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key.String()]
+	if !ok {
+		return nil, nil
+	}
+
+	e := elem.Value.(*memoryEntry)
+	entry := &invoice.CacheEntry{Key: e.key, CreatedAt: e.createdAt, ExpiresAt: e.expiresAt}
+	if entry.Expired(c.now()) {
+		c.remove(elem)
+		return nil, nil
+	}
+
+	if err := json.Unmarshal(e.data, &entry.Data); err != nil {
+		return nil, err
+	}
+	c.order.MoveToFront(elem)
+	return entry, nil
+}
+
+func (c *MemoryResultCache) Set(ctx context.Context, entry *invoice.CacheEntry) error {
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &memoryEntry{key: entry.Key, data: data, createdAt: entry.CreatedAt, expiresAt: entry.ExpiresAt}
+	if elem, ok := c.entries[entry.Key.String()]; ok {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[entry.Key.String()] = c.order.PushFront(e)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *MemoryResultCache) InvalidateImage(ctx context.Context, imageHash string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*memoryEntry).key.ImageHash == imageHash {
+			c.remove(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed, nil
+}
+
+func (c *MemoryResultCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*memoryEntry).key.String())
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+func testEntry(imageHash, value string) *invoice.CacheEntry {
+	return &invoice.CacheEntry{
+		Key: invoice.CacheKey{ImageHash: imageHash, Provider: "gemini", Model: "gemini-2.5-flash", PromptVersion: "v1"},
+		Data: invoice.ExtractedData{
+			KeyValuePairs: []invoice.KeyValuePair{{Key: "Total", Value: value}},
+		},
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestMemoryResultCache_GetSet(t *testing.T) {
+	c := NewMemoryResultCache(10)
+	ctx := context.Background()
+	entry := testEntry("aaa", "100")
+
+	if got, err := c.Get(ctx, entry.Key); err != nil || got != nil {
+		t.Fatalf("Expected a miss, got %+v, %v", got, err)
+	}
+
+	if err := c.Set(ctx, entry); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	got, err := c.Get(ctx, entry.Key)
+	if err != nil || got == nil {
+		t.Fatalf("Expected a hit, got %+v, %v", got, err)
+	}
+	if got.Data.KeyValuePairs[0].Value != "100" {
+		t.Errorf("Expected cached value 100, got %v", got.Data.KeyValuePairs[0].Value)
+	}
+
+	// callers must not be able to change the cached result
+	got.Data.KeyValuePairs[0].Value = "changed"
+	again, _ := c.Get(ctx, entry.Key)
+	if again.Data.KeyValuePairs[0].Value != "100" {
+		t.Errorf("Expected cached value to be unchanged, got %v", again.Data.KeyValuePairs[0].Value)
+	}
+
+	otherModel := entry.Key
+	otherModel.Model = "gemini-2.5-pro"
+	if got, _ := c.Get(ctx, otherModel); got != nil {
+		t.Error("Expected a miss for another model")
+	}
+}
+
+func TestMemoryResultCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryResultCache(2)
+	ctx := context.Background()
+	first, second, third := testEntry("a", "1"), testEntry("b", "2"), testEntry("c", "3")
+
+	_ = c.Set(ctx, first)
+	_ = c.Set(ctx, second)
+	_, _ = c.Get(ctx, first.Key)
+	_ = c.Set(ctx, third)
+
+	if got, _ := c.Get(ctx, second.Key); got != nil {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if got, _ := c.Get(ctx, first.Key); got == nil {
+		t.Error("Expected the recently read entry to be kept")
+	}
+	if got, _ := c.Get(ctx, third.Key); got == nil {
+		t.Error("Expected the newest entry to be kept")
+	}
+}
+
+func TestMemoryResultCache_TTL(t *testing.T) {
+	c := NewMemoryResultCache(10)
+	ctx := context.Background()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	entry := testEntry("a", "1")
+	entry.ExpiresAt = now.Add(time.Hour)
+	_ = c.Set(ctx, entry)
+
+	if got, _ := c.Get(ctx, entry.Key); got == nil {
+		t.Fatal("Expected a hit before expiry")
+	}
+
+	c.now = func() time.Time { return now.Add(time.Hour) }
+	if got, _ := c.Get(ctx, entry.Key); got != nil {
+		t.Error("Expected a miss after expiry")
+	}
+	if c.order.Len() != 0 {
+		t.Errorf("Expected expired entry to be removed, %d left", c.order.Len())
+	}
+}
+
+func TestMemoryResultCache_InvalidateImage(t *testing.T) {
+	c := NewMemoryResultCache(10)
+	ctx := context.Background()
+
+	v1 := testEntry("a", "1")
+	v2 := testEntry("a", "2")
+	v2.Key.PromptVersion = "v2"
+	other := testEntry("b", "3")
+	for _, e := range []*invoice.CacheEntry{v1, v2, other} {
+		_ = c.Set(ctx, e)
+	}
+
+	removed, err := c.InvalidateImage(ctx, "a")
+	if err != nil {
+		t.Fatalf("InvalidateImage() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 entries removed, got %d", removed)
+	}
+	if got, _ := c.Get(ctx, v2.Key); got != nil {
+		t.Error("Expected every prompt version of the image to be invalidated")
+	}
+	if got, _ := c.Get(ctx, other.Key); got == nil {
+		t.Error("Expected other images to be kept")
+	}
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ domainstorage.BlobRepository = (*BlobGormRepo)(nil)
+
+type gormBlob struct {
+	Hash      string    `gorm:"column:hash;primaryKey"`
+	Path      string    `gorm:"column:path"`
+	RefCount  int       `gorm:"column:ref_count"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (gormBlob) TableName() string {
+	return "blobs"
+}
+
+// BlobGormRepo counts the references to stored files in the blobs table
+type BlobGormRepo struct {
+	db *gorm.DB
+}
+
+func NewBlobGormRepo(db *gorm.DB) *BlobGormRepo {
+	return &BlobGormRepo{db: db}
+}
+
+// AcquireExisting adds one to the references of the blob with hash. The update
+// waits for the row lock of a Release, and finds no row once it removed the
+// blob.
+func (r *BlobGormRepo) AcquireExisting(ctx context.Context, hash string) (*domainstorage.Blob, error) {
+	var m gormBlob
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&gormBlob{}).Where("hash = ?", hash).Updates(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count + 1"),
+			"updated_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domainstorage.ErrBlobNotFound
+		}
+		return tx.First(&m, "hash = ?", hash).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domainstorage.Blob{Hash: m.Hash, Path: m.Path, Refs: m.RefCount}, nil
+}
+
+// Acquire inserts the blob with one reference, or adds one to the existing row
+func (r *BlobGormRepo) Acquire(ctx context.Context, hash, path string) error {
+	now := time.Now()
+	m := &gormBlob{Hash: hash, Path: path, RefCount: 1, CreatedAt: now, UpdatedAt: now}
+	return getDBFromContext(ctx, r.db).WithContext(ctx).
+		Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"ref_count":  gorm.Expr("ref_count + 1"),
+				"updated_at": now,
+			}),
+		}).
+		Create(m).Error
+}
+
+// Release decrements the references of the blob at path with its row locked,
+// so concurrent releases of the last two references do not both keep it. The
+// row stays locked until remove deleted the file of the last one.
+func (r *BlobGormRepo) Release(ctx context.Context, path string, remove func(ctx context.Context) error) (int, error) {
+	var refs int
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var m gormBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&m, "path = ?", path).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domainstorage.ErrBlobNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		refs = m.RefCount - 1
+		if refs <= 0 {
+			refs = 0
+			if err := tx.Delete(&gormBlob{}, "hash = ?", m.Hash).Error; err != nil {
+				return err
+			}
+			return remove(ctx)
+		}
+		return tx.Model(&gormBlob{}).Where("hash = ?", m.Hash).Updates(map[string]interface{}{
+			"ref_count":  refs,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	return refs, err
+}
+
+// RewritePaths replaces the paths of blobs with the ones rewrite returns and
+// returns the number of blobs changed, like InvoiceGormRepo.RewritePaths
+func (r *BlobGormRepo) RewritePaths(ctx context.Context, rewrite func(path string) (string, bool)) (int64, error) {
+	var changed int64
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blobs []gormBlob
+		if err := tx.Select("hash", "path").Find(&blobs).Error; err != nil {
+			return err
+		}
+		for _, m := range blobs {
+			path, ok := rewrite(m.Path)
+			if !ok {
+				continue
+			}
+			// updated_at is set to itself, as MySQL bumps it on update
+			if err := tx.Model(&gormBlob{}).Where("hash = ?", m.Hash).UpdateColumns(map[string]interface{}{
+				"path":       path,
+				"updated_at": gorm.Expr("updated_at"),
+			}).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"gorm.io/gorm"
+)
+
+var _ invoice.UsageRepository = (*ExtractionAttemptGormRepo)(nil)
+
+type gormExtractionAttempt struct {
+	ID           int64          `gorm:"column:id;primaryKey;autoIncrement"`
+	InvoiceID    sql.NullString `gorm:"column:invoice_id"`
+	JobID        string         `gorm:"column:job_id"`
+	TenantID     string         `gorm:"column:tenant_id"`
+	Provider     string         `gorm:"column:provider"`
+	Model        string         `gorm:"column:model"`
+	PromptTokens int            `gorm:"column:prompt_tokens"`
+	OutputTokens int            `gorm:"column:output_tokens"`
+	TotalTokens  int            `gorm:"column:total_tokens"`
+	LatencyMs    int64          `gorm:"column:latency_ms"`
+	CostUSD      float64        `gorm:"column:cost_usd"`
+	Succeeded    bool           `gorm:"column:succeeded"`
+	ErrorMessage sql.NullString `gorm:"column:error_message"`
+	CreatedAt    time.Time      `gorm:"column:created_at"`
+}
+
+func (gormExtractionAttempt) TableName() string {
+	return "extraction_attempts"
+}
+
+type gormUsageSummary struct {
+	Day          string
+	Model        string
+	TenantID     string
+	Attempts     int64
+	Failed       int64
+	Invoices     int64
+	PromptTokens int64
+	OutputTokens int64
+	TotalTokens  int64
+	CostUSD      float64
+}
+
+// ExtractionAttemptGormRepo stores the usage and cost of provider calls in
+// the extraction_attempts table
+type ExtractionAttemptGormRepo struct {
+	db *gorm.DB
+}
+
+func NewExtractionAttemptGormRepo(db *gorm.DB) *ExtractionAttemptGormRepo {
+	return &ExtractionAttemptGormRepo{db: db}
+}
+
+func (r *ExtractionAttemptGormRepo) CreateAttempts(ctx context.Context, attempts invoice.ExtractionAttempts) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	models := make([]*gormExtractionAttempt, len(attempts))
+	for i, attempt := range attempts {
+		models[i] = r.toGorm(attempt)
+	}
+	if err := r.db.WithContext(ctx).Create(&models).Error; err != nil {
+		return err
+	}
+
+	for i, m := range models {
+		attempts[i].ID = m.ID
+	}
+	return nil
+}
+
+func (r *ExtractionAttemptGormRepo) ListAttempts(ctx context.Context, invoiceID invoice.ID) (invoice.ExtractionAttempts, error) {
+	var models []gormExtractionAttempt
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ?", invoiceID.String()).
+		Order("id ASC").
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	attempts := make(invoice.ExtractionAttempts, len(models))
+	for i := range models {
+		attempts[i] = r.toDomain(&models[i])
+	}
+	return attempts, nil
+}
+
+func (r *ExtractionAttemptGormRepo) SummarizeUsage(ctx context.Context, filter invoice.UsageFilter) ([]invoice.UsageSummary, error) {
+	query := r.db.WithContext(ctx).
+		Model(&gormExtractionAttempt{}).
+		Select(`DATE_FORMAT(created_at, '%Y-%m-%d') AS day,
+			model,
+			tenant_id,
+			COUNT(*) AS attempts,
+			SUM(CASE WHEN succeeded THEN 0 ELSE 1 END) AS failed,
+			COUNT(DISTINCT invoice_id) AS invoices,
+			SUM(prompt_tokens) AS prompt_tokens,
+			SUM(output_tokens) AS output_tokens,
+			SUM(total_tokens) AS total_tokens,
+			SUM(cost_usd) AS cost_usd`).
+		Group("day, model, tenant_id").
+		Order("day DESC, model, tenant_id")
+
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+
+	var rows []gormUsageSummary
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]invoice.UsageSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = invoice.UsageSummary(row)
+	}
+	return summaries, nil
+}
+
+func (r *ExtractionAttemptGormRepo) toGorm(attempt *invoice.ExtractionAttempt) *gormExtractionAttempt {
+	var errorMsg sql.NullString
+	if attempt.ErrorMessage != nil {
+		errorMsg = sql.NullString{String: *attempt.ErrorMessage, Valid: true}
+	}
+
+	return &gormExtractionAttempt{
+		ID:           attempt.ID,
+		InvoiceID:    sql.NullString{String: attempt.InvoiceID.String(), Valid: attempt.InvoiceID != ""},
+		JobID:        attempt.JobID,
+		TenantID:     attempt.TenantID,
+		Provider:     attempt.Provider,
+		Model:        attempt.Model,
+		PromptTokens: attempt.PromptTokens,
+		OutputTokens: attempt.OutputTokens,
+		TotalTokens:  attempt.TotalTokens,
+		LatencyMs:    attempt.LatencyMs,
+		CostUSD:      attempt.CostUSD,
+		Succeeded:    attempt.Succeeded,
+		ErrorMessage: errorMsg,
+		CreatedAt:    attempt.CreatedAt,
+	}
+}
+
+func (r *ExtractionAttemptGormRepo) toDomain(m *gormExtractionAttempt) *invoice.ExtractionAttempt {
+	var errorMsg *string
+	if m.ErrorMessage.Valid {
+		errorMsg = &m.ErrorMessage.String
+	}
+
+	return &invoice.ExtractionAttempt{
+		ID:           m.ID,
+		InvoiceID:    invoice.ID(m.InvoiceID.String),
+		JobID:        m.JobID,
+		TenantID:     m.TenantID,
+		Provider:     m.Provider,
+		Model:        m.Model,
+		PromptTokens: m.PromptTokens,
+		OutputTokens: m.OutputTokens,
+		TotalTokens:  m.TotalTokens,
+		LatencyMs:    m.LatencyMs,
+		CostUSD:      m.CostUSD,
+		Succeeded:    m.Succeeded,
+		ErrorMessage: errorMsg,
+		CreatedAt:    m.CreatedAt,
+	}
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ invoice.ResultCache = (*ExtractionCacheGormRepo)(nil)
+
+type gormExtractionCache struct {
+	CacheKey      string         `gorm:"column:cache_key;primaryKey"`
+	ImageHash     string         `gorm:"column:image_hash"`
+	Provider      string         `gorm:"column:provider"`
+	Model         string         `gorm:"column:model"`
+	PromptVersion string         `gorm:"column:prompt_version"`
+	ExtractedData datatypes.JSON `gorm:"column:extracted_data"`
+	CreatedAt     time.Time      `gorm:"column:created_at"`
+	ExpiresAt     sql.NullTime   `gorm:"column:expires_at"`
+}
+
+func (gormExtractionCache) TableName() string {
+	return "extraction_cache"
+}
+
+// ExtractionCacheGormRepo stores extraction results in the extraction_cache
+// table. Expired rows are deleted when they are read.
+type ExtractionCacheGormRepo struct {
+	db *gorm.DB
+}
+
+func NewExtractionCacheGormRepo(db *gorm.DB) *ExtractionCacheGormRepo {
+	return &ExtractionCacheGormRepo{db: db}
+}
+
+func (r *ExtractionCacheGormRepo) Get(ctx context.Context, key invoice.CacheKey) (*invoice.CacheEntry, error) {
+	var m gormExtractionCache
+	err := r.db.WithContext(ctx).First(&m, "cache_key = ?", key.String()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &invoice.CacheEntry{Key: key, CreatedAt: m.CreatedAt}
+	if m.ExpiresAt.Valid {
+		entry.ExpiresAt = m.ExpiresAt.Time
+	}
+	if entry.Expired(time.Now()) {
+		err := r.db.WithContext(ctx).
+			Where("cache_key = ? AND expires_at <= ?", m.CacheKey, time.Now()).
+			Delete(&gormExtractionCache{}).Error
+		return nil, err
+	}
+
+	if err := json.Unmarshal(m.ExtractedData, &entry.Data); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Set inserts the entry or replaces the one stored under the same key
+func (r *ExtractionCacheGormRepo) Set(ctx context.Context, entry *invoice.CacheEntry) error {
+	data, err := json.Marshal(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	m := &gormExtractionCache{
+		CacheKey:      entry.Key.String(),
+		ImageHash:     entry.Key.ImageHash,
+		Provider:      entry.Key.Provider,
+		Model:         entry.Key.Model,
+		PromptVersion: entry.Key.PromptVersion,
+		ExtractedData: datatypes.JSON(data),
+		CreatedAt:     entry.CreatedAt,
+		ExpiresAt:     sql.NullTime{Time: entry.ExpiresAt, Valid: !entry.ExpiresAt.IsZero()},
+	}
+
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(m).Error
+}
+
+func (r *ExtractionCacheGormRepo) InvalidateImage(ctx context.Context, imageHash string) (int, error) {
+	result := r.db.WithContext(ctx).
+		Where("image_hash = ?", imageHash).
+		Delete(&gormExtractionCache{})
+	return int(result.RowsAffected), result.Error
+}
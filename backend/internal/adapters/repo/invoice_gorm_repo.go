@@ -3,29 +3,80 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"invoice-scan/backend/pkg/money"
 	"invoice-scan/backend/pkg/ulid"
+	"strings"
 	"time"
 
 	"invoice-scan/backend/internal/domain/invoice"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type gormInvoice struct {
-	ID            string         `gorm:"column:id;primaryKey"`
-	Status        string         `gorm:"column:status"`
-	ImagePath     string         `gorm:"column:image_path"`
-	ExtractedData datatypes.JSON `gorm:"column:extracted_data"`
-	ErrorMessage  sql.NullString `gorm:"column:error_message"`
-	CreatedAt     time.Time      `gorm:"column:created_at"`
-	UpdatedAt     time.Time      `gorm:"column:updated_at"`
+	ID                 string         `gorm:"column:id;primaryKey"`
+	Status             string         `gorm:"column:status"`
+	TenantID           string         `gorm:"column:tenant_id"`
+	ImagePath          string         `gorm:"column:image_path"`
+	ExtractedData      datatypes.JSON `gorm:"column:extracted_data"`
+	PromptVersion      string         `gorm:"column:prompt_version"`
+	InvoiceNumber      string         `gorm:"column:invoice_number"`
+	IssueDate          sql.NullTime   `gorm:"column:issue_date"`
+	SellerName         string         `gorm:"column:seller_name"`
+	SellerTaxCode      string         `gorm:"column:seller_tax_code"`
+	BuyerName          string         `gorm:"column:buyer_name"`
+	BuyerTaxCode       string         `gorm:"column:buyer_tax_code"`
+	Currency           string         `gorm:"column:currency"`
+	Subtotal           sql.NullString `gorm:"column:subtotal"`
+	VATRate            sql.NullString `gorm:"column:vat_rate"`
+	VAT                sql.NullString `gorm:"column:vat"`
+	Total              sql.NullString `gorm:"column:total"`
+	AmountInWords      string         `gorm:"column:amount_in_words"`
+	LineItems          datatypes.JSON `gorm:"column:line_items"`
+	Findings           datatypes.JSON `gorm:"column:findings"`
+	PageCount          int            `gorm:"column:page_count"`
+	ErrorMessage       sql.NullString `gorm:"column:error_message"`
+	ExtractionAttempts int            `gorm:"column:extraction_attempts"`
+	CreatedAt          time.Time      `gorm:"column:created_at"`
+	UpdatedAt          time.Time      `gorm:"column:updated_at"`
 }
 
 func (gormInvoice) TableName() string {
 	return "invoices"
 }
 
+type gormInvoiceRevision struct {
+	ID            int64          `gorm:"column:id;primaryKey;autoIncrement"`
+	InvoiceID     string         `gorm:"column:invoice_id"`
+	Revision      int            `gorm:"column:revision"`
+	ExtractedData datatypes.JSON `gorm:"column:extracted_data"`
+	PromptVersion string         `gorm:"column:prompt_version"`
+	CreatedAt     time.Time      `gorm:"column:created_at"`
+}
+
+func (gormInvoiceRevision) TableName() string {
+	return "invoice_revisions"
+}
+
+type gormInvoicePage struct {
+	ID           int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	InvoiceID    string    `gorm:"column:invoice_id"`
+	PageNumber   int       `gorm:"column:page_number"`
+	ImagePath    string    `gorm:"column:image_path"`
+	OriginalPath string    `gorm:"column:original_path"`
+	ImageHash    string    `gorm:"column:image_hash"`
+	MIMEType     string    `gorm:"column:mime_type"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+}
+
+func (gormInvoicePage) TableName() string {
+	return "invoice_pages"
+}
+
 type InvoiceGormRepo struct {
 	db *gorm.DB
 }
@@ -51,6 +102,23 @@ func (r *InvoiceGormRepo) GetByID(ctx context.Context, id invoice.ID) (*invoice.
 	var gormInv gormInvoice
 	err := r.db.WithContext(ctx).
 		First(&gormInv, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invoice.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(&gormInv), nil
+}
+
+func (r *InvoiceGormRepo) GetForUpdate(ctx context.Context, id invoice.ID) (*invoice.Invoice, error) {
+	var gormInv gormInvoice
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&gormInv, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invoice.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +169,27 @@ func (r *InvoiceGormRepo) List(ctx context.Context, params invoice.PaginationPar
 	}, nil
 }
 
+func (r *InvoiceGormRepo) ListStale(ctx context.Context, updatedBefore time.Time, params invoice.PaginationParams) (invoice.Invoices, error) {
+	var gormInvoices []gormInvoice
+
+	offset := (params.Page - 1) * params.PageSize
+	if err := r.db.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?",
+			[]string{invoice.StatusPending.String(), invoice.StatusProcessing.String()}, updatedBefore).
+		Order("updated_at ASC").
+		Limit(params.PageSize).
+		Offset(offset).
+		Find(&gormInvoices).Error; err != nil {
+		return nil, err
+	}
+
+	invoices := make(invoice.Invoices, len(gormInvoices))
+	for i, m := range gormInvoices {
+		invoices[i] = r.toDomain(&m)
+	}
+	return invoices, nil
+}
+
 func (r *InvoiceGormRepo) Update(ctx context.Context, inv *invoice.Invoice, updateFunc func(invoice2 *invoice.Invoice) error) error {
 	var (
 		db = getDBFromContext(ctx, r.db)
@@ -110,8 +199,9 @@ func (r *InvoiceGormRepo) Update(ctx context.Context, inv *invoice.Invoice, upda
 		return err
 	}
 
+	// select all columns so that cleared fields (e.g. error_message) are written too
 	gormInv := r.toGorm(inv)
-	return db.WithContext(ctx).Model(&gormInv).Updates(&gormInv).Error
+	return db.WithContext(ctx).Model(&gormInv).Select("*").Updates(&gormInv).Error
 }
 
 func (r *InvoiceGormRepo) Delete(ctx context.Context, id invoice.ID) error {
@@ -123,6 +213,168 @@ func (r *InvoiceGormRepo) Delete(ctx context.Context, id invoice.ID) error {
 	return nil
 }
 
+func (r *InvoiceGormRepo) AddPages(ctx context.Context, pages invoice.Pages) error {
+	if len(pages) == 0 {
+		return nil
+	}
+
+	db := getDBFromContext(ctx, r.db)
+
+	models := make([]*gormInvoicePage, len(pages))
+	for i, page := range pages {
+		models[i] = &gormInvoicePage{
+			InvoiceID:    page.InvoiceID.String(),
+			PageNumber:   page.Number,
+			ImagePath:    page.ImagePath,
+			OriginalPath: page.OriginalPath,
+			ImageHash:    page.ImageHash,
+			MIMEType:     page.MIMEType,
+			CreatedAt:    page.CreatedAt,
+		}
+	}
+	if err := db.WithContext(ctx).Create(&models).Error; err != nil {
+		return err
+	}
+
+	for i, m := range models {
+		pages[i].ID = m.ID
+	}
+	return nil
+}
+
+func (r *InvoiceGormRepo) ListPages(ctx context.Context, invoiceID invoice.ID) (invoice.Pages, error) {
+	var models []gormInvoicePage
+	if err := getDBFromContext(ctx, r.db).WithContext(ctx).
+		Where("invoice_id = ?", invoiceID.String()).
+		Order("page_number ASC").
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	pages := make(invoice.Pages, len(models))
+	for i, m := range models {
+		pages[i] = &invoice.Page{
+			ID:           m.ID,
+			InvoiceID:    invoice.ID(m.InvoiceID),
+			Number:       m.PageNumber,
+			ImagePath:    m.ImagePath,
+			OriginalPath: m.OriginalPath,
+			ImageHash:    m.ImageHash,
+			MIMEType:     m.MIMEType,
+			CreatedAt:    m.CreatedAt,
+		}
+	}
+	return pages, nil
+}
+
+// RewritePaths replaces the image paths of invoices and pages with the ones
+// rewrite returns, in one transaction, and returns the number of rows changed.
+// Paths rewrite returns false for are left alone.
+func (r *InvoiceGormRepo) RewritePaths(ctx context.Context, rewrite func(path string) (string, bool)) (int64, error) {
+	var changed int64
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invoices []gormInvoice
+		if err := tx.Select("id", "image_path").Find(&invoices).Error; err != nil {
+			return err
+		}
+		for _, m := range invoices {
+			path, ok := rewrite(m.ImagePath)
+			if !ok {
+				continue
+			}
+			// moving a file is not an edit, and a stale pending invoice must
+			// still look stale to the reaper. updated_at is set to itself, as
+			// the column is otherwise bumped on update by MySQL.
+			if err := tx.Model(&gormInvoice{}).Where("id = ?", m.ID).UpdateColumns(map[string]interface{}{
+				"image_path": path,
+				"updated_at": gorm.Expr("updated_at"),
+			}).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+
+		var pages []gormInvoicePage
+		if err := tx.Select("id", "image_path", "original_path").Find(&pages).Error; err != nil {
+			return err
+		}
+		for _, m := range pages {
+			updates := map[string]interface{}{}
+			if path, ok := rewrite(m.ImagePath); ok {
+				updates["image_path"] = path
+			}
+			if m.OriginalPath != "" {
+				if path, ok := rewrite(m.OriginalPath); ok {
+					updates["original_path"] = path
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			// pages have no updated_at to keep
+			if err := tx.Model(&gormInvoicePage{}).Where("id = ?", m.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// CreateRevision stores the snapshot with the next revision number of its invoice
+func (r *InvoiceGormRepo) CreateRevision(ctx context.Context, rev *invoice.Revision) error {
+	db := getDBFromContext(ctx, r.db)
+
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last sql.NullInt64
+		if err := tx.Model(&gormInvoiceRevision{}).
+			Where("invoice_id = ?", rev.InvoiceID.String()).
+			Select("MAX(revision)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+
+		m := &gormInvoiceRevision{
+			InvoiceID:     rev.InvoiceID.String(),
+			Revision:      int(last.Int64) + 1,
+			ExtractedData: datatypes.JSON(rev.ExtractedData),
+			PromptVersion: rev.PromptVersion,
+			CreatedAt:     rev.CreatedAt,
+		}
+		if err := tx.Create(m).Error; err != nil {
+			return err
+		}
+
+		rev.ID = m.ID
+		rev.Number = m.Revision
+		return nil
+	})
+}
+
+func (r *InvoiceGormRepo) ListRevisions(ctx context.Context, invoiceID invoice.ID) (invoice.Revisions, error) {
+	var models []gormInvoiceRevision
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ?", invoiceID.String()).
+		Order("revision DESC").
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+
+	revisions := make(invoice.Revisions, len(models))
+	for i, m := range models {
+		revisions[i] = &invoice.Revision{
+			ID:            m.ID,
+			InvoiceID:     invoice.ID(m.InvoiceID),
+			Number:        m.Revision,
+			ExtractedData: []byte(m.ExtractedData),
+			PromptVersion: m.PromptVersion,
+			CreatedAt:     m.CreatedAt,
+		}
+	}
+	return revisions, nil
+}
+
 func (r *InvoiceGormRepo) toGorm(inv *invoice.Invoice) *gormInvoice {
 	var errorMsg sql.NullString
 	if inv.ErrorMessage != nil {
@@ -131,14 +383,44 @@ func (r *InvoiceGormRepo) toGorm(inv *invoice.Invoice) *gormInvoice {
 			Valid:  true,
 		}
 	}
+	var issueDate sql.NullTime
+	if inv.Fields.IssueDate != nil {
+		issueDate = sql.NullTime{Time: *inv.Fields.IssueDate, Valid: true}
+	}
+	var lineItems datatypes.JSON
+	if len(inv.Fields.LineItems) > 0 {
+		lineItems, _ = json.Marshal(inv.Fields.LineItems)
+	}
+	var findings datatypes.JSON
+	if len(inv.Findings) > 0 {
+		findings, _ = json.Marshal(inv.Findings)
+	}
 	return &gormInvoice{
-		ID:            inv.ID.String(),
-		Status:        inv.Status.String(),
-		ImagePath:     inv.ImagePath,
-		ExtractedData: datatypes.JSON(inv.ExtractedData),
-		ErrorMessage:  errorMsg,
-		CreatedAt:     inv.CreatedAt,
-		UpdatedAt:     inv.UpdatedAt,
+		ID:                 inv.ID.String(),
+		Status:             inv.Status.String(),
+		TenantID:           inv.TenantID,
+		ImagePath:          inv.ImagePath,
+		ExtractedData:      datatypes.JSON(inv.ExtractedData),
+		PromptVersion:      inv.PromptVersion,
+		InvoiceNumber:      inv.Fields.InvoiceNumber,
+		IssueDate:          issueDate,
+		SellerName:         inv.Fields.SellerName,
+		SellerTaxCode:      inv.Fields.SellerTaxCode,
+		BuyerName:          inv.Fields.BuyerName,
+		BuyerTaxCode:       inv.Fields.BuyerTaxCode,
+		Currency:           inv.Fields.Currency,
+		Subtotal:           toDecimalColumn(inv.Fields.Subtotal, amountDigits),
+		VATRate:            toDecimalColumn(inv.Fields.VATRate, rateDigits),
+		VAT:                toDecimalColumn(inv.Fields.VAT, amountDigits),
+		Total:              toDecimalColumn(inv.Fields.Total, amountDigits),
+		AmountInWords:      inv.Fields.AmountInWords,
+		LineItems:          lineItems,
+		Findings:           findings,
+		PageCount:          inv.PageCount,
+		ErrorMessage:       errorMsg,
+		ExtractionAttempts: inv.ExtractionAttempts,
+		CreatedAt:          inv.CreatedAt,
+		UpdatedAt:          inv.UpdatedAt,
 	}
 }
 
@@ -147,13 +429,76 @@ func (r *InvoiceGormRepo) toDomain(m *gormInvoice) *invoice.Invoice {
 	if m.ErrorMessage.Valid {
 		errorMsg = &m.ErrorMessage.String
 	}
+	fields := invoice.Fields{
+		InvoiceNumber: m.InvoiceNumber,
+		SellerName:    m.SellerName,
+		SellerTaxCode: m.SellerTaxCode,
+		BuyerName:     m.BuyerName,
+		BuyerTaxCode:  m.BuyerTaxCode,
+		Currency:      m.Currency,
+		Subtotal:      fromDecimalColumn(m.Subtotal),
+		VATRate:       fromDecimalColumn(m.VATRate),
+		VAT:           fromDecimalColumn(m.VAT),
+		Total:         fromDecimalColumn(m.Total),
+		AmountInWords: m.AmountInWords,
+	}
+	if m.IssueDate.Valid {
+		fields.IssueDate = &m.IssueDate.Time
+	}
+	if len(m.LineItems) > 0 {
+		_ = json.Unmarshal(m.LineItems, &fields.LineItems)
+	}
+	var findings []invoice.Finding
+	if len(m.Findings) > 0 {
+		_ = json.Unmarshal(m.Findings, &findings)
+	}
 	return &invoice.Invoice{
-		ID:            invoice.ID(m.ID),
-		Status:        invoice.Status(m.Status),
-		ImagePath:     m.ImagePath,
-		ExtractedData: []byte(m.ExtractedData),
-		ErrorMessage:  errorMsg,
-		CreatedAt:     m.CreatedAt,
-		UpdatedAt:     m.UpdatedAt,
+		ID:                 invoice.ID(m.ID),
+		Status:             invoice.Status(m.Status),
+		TenantID:           m.TenantID,
+		ImagePath:          m.ImagePath,
+		ExtractedData:      []byte(m.ExtractedData),
+		Fields:             fields,
+		Findings:           findings,
+		PromptVersion:      m.PromptVersion,
+		PageCount:          m.PageCount,
+		ErrorMessage:       errorMsg,
+		ExtractionAttempts: m.ExtractionAttempts,
+		CreatedAt:          m.CreatedAt,
+		UpdatedAt:          m.UpdatedAt,
+	}
+}
+
+const (
+	// amountDigits and rateDigits are the integer digits of the DECIMAL(20,4)
+	// amount and DECIMAL(7,4) rate columns, decimalScale their fraction digits
+	amountDigits = 16
+	rateDigits   = 3
+	decimalScale = 4
+)
+
+// toDecimalColumn stores a decimal rounded to the column scale, or NULL for a
+// missing one or one too large for its column, which MySQL would reject
+func toDecimalColumn(value *money.Decimal, digits int) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	rounded := value.Round(decimalScale).String()
+	whole, _, _ := strings.Cut(strings.TrimPrefix(rounded, "-"), ".")
+	if len(whole) > digits {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: rounded, Valid: true}
+}
+
+// fromDecimalColumn reads a decimal column, nil for NULL
+func fromDecimalColumn(value sql.NullString) *money.Decimal {
+	if !value.Valid {
+		return nil
+	}
+	d, err := money.ParseDecimal(value.String)
+	if err != nil {
+		return nil
 	}
+	return &d
 }
@@ -0,0 +1,326 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"invoice-scan/backend/internal/domain/job"
+	"invoice-scan/backend/pkg/ulid"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ job.JobQueue = (*JobGormRepo)(nil)
+
+type gormJob struct {
+	ID          string         `gorm:"column:id;primaryKey"`
+	Type        string         `gorm:"column:type"`
+	ResourceID  sql.NullString `gorm:"column:resource_id"`
+	Payload     datatypes.JSON `gorm:"column:payload"`
+	Status      string         `gorm:"column:status"`
+	Attempts    int            `gorm:"column:attempts"`
+	LastError   sql.NullString `gorm:"column:last_error"`
+	NextRunAt   time.Time      `gorm:"column:next_run_at"`
+	LockedBy    sql.NullString `gorm:"column:locked_by"`
+	LockedUntil sql.NullTime   `gorm:"column:locked_until"`
+	HeartbeatAt sql.NullTime   `gorm:"column:heartbeat_at"`
+	CreatedAt   time.Time      `gorm:"column:created_at"`
+	UpdatedAt   time.Time      `gorm:"column:updated_at"`
+}
+
+func (gormJob) TableName() string {
+	return "jobs"
+}
+
+type JobGormRepo struct {
+	db *gorm.DB
+}
+
+func NewJobGormRepo(db *gorm.DB) *JobGormRepo {
+	return &JobGormRepo{db: db}
+}
+
+func (r *JobGormRepo) NextID() job.ID {
+	return job.ID(ulid.GenerateULID())
+}
+
+func (r *JobGormRepo) Enqueue(ctx context.Context, j *job.Job) error {
+	db := getDBFromContext(ctx, r.db)
+	return db.WithContext(ctx).Create(r.toGorm(j)).Error
+}
+
+// Claim locks the next runnable job with SELECT ... FOR UPDATE SKIP LOCKED so
+// concurrent workers never pick the same row. A job is runnable when it is
+// queued and due, or when it is running but its lease has expired.
+func (r *JobGormRepo) Claim(ctx context.Context, workerID string, lease time.Duration) (*job.Job, error) {
+	var claimed *job.Job
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var m gormJob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_run_at <= ?) OR (status = ? AND locked_until < ?)",
+				job.StatusQueued.String(), now, job.StatusRunning.String(), now).
+			Order("next_run_at ASC").
+			Limit(1).
+			Take(&m).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return job.ErrNoJobAvailable
+		}
+		if err != nil {
+			return err
+		}
+
+		claimed = r.toDomain(&m)
+		claimed.MarkRunning(workerID, lease)
+
+		return tx.Model(&gormJob{}).
+			Where("id = ?", m.ID).
+			Updates(map[string]interface{}{
+				"status":       claimed.Status.String(),
+				"attempts":     claimed.Attempts,
+				"locked_by":    claimed.LockedBy,
+				"locked_until": claimed.LockedUntil,
+				"heartbeat_at": claimed.HeartbeatAt,
+				"updated_at":   claimed.UpdatedAt,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+func (r *JobGormRepo) Heartbeat(ctx context.Context, j *job.Job, lease time.Duration) error {
+	j.ExtendLease(lease)
+	return r.updateOwned(ctx, j.ID, j.LockedBy, map[string]interface{}{
+		"locked_until": j.LockedUntil,
+		"heartbeat_at": j.HeartbeatAt,
+		"updated_at":   j.UpdatedAt,
+	})
+}
+
+func (r *JobGormRepo) Complete(ctx context.Context, j *job.Job) error {
+	workerID := j.LockedBy
+	j.MarkSucceeded()
+	return r.finish(ctx, j, workerID)
+}
+
+func (r *JobGormRepo) Retry(ctx context.Context, j *job.Job, errMsg string, runAt time.Time) error {
+	workerID := j.LockedBy
+	j.MarkRetry(errMsg, runAt)
+	return r.finish(ctx, j, workerID)
+}
+
+func (r *JobGormRepo) DeadLetter(ctx context.Context, j *job.Job, errMsg string) error {
+	workerID := j.LockedBy
+	j.MarkDead(errMsg)
+	return r.finish(ctx, j, workerID)
+}
+
+func (r *JobGormRepo) Abandon(ctx context.Context, j *job.Job, errMsg string) error {
+	db := getDBFromContext(ctx, r.db)
+
+	j.MarkDead(errMsg)
+	result := db.WithContext(ctx).
+		Model(&gormJob{}).
+		Where("id = ? AND status = ?", j.ID.String(), job.StatusRunning.String()).
+		Updates(map[string]interface{}{
+			"status":       j.Status.String(),
+			"last_error":   j.LastError,
+			"locked_by":    nil,
+			"locked_until": nil,
+			"updated_at":   j.UpdatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return job.ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *JobGormRepo) GetByID(ctx context.Context, id job.ID) (*job.Job, error) {
+	var m gormJob
+	err := getDBFromContext(ctx, r.db).WithContext(ctx).First(&m, "id = ?", id.String()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, job.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(&m), nil
+}
+
+func (r *JobGormRepo) FindLatest(ctx context.Context, jobType job.Type, resourceID string) (*job.Job, error) {
+	var m gormJob
+	err := r.db.WithContext(ctx).
+		Where("type = ? AND resource_id = ?", jobType.String(), resourceID).
+		Order("created_at DESC, id DESC").
+		Take(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, job.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.toDomain(&m), nil
+}
+
+func (r *JobGormRepo) ListDeadLetters(ctx context.Context, params job.ListParams) (*job.PaginatedResult, error) {
+	var (
+		gormJobs []gormJob
+		total    int64
+	)
+
+	query := r.db.WithContext(ctx).Model(&gormJob{}).Where("status = ?", job.StatusDead.String())
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+	if err := query.
+		Order("updated_at DESC").
+		Limit(params.PageSize).
+		Offset(offset).
+		Find(&gormJobs).Error; err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*job.Job, len(gormJobs))
+	for i, m := range gormJobs {
+		jobs[i] = r.toDomain(&m)
+	}
+
+	totalPages := int(total) / params.PageSize
+	if int(total)%params.PageSize > 0 {
+		totalPages++
+	}
+
+	return &job.PaginatedResult{
+		Jobs:       jobs,
+		Total:      total,
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+func (r *JobGormRepo) Requeue(ctx context.Context, id job.ID) (*job.Job, error) {
+	db := getDBFromContext(ctx, r.db)
+
+	j, err := r.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != job.StatusDead {
+		return nil, job.ErrNotDead
+	}
+
+	j.Requeue()
+	result := db.WithContext(ctx).
+		Model(&gormJob{}).
+		Where("id = ? AND status = ?", id.String(), job.StatusDead.String()).
+		Updates(map[string]interface{}{
+			"status":      j.Status.String(),
+			"attempts":    j.Attempts,
+			"next_run_at": j.NextRunAt,
+			"updated_at":  j.UpdatedAt,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, job.ErrNotDead
+	}
+
+	return j, nil
+}
+
+func (r *JobGormRepo) finish(ctx context.Context, j *job.Job, workerID string) error {
+	return r.updateOwned(ctx, j.ID, workerID, map[string]interface{}{
+		"status":       j.Status.String(),
+		"last_error":   j.LastError,
+		"next_run_at":  j.NextRunAt,
+		"locked_by":    nil,
+		"locked_until": nil,
+		"updated_at":   j.UpdatedAt,
+	})
+}
+
+// updateOwned applies updates only if the job is still leased by workerID
+func (r *JobGormRepo) updateOwned(ctx context.Context, id job.ID, workerID string, updates map[string]interface{}) error {
+	db := getDBFromContext(ctx, r.db)
+	result := db.WithContext(ctx).
+		Model(&gormJob{}).
+		Where("id = ? AND locked_by = ?", id.String(), workerID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return job.ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *JobGormRepo) toGorm(j *job.Job) *gormJob {
+	m := &gormJob{
+		ID:        j.ID.String(),
+		Type:      j.Type.String(),
+		Payload:   datatypes.JSON(j.Payload),
+		Status:    j.Status.String(),
+		Attempts:  j.Attempts,
+		NextRunAt: j.NextRunAt,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+	if j.ResourceID != "" {
+		m.ResourceID = sql.NullString{String: j.ResourceID, Valid: true}
+	}
+	if j.LastError != nil {
+		m.LastError = sql.NullString{String: *j.LastError, Valid: true}
+	}
+	if j.LockedBy != "" {
+		m.LockedBy = sql.NullString{String: j.LockedBy, Valid: true}
+	}
+	if j.LockedUntil != nil {
+		m.LockedUntil = sql.NullTime{Time: *j.LockedUntil, Valid: true}
+	}
+	if j.HeartbeatAt != nil {
+		m.HeartbeatAt = sql.NullTime{Time: *j.HeartbeatAt, Valid: true}
+	}
+	return m
+}
+
+func (r *JobGormRepo) toDomain(m *gormJob) *job.Job {
+	j := &job.Job{
+		ID:         job.ID(m.ID),
+		Type:       job.Type(m.Type),
+		ResourceID: m.ResourceID.String,
+		Payload:    []byte(m.Payload),
+		Status:     job.Status(m.Status),
+		Attempts:   m.Attempts,
+		NextRunAt:  m.NextRunAt,
+		LockedBy:   m.LockedBy.String,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+	if m.LastError.Valid {
+		j.LastError = &m.LastError.String
+	}
+	if m.LockedUntil.Valid {
+		j.LockedUntil = &m.LockedUntil.Time
+	}
+	if m.HeartbeatAt.Valid {
+		j.HeartbeatAt = &m.HeartbeatAt.Time
+	}
+	return j
+}
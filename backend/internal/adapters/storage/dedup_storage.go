@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+)
+
+var (
+	_ domainstorage.HashingStorage = (*DedupStorage)(nil)
+	_ domainstorage.SharingStorage = (*DedupStorage)(nil)
+)
+
+// DedupStorage stores files in another storage once per content, as
+// <sha256><ext>, and counts the references to them so a file saved by several
+// invoices is deleted with the last of them
+type DedupStorage struct {
+	files domainstorage.FileStorage
+	blobs domainstorage.BlobRepository
+}
+
+func NewDedupStorage(files domainstorage.FileStorage, blobs domainstorage.BlobRepository) *DedupStorage {
+	return &DedupStorage{files: files, blobs: blobs}
+}
+
+// Save stores data under its hash and the extension of filename, which is
+// otherwise ignored
+func (s *DedupStorage) Save(ctx context.Context, filename string, data []byte, contentType string) (string, error) {
+	path, _, err := s.SaveHashed(ctx, filename, data, contentType)
+	return path, err
+}
+
+func (s *DedupStorage) SaveHashed(ctx context.Context, filename string, data []byte, contentType string) (string, string, error) {
+	if !domainstorage.IsSupportedContentType(contentType) {
+		return "", "", fmt.Errorf("invalid content type: %s", contentType)
+	}
+	hash := domainstorage.ContentHash(data)
+
+	// a blob being deleted is only found again once its file is gone, and
+	// then written anew
+	blob, err := s.blobs.AcquireExisting(ctx, hash)
+	if err == nil {
+		return blob.Path, hash, nil
+	}
+	if !errors.Is(err, domainstorage.ErrBlobNotFound) {
+		return "", "", fmt.Errorf("failed to reference blob: %w", err)
+	}
+
+	// saving the same content twice writes the same file, so uploads racing
+	// on a new blob both end up referencing it
+	path, err := s.files.Save(ctx, hash+strings.ToLower(filepath.Ext(filename)), data, contentType)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.blobs.Acquire(ctx, hash, path); err != nil {
+		return "", "", fmt.Errorf("failed to reference blob: %w", err)
+	}
+	return path, hash, nil
+}
+
+func (s *DedupStorage) Get(ctx context.Context, path string) ([]byte, error) {
+	return s.files.Get(ctx, path)
+}
+
+// Delete removes a reference to the file at path, and the file with the last
+// one. Files saved before deduplication have no references and are deleted
+// right away.
+func (s *DedupStorage) Delete(ctx context.Context, path string) error {
+	_, err := s.DeleteShared(ctx, path)
+	return err
+}
+
+// DeleteShared deletes the file at path like Delete and reports whether it
+// was the last reference, so the file itself was removed
+func (s *DedupStorage) DeleteShared(ctx context.Context, path string) (bool, error) {
+	// the file is deleted while the blob is locked, so a save of the same
+	// content cannot reference it in between
+	removed := false
+	_, err := s.blobs.Release(ctx, path, func(ctx context.Context) error {
+		if err := s.files.Delete(ctx, path); err != nil {
+			return err
+		}
+		removed = true
+		return nil
+	})
+	if errors.Is(err, domainstorage.ErrBlobNotFound) {
+		if err := s.files.Delete(ctx, path); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to release blob: %w", err)
+	}
+	return removed, nil
+}
+
+func (s *DedupStorage) GetURL(path string) string {
+	return s.files.GetURL(path)
+}
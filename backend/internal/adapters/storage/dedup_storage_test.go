@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+)
+
+// memoryBlobs counts blob references in memory
+type memoryBlobs struct {
+	mu    sync.Mutex
+	blobs map[string]*domainstorage.Blob
+}
+
+func (m *memoryBlobs) AcquireExisting(ctx context.Context, hash string) (*domainstorage.Blob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blob, ok := m.blobs[hash]
+	if !ok {
+		return nil, domainstorage.ErrBlobNotFound
+	}
+	blob.Refs++
+	copied := *blob
+	return &copied, nil
+}
+
+func (m *memoryBlobs) Acquire(ctx context.Context, hash, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if blob, ok := m.blobs[hash]; ok {
+		blob.Refs++
+		return nil
+	}
+	m.blobs[hash] = &domainstorage.Blob{Hash: hash, Path: path, Refs: 1}
+	return nil
+}
+
+func (m *memoryBlobs) Release(ctx context.Context, path string, remove func(ctx context.Context) error) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for hash, blob := range m.blobs {
+		if blob.Path != path {
+			continue
+		}
+		if blob.Refs > 1 {
+			blob.Refs--
+			return blob.Refs, nil
+		}
+		if err := remove(ctx); err != nil {
+			return 0, err
+		}
+		delete(m.blobs, hash)
+		return 0, nil
+	}
+	return 0, domainstorage.ErrBlobNotFound
+}
+
+// hookedStorage runs beforeDelete before deleting a file
+type hookedStorage struct {
+	domainstorage.FileStorage
+	beforeDelete func(path string)
+}
+
+func (s *hookedStorage) Delete(ctx context.Context, path string) error {
+	s.beforeDelete(path)
+	return s.FileStorage.Delete(ctx, path)
+}
+
+func setupTestDedupStorage(t *testing.T) (*DedupStorage, *memoryBlobs, string) {
+	files, tmpDir := setupTestStorage(t)
+	blobs := &memoryBlobs{blobs: map[string]*domainstorage.Blob{}}
+	return NewDedupStorage(files, blobs), blobs, tmpDir
+}
+
+func TestDedupStorage_Save(t *testing.T) {
+	storage, blobs, tmpDir := setupTestDedupStorage(t)
+	ctx := context.Background()
+	data := []byte("test image data")
+	hash := domainstorage.ContentHash(data)
+
+	path, gotHash, err := storage.SaveHashed(ctx, "inv-1.JPG", data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("SaveHashed() error = %v", err)
+	}
+	if gotHash != hash {
+		t.Errorf("Expected hash %v, got %v", hash, gotHash)
+	}
+	expectedPath := filepath.Join(tmpDir, hash+".jpg")
+	if path != expectedPath {
+		t.Errorf("Expected path %v, got %v", expectedPath, path)
+	}
+
+	// the same content uploaded for another invoice shares the file
+	second, err := storage.Save(ctx, "inv-2.jpg", data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if second != path {
+		t.Errorf("Expected path %v, got %v", path, second)
+	}
+	if refs := blobs.blobs[hash].Refs; refs != 2 {
+		t.Errorf("Expected 2 references, got %d", refs)
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 stored file, got %d", len(entries))
+	}
+}
+
+func TestDedupStorage_Save_InvalidContentType(t *testing.T) {
+	storage, blobs, _ := setupTestDedupStorage(t)
+
+	if _, err := storage.Save(context.Background(), "test.txt", []byte("data"), "text/plain"); err == nil {
+		t.Error("Expected error for invalid content type")
+	}
+	if len(blobs.blobs) != 0 {
+		t.Errorf("Expected no blob, got %d", len(blobs.blobs))
+	}
+}
+
+func TestDedupStorage_Delete(t *testing.T) {
+	storage, blobs, _ := setupTestDedupStorage(t)
+	ctx := context.Background()
+
+	path, _ := storage.Save(ctx, "inv-1.jpg", []byte("shared"), "image/jpeg")
+	if _, err := storage.Save(ctx, "inv-2.jpg", []byte("shared"), "image/jpeg"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	removed, err := storage.DeleteShared(ctx, path)
+	if err != nil {
+		t.Fatalf("DeleteShared() error = %v", err)
+	}
+	if removed {
+		t.Error("Expected the file to be reported kept")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("File should be kept while referenced")
+	}
+
+	removed, err = storage.DeleteShared(ctx, path)
+	if err != nil {
+		t.Fatalf("DeleteShared() error = %v", err)
+	}
+	if !removed {
+		t.Error("Expected the file to be reported removed")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("File should be deleted with its last reference")
+	}
+	if len(blobs.blobs) != 0 {
+		t.Errorf("Expected no blob, got %d", len(blobs.blobs))
+	}
+}
+
+func TestDedupStorage_Delete_ConcurrentSave(t *testing.T) {
+	files, _ := setupTestStorage(t)
+	blobs := &memoryBlobs{blobs: map[string]*domainstorage.Blob{}}
+	hooked := &hookedStorage{FileStorage: files, beforeDelete: func(string) {}}
+	storage := NewDedupStorage(hooked, blobs)
+	ctx := context.Background()
+	data := []byte("shared")
+
+	path, err := storage.Save(ctx, "inv-1.jpg", data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// the same content is uploaded again while its last reference is deleted
+	saved := make(chan error, 1)
+	hooked.beforeDelete = func(string) {
+		go func() {
+			_, err := storage.Save(ctx, "inv-2.jpg", data, "image/jpeg")
+			saved <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := storage.Delete(ctx, path); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := <-saved; err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Error("File of the new upload should exist")
+	}
+	if blob := blobs.blobs[domainstorage.ContentHash(data)]; blob == nil || blob.Refs != 1 {
+		t.Errorf("Expected 1 reference, got %+v", blob)
+	}
+}
+
+func TestDedupStorage_Delete_Unreferenced(t *testing.T) {
+	storage, _, tmpDir := setupTestDedupStorage(t)
+
+	// saved before deduplication was enabled
+	path := filepath.Join(tmpDir, "legacy.jpg")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := storage.Delete(context.Background(), path); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("File should be deleted")
+	}
+}
+
+func TestSaveHashed(t *testing.T) {
+	files, tmpDir := setupTestStorage(t)
+	data := []byte("test image data")
+
+	path, hash, err := domainstorage.SaveHashed(context.Background(), files, "test.jpg", data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("SaveHashed() error = %v", err)
+	}
+	if path != filepath.Join(tmpDir, "test.jpg") {
+		t.Errorf("Expected path %v, got %v", filepath.Join(tmpDir, "test.jpg"), path)
+	}
+	if hash != domainstorage.ContentHash(data) {
+		t.Errorf("Expected hash %v, got %v", domainstorage.ContentHash(data), hash)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	files, _ := setupTestStorage(t)
+	ctx := context.Background()
+
+	path, err := files.Save(ctx, "test.jpg", []byte("data"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	removed, err := domainstorage.DeleteFile(ctx, files, path)
+	if err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if !removed {
+		t.Error("Expected the file to be reported removed")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("File should be deleted")
+	}
+}
@@ -29,7 +29,7 @@ func NewLocalStorage(basePath, baseURL string) (*LocalStorage, error) {
 }
 
 func (s *LocalStorage) Save(ctx context.Context, filename string, data []byte, contentType string) (string, error) {
-	if !strings.HasPrefix(contentType, "image/") {
+	if !domainstorage.IsSupportedContentType(contentType) {
 		return "", fmt.Errorf("invalid content type: %s", contentType)
 	}
 
@@ -80,6 +80,15 @@ func TestLocalStorage_Save_InvalidContentType(t *testing.T) {
 	}
 }
 
+func TestLocalStorage_Save_PDF(t *testing.T) {
+	storage, _ := setupTestStorage(t)
+	ctx := context.Background()
+
+	if _, err := storage.Save(ctx, "test.pdf", []byte("%PDF-1.4"), "application/pdf"); err != nil {
+		t.Errorf("Save() error = %v, PDF documents should be accepted", err)
+	}
+}
+
 func TestLocalStorage_Get(t *testing.T) {
 	storage, tmpDir := setupTestStorage(t)
 	ctx := context.Background()
@@ -154,6 +163,21 @@ func TestLocalStorage_GetURL(t *testing.T) {
 	}
 }
 
+func TestLocalStorage_GetURL_Relative(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage, err := NewLocalStorage(tmpDir, "")
+	if err != nil {
+		t.Fatalf("NewLocalStorage() error = %v", err)
+	}
+
+	url := storage.GetURL(filepath.Join(tmpDir, "test.jpg"))
+
+	expectedURL := "/uploads/test.jpg"
+	if url != expectedURL {
+		t.Errorf("Expected URL %v, got %v", expectedURL, url)
+	}
+}
+
 func TestLocalStorage_ImplementsInterface(t *testing.T) {
 	var _ domainstorage.FileStorage = (*LocalStorage)(nil)
 }
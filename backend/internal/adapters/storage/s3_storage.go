@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/s3"
+)
+
+var _ domainstorage.FileStorage = (*S3Storage)(nil)
+
+const (
+	DefaultS3URLExpiry = time.Hour
+	DefaultS3PartSize  = 8 << 20
+	// maxS3URLExpiry is the longest a presigned URL may be valid for
+	maxS3URLExpiry = 7 * 24 * time.Hour
+)
+
+type S3StorageConfig struct {
+	S3 s3.Config
+	// PublicEndpoint is the endpoint browsers reach the storage at, when it
+	// differs from S3.Endpoint, e.g. MinIO behind a docker network
+	PublicEndpoint string
+	// Prefix is prepended to the filenames to make the object keys
+	Prefix string
+	// URLExpiry is how long the URLs returned by GetURL are valid for
+	URLExpiry time.Duration
+	// PartSize is the size of the parts files larger than it are uploaded
+	// in
+	PartSize int
+}
+
+// S3Storage stores files as objects of an S3-compatible bucket. Paths are
+// the object keys, and files are served by presigned URLs so the bucket
+// stays private.
+type S3Storage struct {
+	client    *s3.Client
+	presigner *s3.Client
+	prefix    string
+	urlExpiry time.Duration
+	partSize  int
+	now       func() time.Time
+}
+
+func NewS3Storage(cfg S3StorageConfig) (*S3Storage, error) {
+	client, err := s3.New(cfg.S3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	presigner := client
+	if cfg.PublicEndpoint != "" {
+		public := cfg.S3
+		public.Endpoint = cfg.PublicEndpoint
+		if presigner, err = s3.New(public); err != nil {
+			return nil, fmt.Errorf("failed to create s3 client: %w", err)
+		}
+	}
+
+	if cfg.URLExpiry == 0 {
+		cfg.URLExpiry = DefaultS3URLExpiry
+	}
+	if cfg.URLExpiry < time.Second || cfg.URLExpiry > maxS3URLExpiry {
+		return nil, fmt.Errorf("s3 url expiry must be between 1s and %s", maxS3URLExpiry)
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < s3.MinPartSize {
+		return nil, fmt.Errorf("s3 part size must be at least %d bytes", s3.MinPartSize)
+	}
+
+	return &S3Storage{
+		client:    client,
+		presigner: presigner,
+		prefix:    strings.TrimPrefix(cfg.Prefix, "/"),
+		urlExpiry: cfg.URLExpiry,
+		partSize:  cfg.PartSize,
+		now:       time.Now,
+	}, nil
+}
+
+func (s *S3Storage) Save(ctx context.Context, filename string, data []byte, contentType string) (string, error) {
+	if !domainstorage.IsSupportedContentType(contentType) {
+		return "", fmt.Errorf("invalid content type: %s", contentType)
+	}
+
+	key := s.prefix + path.Base(filename)
+	var err error
+	if len(data) > s.partSize {
+		err = s.client.PutObjectMultipart(ctx, key, data, contentType, s.partSize)
+	} else {
+		err = s.client.PutObject(ctx, key, data, contentType)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, path string) ([]byte, error) {
+	data, err := s.client.GetObject(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, path string) error {
+	if err := s.client.DeleteObject(ctx, path); err != nil && !errors.Is(err, s3.ErrNotFound) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
+
+// GetURL returns a presigned URL of the file valid for at least half of the
+// URL expiry. Signing times are rounded down to the half so the URL of a file
+// stays the same for a while and browsers can cache it.
+func (s *S3Storage) GetURL(path string) string {
+	signedAt := s.now().UTC().Truncate(s.urlExpiry / 2)
+	return s.presigner.PresignGetObject(path, signedAt, s.urlExpiry)
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/s3"
+)
+
+// fakeBucket serves the objects of a path-style bucket from memory, assembling
+// multipart uploads in the order of their parts
+type fakeBucket struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	parts      map[string][][]byte
+	multiparts int
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/invoices/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		b.multiparts++
+		b.parts[key] = nil
+		fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>1</UploadId></InitiateMultipartUploadResult>")
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		b.parts[key] = append(b.parts[key], body)
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		b.objects[key] = bytes.Join(b.parts[key], nil)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodPut:
+		b.objects[key] = body
+	case r.Method == http.MethodGet:
+		object, ok := b.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>")
+			return
+		}
+		w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func setupTestS3Storage(t *testing.T) (*S3Storage, *fakeBucket) {
+	bucket := &fakeBucket{objects: map[string][]byte{}, parts: map[string][][]byte{}}
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+
+	storage, err := NewS3Storage(S3StorageConfig{
+		S3: s3.Config{
+			Endpoint:  server.URL,
+			Bucket:    "invoices",
+			AccessKey: "minioadmin",
+			SecretKey: "minioadmin",
+			PathStyle: true,
+		},
+		PublicEndpoint: "http://localhost:9000",
+		Prefix:         "uploads/",
+		PartSize:       s3.MinPartSize,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return storage, bucket
+}
+
+func TestNewS3Storage_Errors(t *testing.T) {
+	cfg := S3StorageConfig{S3: s3.Config{Endpoint: "http://localhost:9000", Bucket: "invoices", AccessKey: "key", SecretKey: "secret"}}
+
+	invalid := cfg
+	invalid.URLExpiry = 8 * 24 * time.Hour
+	if _, err := NewS3Storage(invalid); err == nil {
+		t.Error("Expected error for a URL expiry over 7 days")
+	}
+
+	invalid = cfg
+	invalid.PartSize = 1 << 20
+	if _, err := NewS3Storage(invalid); err == nil {
+		t.Error("Expected error for a part size under 5MB")
+	}
+
+	invalid = cfg
+	invalid.S3.Bucket = ""
+	if _, err := NewS3Storage(invalid); err == nil {
+		t.Error("Expected error for a missing bucket")
+	}
+}
+
+func TestS3Storage_SaveGetDelete(t *testing.T) {
+	storage, bucket := setupTestS3Storage(t)
+	ctx := context.Background()
+
+	path, err := storage.Save(ctx, "test.jpg", []byte("test image data"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if path != "uploads/test.jpg" {
+		t.Errorf("Expected path %v, got %v", "uploads/test.jpg", path)
+	}
+	if bucket.multiparts != 0 {
+		t.Errorf("Expected a single upload, got %d multipart uploads", bucket.multiparts)
+	}
+
+	got, err := storage.Get(ctx, path)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got) != "test image data" {
+		t.Errorf("Expected data %q, got %q", "test image data", got)
+	}
+
+	if err := storage.Delete(ctx, path); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := storage.Get(ctx, path); err == nil {
+		t.Error("Expected error for a deleted file")
+	}
+	if err := storage.Delete(ctx, path); err != nil {
+		t.Errorf("Delete() should not error for non-existent file, got %v", err)
+	}
+}
+
+func TestS3Storage_Save_Multipart(t *testing.T) {
+	storage, bucket := setupTestS3Storage(t)
+
+	data := bytes.Repeat([]byte("pdf"), s3.MinPartSize)
+	path, err := storage.Save(context.Background(), "scan.pdf", data, "application/pdf")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if bucket.multiparts != 1 {
+		t.Errorf("Expected 1 multipart upload, got %d", bucket.multiparts)
+	}
+	if len(bucket.parts[path]) != 3 {
+		t.Errorf("Expected 3 parts, got %d", len(bucket.parts[path]))
+	}
+	if !bytes.Equal(bucket.objects[path], data) {
+		t.Error("Expected the parts to make up the file")
+	}
+}
+
+func TestS3Storage_Save_InvalidContentType(t *testing.T) {
+	storage, _ := setupTestS3Storage(t)
+
+	if _, err := storage.Save(context.Background(), "test.txt", []byte("data"), "text/plain"); err == nil {
+		t.Error("Expected error for invalid content type")
+	}
+}
+
+func TestS3Storage_GetURL(t *testing.T) {
+	storage, _ := setupTestS3Storage(t)
+	now := time.Date(2026, 10, 16, 9, 10, 0, 0, time.UTC)
+	storage.now = func() time.Time { return now }
+
+	first := storage.GetURL("uploads/test.jpg")
+	u, err := url.Parse(first)
+	if err != nil {
+		t.Fatalf("GetURL() returned an invalid URL: %v", err)
+	}
+	if u.Host != "localhost:9000" || u.Path != "/invoices/uploads/test.jpg" {
+		t.Errorf("Expected a URL of the public endpoint, got %v", first)
+	}
+	if date := u.Query().Get("X-Amz-Date"); date != "20261016T090000Z" {
+		t.Errorf("Expected the signing time rounded down to the half hour, got %v", date)
+	}
+
+	// the URL is the same until the next half of the expiry
+	now = now.Add(15 * time.Minute)
+	if second := storage.GetURL("uploads/test.jpg"); second != first {
+		t.Errorf("Expected URL %v, got %v", first, second)
+	}
+	now = now.Add(10 * time.Minute)
+	if third := storage.GetURL("uploads/test.jpg"); third == first {
+		t.Error("Expected a new URL after half of the expiry")
+	}
+}
+
+func TestS3Storage_ImplementsInterface(t *testing.T) {
+	var _ domainstorage.FileStorage = (*S3Storage)(nil)
+}
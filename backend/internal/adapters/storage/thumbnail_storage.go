@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/imageproc"
+)
+
+var _ domainstorage.ThumbnailStorage = (*ThumbnailStorage)(nil)
+
+const thumbnailQuality = 80
+
+// ThumbnailStorage stores the thumbnails of an image next to it in the same
+// file storage, as <name>-thumb<size>.jpg. Sizes are the longest side of the
+// thumbnail in pixels.
+type ThumbnailStorage struct {
+	files     domainstorage.FileStorage
+	sizes     []int
+	pipelines map[int]*imageproc.Pipeline
+}
+
+func NewThumbnailStorage(files domainstorage.FileStorage, sizes []int) (*ThumbnailStorage, error) {
+	if len(sizes) == 0 {
+		return nil, errors.New("at least one thumbnail size is required")
+	}
+
+	s := &ThumbnailStorage{
+		files:     files,
+		sizes:     slices.Sorted(slices.Values(sizes)),
+		pipelines: make(map[int]*imageproc.Pipeline, len(sizes)),
+	}
+	for _, size := range s.sizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid thumbnail size %d", size)
+		}
+		pipeline, err := imageproc.NewPipeline(imageproc.Config{
+			Steps:        []string{imageproc.StepOrient, imageproc.StepDownscale},
+			MaxDimension: size,
+			Quality:      thumbnailQuality,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.pipelines[size] = pipeline
+	}
+	return s, nil
+}
+
+func (s *ThumbnailStorage) Generate(ctx context.Context, path string) error {
+	data, err := s.source(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range s.sizes {
+		if _, err := s.generate(ctx, path, data, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ThumbnailStorage) Get(ctx context.Context, path string, size int) ([]byte, error) {
+	if isPDFFile(path) {
+		return nil, domainstorage.ErrNoThumbnail
+	}
+
+	size = s.fit(size)
+	if thumbnail, err := s.files.Get(ctx, thumbnailPath(path, size)); err == nil {
+		return thumbnail, nil
+	}
+
+	// images stored before thumbnails existed get theirs on first request
+	data, err := s.source(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return s.generate(ctx, path, data, size)
+}
+
+func (s *ThumbnailStorage) Delete(ctx context.Context, path string) error {
+	var errs []error
+	for _, size := range s.sizes {
+		if err := s.files.Delete(ctx, thumbnailPath(path, size)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fit returns the smallest size of at least size, or the largest size
+func (s *ThumbnailStorage) fit(size int) int {
+	for _, candidate := range s.sizes {
+		if candidate >= size {
+			return candidate
+		}
+	}
+	return s.sizes[len(s.sizes)-1]
+}
+
+func (s *ThumbnailStorage) source(ctx context.Context, path string) ([]byte, error) {
+	if isPDFFile(path) {
+		return nil, domainstorage.ErrNoThumbnail
+	}
+	return s.files.Get(ctx, path)
+}
+
+func (s *ThumbnailStorage) generate(ctx context.Context, path string, data []byte, size int) ([]byte, error) {
+	thumbnail, err := s.pipelines[size].Process(data)
+	if err != nil {
+		if errors.Is(err, imageproc.ErrUnsupportedFormat) {
+			return nil, domainstorage.ErrNoThumbnail
+		}
+		return nil, fmt.Errorf("failed to make thumbnail: %w", err)
+	}
+
+	if _, err := s.files.Save(ctx, filepath.Base(thumbnailPath(path, size)), thumbnail, "image/jpeg"); err != nil {
+		return nil, fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+	return thumbnail, nil
+}
+
+// thumbnailPath is where the thumbnail of the image at path is stored in size
+func thumbnailPath(path string, size int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-thumb%d.jpg", strings.TrimSuffix(path, ext), size)
+}
+
+func isPDFFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pdf")
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+)
+
+func setupTestThumbnails(t *testing.T, sizes ...int) (*ThumbnailStorage, *LocalStorage, string) {
+	files, tmpDir := setupTestStorage(t)
+	thumbnails, err := NewThumbnailStorage(files, sizes)
+	if err != nil {
+		t.Fatalf("Failed to create thumbnail storage: %v", err)
+	}
+	return thumbnails, files, tmpDir
+}
+
+func saveTestImage(t *testing.T, files *LocalStorage, filename string, width, height int) string {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	img.Set(0, 0, color.Black)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	path, err := files.Save(context.Background(), filename, buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatalf("Failed to save test image: %v", err)
+	}
+	return path
+}
+
+func decodeSize(t *testing.T, data []byte) image.Point {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a JPEG: %v", err)
+	}
+	return img.Bounds().Size()
+}
+
+func TestThumbnailStorage_Generate(t *testing.T) {
+	thumbnails, files, tmpDir := setupTestThumbnails(t, 256, 64)
+	ctx := context.Background()
+	path := saveTestImage(t, files, "inv-1.png", 800, 400)
+
+	if err := thumbnails.Generate(ctx, path); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for name, want := range map[string]image.Point{
+		"inv-1-thumb64.jpg":  {64, 32},
+		"inv-1-thumb256.jpg": {256, 128},
+	} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("Expected thumbnail %s to be stored: %v", name, err)
+		}
+		if got := decodeSize(t, data); got != want {
+			t.Errorf("Expected %s to be %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestThumbnailStorage_Get(t *testing.T) {
+	thumbnails, files, tmpDir := setupTestThumbnails(t, 64, 256)
+	ctx := context.Background()
+	path := saveTestImage(t, files, "inv-1.png", 400, 800)
+
+	// images stored without thumbnails get them on demand
+	data, err := thumbnails.Get(ctx, path, 100)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := decodeSize(t, data); got != (image.Point{128, 256}) {
+		t.Errorf("Expected the smallest size of at least 100, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "inv-1-thumb256.jpg")); err != nil {
+		t.Errorf("Expected the generated thumbnail to be stored: %v", err)
+	}
+
+	data, err = thumbnails.Get(ctx, path, 0)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := decodeSize(t, data); got != (image.Point{32, 64}) {
+		t.Errorf("Expected the smallest size by default, got %v", got)
+	}
+
+	data, err = thumbnails.Get(ctx, path, 4000)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := decodeSize(t, data); got != (image.Point{128, 256}) {
+		t.Errorf("Expected the largest size, got %v", got)
+	}
+}
+
+func TestThumbnailStorage_Get_NoThumbnail(t *testing.T) {
+	thumbnails, files, _ := setupTestThumbnails(t, 64)
+	ctx := context.Background()
+
+	pdfPath, err := files.Save(ctx, "inv-1.pdf", []byte("%PDF-1.4"), "application/pdf")
+	if err != nil {
+		t.Fatalf("Failed to save test document: %v", err)
+	}
+	if _, err := thumbnails.Get(ctx, pdfPath, 64); !errors.Is(err, domainstorage.ErrNoThumbnail) {
+		t.Errorf("Expected ErrNoThumbnail for a PDF document, got %v", err)
+	}
+	if err := thumbnails.Generate(ctx, pdfPath); !errors.Is(err, domainstorage.ErrNoThumbnail) {
+		t.Errorf("Expected ErrNoThumbnail for a PDF document, got %v", err)
+	}
+
+	heicPath, err := files.Save(ctx, "inv-2.heic", []byte("not decodable"), "image/heic")
+	if err != nil {
+		t.Fatalf("Failed to save test image: %v", err)
+	}
+	if _, err := thumbnails.Get(ctx, heicPath, 64); !errors.Is(err, domainstorage.ErrNoThumbnail) {
+		t.Errorf("Expected ErrNoThumbnail for an undecodable image, got %v", err)
+	}
+}
+
+func TestThumbnailStorage_Delete(t *testing.T) {
+	thumbnails, files, tmpDir := setupTestThumbnails(t, 64, 256)
+	ctx := context.Background()
+	path := saveTestImage(t, files, "inv-1.png", 300, 300)
+
+	if err := thumbnails.Generate(ctx, path); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if err := thumbnails.Delete(ctx, path); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	for _, name := range []string{"inv-1-thumb64.jpg", "inv-1-thumb256.jpg"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected thumbnail %s to be deleted", name)
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the image itself to be kept: %v", err)
+	}
+}
+
+func TestNewThumbnailStorage_InvalidSizes(t *testing.T) {
+	files, _ := setupTestStorage(t)
+	if _, err := NewThumbnailStorage(files, nil); err == nil {
+		t.Error("Expected error without sizes")
+	}
+	if _, err := NewThumbnailStorage(files, []int{128, 0}); err == nil {
+		t.Error("Expected error for a size of 0")
+	}
+}
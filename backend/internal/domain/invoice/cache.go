@@ -0,0 +1,58 @@
+package invoice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// CacheKey identifies an extraction result: the same image extracted by the
+// same provider, model and prompt version gives the same result
+type CacheKey struct {
+	// ImageHash is the hex SHA-256 of the image bytes, or of the image hashes
+	// one per line for several images
+	ImageHash     string
+	Provider      string
+	Model         string
+	PromptVersion string
+	// Variant covers the other inputs of the prompt, e.g. tenant and custom
+	// fields
+	Variant string
+}
+
+// String returns a fixed length digest of the key
+func (k CacheKey) String() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{k.ImageHash, k.Provider, k.Model, k.PromptVersion, k.Variant}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// CacheEntry is a cached extraction result
+type CacheEntry struct {
+	Key       CacheKey
+	Data      ExtractedData
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (e *CacheEntry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// CacheInfo tells whether extracted data was served from the cache
+type CacheInfo struct {
+	Hit       bool       `json:"hit"`
+	ImageHash string     `json:"image_hash"`
+	CachedAt  *time.Time `json:"cached_at,omitempty"`
+}
+
+// ResultCache stores extraction results
+type ResultCache interface {
+	// Get returns the entry of key, or nil when there is none or it expired
+	Get(ctx context.Context, key CacheKey) (*CacheEntry, error)
+	Set(ctx context.Context, entry *CacheEntry) error
+	// InvalidateImage removes the entries of an image, for every provider,
+	// model and prompt version, and returns how many were removed
+	InvalidateImage(ctx context.Context, imageHash string) (int, error)
+}
@@ -0,0 +1,40 @@
+package invoice
+
+import "errors"
+
+// ExtractionError classifies an extraction failure so callers can decide
+// whether trying again may succeed
+type ExtractionError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *ExtractionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExtractionError) Unwrap() error {
+	return e.Err
+}
+
+// NewRetryableError marks a transient failure such as a timeout, a rate limit
+// or a truncated model response
+func NewRetryableError(err error) error {
+	return &ExtractionError{Err: err, Retryable: true}
+}
+
+// NewPermanentError marks a failure that will not go away on retry such as an
+// empty image or an unsupported MIME type
+func NewPermanentError(err error) error {
+	return &ExtractionError{Err: err, Retryable: false}
+}
+
+// IsRetryable reports whether err is worth retrying. Errors that were not
+// classified by the extraction service are treated as transient.
+func IsRetryable(err error) bool {
+	var extractionErr *ExtractionError
+	if errors.As(err, &extractionErr) {
+		return extractionErr.Retryable
+	}
+	return true
+}
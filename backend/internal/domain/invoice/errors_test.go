@@ -0,0 +1,28 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Retryable", NewRetryableError(errors.New("rate limited")), true},
+		{"Permanent", NewPermanentError(errors.New("invalid image type")), false},
+		{"WrappedPermanent", fmt.Errorf("extract: %w", NewPermanentError(errors.New("empty image"))), false},
+		{"Unclassified", errors.New("connection reset"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.expected {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package invoice
+
+import (
+	"encoding/json"
+
+	"invoice-scan/backend/internal/domain/job"
+)
+
+const JobTypeExtraction job.Type = "invoice.extraction"
+
+// ExtractionJobPayload is the payload of a JobTypeExtraction job. The pages
+// themselves are not part of the payload, they are reloaded from storage by the
+// worker.
+type ExtractionJobPayload struct {
+	InvoiceID ID `json:"invoice_id"`
+	ExtractOptions
+}
+
+func NewExtractionJob(id job.ID, payload ExtractionJobPayload) (*job.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return job.New(id, JobTypeExtraction, payload.InvoiceID.String(), data), nil
+}
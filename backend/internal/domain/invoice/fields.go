@@ -0,0 +1,56 @@
+package invoice
+
+import (
+	"encoding/json"
+	"time"
+
+	"invoice-scan/backend/pkg/money"
+)
+
+// Fields are the invoice fields ExtractedData is normalized into, whatever
+// language its keys are in. Fields that were not found are empty or nil.
+type Fields struct {
+	InvoiceNumber string     `json:"invoice_number,omitempty"`
+	IssueDate     *time.Time `json:"issue_date,omitempty"`
+	SellerName    string     `json:"seller_name,omitempty"`
+	SellerTaxCode string     `json:"seller_tax_code,omitempty"`
+	BuyerName     string     `json:"buyer_name,omitempty"`
+	BuyerTaxCode  string     `json:"buyer_tax_code,omitempty"`
+	// Currency is an ISO 4217 code, e.g. VND
+	Currency string         `json:"currency,omitempty"`
+	Subtotal *money.Decimal `json:"subtotal,omitempty"`
+	// VATRate is the VAT percentage, e.g. 10 for 10%
+	VATRate *money.Decimal `json:"vat_rate,omitempty"`
+	VAT     *money.Decimal `json:"vat,omitempty"`
+	Total   *money.Decimal `json:"total,omitempty"`
+	// AmountInWords is the total as printed in words, e.g. "Một triệu hai
+	// trăm nghìn đồng"
+	AmountInWords string     `json:"amount_in_words,omitempty"`
+	LineItems     []LineItem `json:"line_items,omitempty"`
+}
+
+// LineItem is a row of the invoice table
+type LineItem struct {
+	Description string         `json:"description,omitempty"`
+	Unit        string         `json:"unit,omitempty"`
+	Quantity    *money.Decimal `json:"quantity,omitempty"`
+	UnitPrice   *money.Decimal `json:"unit_price,omitempty"`
+	Amount      *money.Decimal `json:"amount,omitempty"`
+}
+
+// IsZero reports whether no field was found
+func (f Fields) IsZero() bool {
+	return f.InvoiceNumber == "" && f.IssueDate == nil && f.SellerName == "" && f.SellerTaxCode == "" &&
+		f.BuyerName == "" && f.BuyerTaxCode == "" && f.Currency == "" && f.Subtotal == nil &&
+		f.VATRate == nil && f.VAT == nil && f.Total == nil && f.AmountInWords == "" && len(f.LineItems) == 0
+}
+
+// NormalizeJSON normalizes extracted data stored as JSON. Data that is not an
+// ExtractedData has no fields.
+func NormalizeJSON(data json.RawMessage) Fields {
+	var extracted ExtractedData
+	if len(data) == 0 || json.Unmarshal(data, &extracted) != nil {
+		return Fields{}
+	}
+	return Normalize(extracted)
+}
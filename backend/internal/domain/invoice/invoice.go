@@ -14,10 +14,14 @@ func (id ID) String() string {
 type Status string
 
 const (
+	// StatusDraft invoices wait for more pages before extraction is started
+	StatusDraft      Status = "draft"
 	StatusPending    Status = "pending"
 	StatusProcessing Status = "processing"
 	StatusCompleted  Status = "completed"
-	StatusFailed     Status = "failed"
+	// StatusNeedsReview invoices were extracted with findings to review
+	StatusNeedsReview Status = "needs_review"
+	StatusFailed      Status = "failed"
 )
 
 func (s Status) String() string {
@@ -26,7 +30,7 @@ func (s Status) String() string {
 
 func (s Status) IsValid() bool {
 	switch s {
-	case StatusPending, StatusProcessing, StatusCompleted, StatusFailed:
+	case StatusDraft, StatusPending, StatusProcessing, StatusCompleted, StatusNeedsReview, StatusFailed:
 		return true
 	}
 	return false
@@ -34,13 +38,26 @@ func (s Status) IsValid() bool {
 
 type (
 	Invoice struct {
-		ID            ID
-		Status        Status
+		ID     ID
+		Status Status
+		// TenantID is the tenant that uploaded the invoice, empty for none
+		TenantID string
+		// ImagePath is the first page of the invoice
 		ImagePath     string
 		ExtractedData json.RawMessage
-		ErrorMessage  *string
-		CreatedAt     time.Time
-		UpdatedAt     time.Time
+		// Fields are normalized from ExtractedData by SetExtractedData
+		Fields Fields
+		// Findings are the inconsistencies Validate found in Fields
+		Findings []Finding
+		// PromptVersion is the prompt version ExtractedData was extracted with
+		PromptVersion string
+		// PageCount is the number of pages of the invoice: its images and the
+		// pages of its PDF documents
+		PageCount          int
+		ErrorMessage       *string
+		ExtractionAttempts int
+		CreatedAt          time.Time
+		UpdatedAt          time.Time
 	}
 
 	Invoices []*Invoice
@@ -52,19 +69,81 @@ func New(id ID, imagePath string) *Invoice {
 		ID:        id,
 		Status:    StatusPending,
 		ImagePath: imagePath,
+		PageCount: 1,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
 
+// NewDraft creates an invoice that takes more pages until its extraction is
+// started
+func NewDraft(id ID, imagePath string) *Invoice {
+	inv := New(id, imagePath)
+	inv.Status = StatusDraft
+	return inv
+}
+
+// AcceptsPages reports whether pages may still be added, which is only until
+// extraction is started
+func (i *Invoice) AcceptsPages() bool {
+	return i.Status == StatusDraft
+}
+
+// MarkPending puts the invoice back in line for extraction
+func (i *Invoice) MarkPending() {
+	i.Status = StatusPending
+	i.UpdatedAt = time.Now()
+}
+
+// IsExtracting reports whether an extraction is queued or running
+func (i *Invoice) IsExtracting() bool {
+	return i.Status == StatusPending || i.Status == StatusProcessing
+}
+
+// MarkProcessing starts a new extraction attempt
 func (i *Invoice) MarkProcessing() {
 	i.Status = StatusProcessing
+	i.ExtractionAttempts++
 	i.UpdatedAt = time.Now()
 }
 
-func (i *Invoice) MarkCompleted(data json.RawMessage) {
+// IsExtracted reports whether the invoice has extracted data to show, with or
+// without findings
+func (i *Invoice) IsExtracted() bool {
+	return i.Status == StatusCompleted || i.Status == StatusNeedsReview
+}
+
+// MarkCompleted stores the extracted data. The invoice needs review when its
+// data has findings. pageCount is the number of pages the data was extracted
+// from, below 1 for a single image.
+func (i *Invoice) MarkCompleted(data json.RawMessage, promptVersion string, pageCount int) {
 	i.Status = StatusCompleted
+	i.SetExtractedData(data)
+	i.PromptVersion = promptVersion
+	i.PageCount = max(pageCount, 1)
+	i.ErrorMessage = nil
+	i.UpdatedAt = time.Now()
+}
+
+// SetExtractedData replaces the extracted data, the fields normalized from it
+// and their findings. An extracted invoice needs review as long as it has
+// findings, so editing the data can flag or clear it.
+func (i *Invoice) SetExtractedData(data json.RawMessage) {
 	i.ExtractedData = data
+	i.Fields = NormalizeJSON(data)
+	i.Findings = Validate(i.Fields)
+	if i.IsExtracted() {
+		i.Status = StatusCompleted
+		if len(i.Findings) > 0 {
+			i.Status = StatusNeedsReview
+		}
+	}
+}
+
+// MarkRetryScheduled records the error of a failed attempt that will be retried
+func (i *Invoice) MarkRetryScheduled(errMsg string) {
+	i.Status = StatusPending
+	i.ErrorMessage = &errMsg
 	i.UpdatedAt = time.Now()
 }
 
@@ -78,16 +157,65 @@ type KeyValuePair struct {
 	Key        string   `json:"key"`
 	Value      string   `json:"value"`
 	Confidence *float64 `json:"confidence,omitempty"`
+	// Provider names the extraction provider that produced the pair when
+	// several providers are combined
+	Provider string `json:"provider,omitempty" schema:"-"`
+	// Disagreements holds the other values extracted for the same key when
+	// providers did not agree
+	Disagreements []Candidate `json:"disagreements,omitempty" schema:"-"`
+	// BoundingBox is where the pair was read, for providers that locate it
+	BoundingBox *BoundingBox `json:"bounding_box,omitempty"`
+}
+
+// BoundingBox is the region of a page a value was read from. Coordinates are
+// fractions of the page width and height, from its top left corner.
+type BoundingBox struct {
+	// Page is the page of the invoice the region is on, from 1
+	Page   int     `json:"page,omitempty"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Candidate is a value one provider extracted for a key
+type Candidate struct {
+	Value      string   `json:"value"`
+	Confidence *float64 `json:"confidence,omitempty"`
+	Provider   string   `json:"provider"`
 }
 
 type TableData struct {
 	Headers []string   `json:"headers"`
 	Rows    [][]string `json:"rows"`
+	// CellBoxes holds the bounding box of every cell of Rows, row by row, nil
+	// where a cell was not located
+	CellBoxes [][]*BoundingBox `json:"cell_boxes,omitempty"`
 }
 
+// ExtractedData is the result of an extraction. Fields tagged schema:"-" are
+// filled in by the service rather than asked from the model.
 type ExtractedData struct {
 	KeyValuePairs []KeyValuePair `json:"key_value_pairs"`
 	Table         TableData      `json:"table"`
 	Summary       []KeyValuePair `json:"summary"`
 	Confidence    *float64       `json:"confidence,omitempty"`
+	// SellerTaxCode and BuyerTaxCode are the valid tax codes found in the
+	// pairs by CheckTaxCodes
+	SellerTaxCode string `json:"seller_tax_code,omitempty" schema:"-"`
+	BuyerTaxCode  string `json:"buyer_tax_code,omitempty" schema:"-"`
+	// Provider names the extraction provider that produced the table and the
+	// overall confidence when several providers are combined
+	Provider string `json:"provider,omitempty" schema:"-"`
+	// PromptVersion is the prompt version the data was extracted with, empty
+	// for providers that do not use prompts
+	PromptVersion string `json:"prompt_version,omitempty" schema:"-"`
+	// PageCount is the number of pages the data was extracted from: the images
+	// and the pages of PDF documents
+	PageCount int `json:"page_count,omitempty" schema:"-"`
+	// Cache is set when extraction went through the result cache
+	Cache *CacheInfo `json:"cache,omitempty" schema:"-"`
+	// Usage lists the provider calls made for the extraction. It is recorded
+	// as extraction attempts rather than stored with the data.
+	Usage []Usage `json:"usage,omitempty" schema:"-"`
 }
@@ -19,9 +19,11 @@ func TestStatus_String(t *testing.T) {
 		status   Status
 		expected string
 	}{
+		{"Draft", StatusDraft, "draft"},
 		{"Pending", StatusPending, "pending"},
 		{"Processing", StatusProcessing, "processing"},
 		{"Completed", StatusCompleted, "completed"},
+		{"NeedsReview", StatusNeedsReview, "needs_review"},
 		{"Failed", StatusFailed, "failed"},
 	}
 
@@ -40,9 +42,11 @@ func TestStatus_IsValid(t *testing.T) {
 		status   Status
 		expected bool
 	}{
+		{"Draft", StatusDraft, true},
 		{"Pending", StatusPending, true},
 		{"Processing", StatusProcessing, true},
 		{"Completed", StatusCompleted, true},
+		{"NeedsReview", StatusNeedsReview, true},
 		{"Failed", StatusFailed, true},
 		{"Invalid", Status("invalid"), false},
 	}
@@ -78,6 +82,22 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewDraft(t *testing.T) {
+	inv := NewDraft(ID("01HXYZ123ABC456DEF789GHI"), "/uploads/test.jpg")
+
+	if inv.Status != StatusDraft {
+		t.Errorf("Expected status %v, got %v", StatusDraft, inv.Status)
+	}
+	if !inv.AcceptsPages() {
+		t.Error("Draft invoice should accept pages")
+	}
+
+	inv.MarkPending()
+	if inv.AcceptsPages() {
+		t.Error("Pending invoice should not accept pages")
+	}
+}
+
 func TestInvoice_MarkProcessing(t *testing.T) {
 	id := ID("01HXYZ123ABC456DEF789GHI")
 	inv := New(id, "/uploads/test.jpg")
@@ -89,6 +109,9 @@ func TestInvoice_MarkProcessing(t *testing.T) {
 	if inv.Status != StatusProcessing {
 		t.Errorf("Expected status %v, got %v", StatusProcessing, inv.Status)
 	}
+	if inv.ExtractionAttempts != 1 {
+		t.Errorf("Expected 1 extraction attempt, got %d", inv.ExtractionAttempts)
+	}
 	if !inv.UpdatedAt.After(beforeUpdate) {
 		t.Error("UpdatedAt should be updated")
 	}
@@ -99,7 +122,7 @@ func TestInvoice_MarkCompleted(t *testing.T) {
 	inv := New(id, "/uploads/test.jpg")
 	data := json.RawMessage(`{"key": "value"}`)
 
-	inv.MarkCompleted(data)
+	inv.MarkCompleted(data, "v2", 3)
 
 	if inv.Status != StatusCompleted {
 		t.Errorf("Expected status %v, got %v", StatusCompleted, inv.Status)
@@ -107,6 +130,12 @@ func TestInvoice_MarkCompleted(t *testing.T) {
 	if string(inv.ExtractedData) != string(data) {
 		t.Errorf("Expected extracted data %v, got %v", data, inv.ExtractedData)
 	}
+	if inv.PromptVersion != "v2" {
+		t.Errorf("Expected prompt version v2, got %v", inv.PromptVersion)
+	}
+	if inv.PageCount != 3 {
+		t.Errorf("Expected page count 3, got %v", inv.PageCount)
+	}
 }
 
 func TestInvoice_MarkFailed(t *testing.T) {
@@ -124,3 +153,29 @@ func TestInvoice_MarkFailed(t *testing.T) {
 	}
 }
 
+func TestInvoice_MarkRetryScheduled(t *testing.T) {
+	id := ID("01HXYZ123ABC456DEF789GHI")
+	inv := New(id, "/uploads/test.jpg")
+	inv.MarkProcessing()
+
+	inv.MarkRetryScheduled("timeout")
+
+	if inv.Status != StatusPending {
+		t.Errorf("Expected status %v, got %v", StatusPending, inv.Status)
+	}
+	if inv.ErrorMessage == nil || *inv.ErrorMessage != "timeout" {
+		t.Errorf("Expected error message timeout, got %v", inv.ErrorMessage)
+	}
+}
+
+func TestInvoice_MarkCompleted_ClearsError(t *testing.T) {
+	id := ID("01HXYZ123ABC456DEF789GHI")
+	inv := New(id, "/uploads/test.jpg")
+	inv.MarkRetryScheduled("timeout")
+
+	inv.MarkCompleted(json.RawMessage(`{}`), "v1", 0)
+
+	if inv.ErrorMessage != nil {
+		t.Errorf("Expected error message to be cleared, got %v", *inv.ErrorMessage)
+	}
+}
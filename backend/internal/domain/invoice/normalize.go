@@ -0,0 +1,452 @@
+package invoice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"invoice-scan/backend/pkg/money"
+	"invoice-scan/backend/pkg/taxcode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type field int
+
+const (
+	fieldInvoiceNumber field = iota
+	fieldIssueDate
+	fieldSellerName
+	fieldSellerTaxCode
+	fieldBuyerName
+	fieldBuyerTaxCode
+	fieldCurrency
+	fieldSubtotal
+	fieldVATRate
+	fieldVAT
+	fieldTotal
+	fieldAmountInWords
+)
+
+type column int
+
+const (
+	columnDescription column = iota
+	columnUnit
+	columnQuantity
+	columnUnitPrice
+	columnAmount
+)
+
+// synonym is a key a field or column is found under, folded by foldKey.
+// Keys containing a synonym as whole words match it, exact synonyms only
+// match the whole key. The longest synonym found wins, so "tong tien hang"
+// is the subtotal rather than the total.
+type synonym[T any] struct {
+	target T
+	phrase string
+	exact  bool
+}
+
+var fieldSynonyms = []synonym[field]{
+	{target: fieldInvoiceNumber, phrase: "so hoa don"},
+	{target: fieldInvoiceNumber, phrase: "so hd"},
+	{target: fieldInvoiceNumber, phrase: "invoice number"},
+	{target: fieldInvoiceNumber, phrase: "invoice no"},
+	{target: fieldInvoiceNumber, phrase: "so", exact: true},
+	{target: fieldInvoiceNumber, phrase: "no", exact: true},
+
+	{target: fieldIssueDate, phrase: "ngay lap"},
+	{target: fieldIssueDate, phrase: "ngay hoa don"},
+	{target: fieldIssueDate, phrase: "ngay xuat hoa don"},
+	{target: fieldIssueDate, phrase: "ngay phat hanh"},
+	{target: fieldIssueDate, phrase: "invoice date"},
+	{target: fieldIssueDate, phrase: "issue date"},
+	{target: fieldIssueDate, phrase: "date of issue"},
+	{target: fieldIssueDate, phrase: "ngay", exact: true},
+	{target: fieldIssueDate, phrase: "date", exact: true},
+
+	{target: fieldSellerName, phrase: "don vi ban hang"},
+	{target: fieldSellerName, phrase: "don vi ban"},
+	{target: fieldSellerName, phrase: "ten nguoi ban"},
+	{target: fieldSellerName, phrase: "nguoi ban"},
+	{target: fieldSellerName, phrase: "ten cong ty"},
+	{target: fieldSellerName, phrase: "seller"},
+	{target: fieldSellerName, phrase: "vendor"},
+	{target: fieldSellerName, phrase: "supplier"},
+	{target: fieldSellerName, phrase: "company name"},
+
+	{target: fieldSellerTaxCode, phrase: "ma so thue"},
+	{target: fieldSellerTaxCode, phrase: "ma so thue nguoi ban"},
+	{target: fieldSellerTaxCode, phrase: "mst"},
+	{target: fieldSellerTaxCode, phrase: "tax code"},
+	{target: fieldSellerTaxCode, phrase: "tax id"},
+	{target: fieldSellerTaxCode, phrase: "seller tax code"},
+
+	{target: fieldBuyerName, phrase: "don vi mua hang"},
+	{target: fieldBuyerName, phrase: "don vi mua"},
+	{target: fieldBuyerName, phrase: "ten nguoi mua"},
+	{target: fieldBuyerName, phrase: "ho ten nguoi mua"},
+	{target: fieldBuyerName, phrase: "nguoi mua hang"},
+	{target: fieldBuyerName, phrase: "nguoi mua"},
+	{target: fieldBuyerName, phrase: "ten khach hang"},
+	{target: fieldBuyerName, phrase: "khach hang"},
+	{target: fieldBuyerName, phrase: "buyer"},
+	{target: fieldBuyerName, phrase: "customer"},
+	{target: fieldBuyerName, phrase: "bill to"},
+	{target: fieldBuyerName, phrase: "sold to"},
+
+	{target: fieldBuyerTaxCode, phrase: "ma so thue nguoi mua"},
+	{target: fieldBuyerTaxCode, phrase: "ma so thue khach hang"},
+	{target: fieldBuyerTaxCode, phrase: "mst nguoi mua"},
+	{target: fieldBuyerTaxCode, phrase: "buyer tax code"},
+	{target: fieldBuyerTaxCode, phrase: "customer tax code"},
+
+	{target: fieldCurrency, phrase: "don vi tien te"},
+	{target: fieldCurrency, phrase: "loai tien"},
+	{target: fieldCurrency, phrase: "tien te"},
+	{target: fieldCurrency, phrase: "currency"},
+
+	{target: fieldSubtotal, phrase: "cong tien hang"},
+	{target: fieldSubtotal, phrase: "tong tien hang"},
+	{target: fieldSubtotal, phrase: "tien hang"},
+	{target: fieldSubtotal, phrase: "thanh tien truoc thue"},
+	{target: fieldSubtotal, phrase: "tien truoc thue"},
+	{target: fieldSubtotal, phrase: "subtotal"},
+	{target: fieldSubtotal, phrase: "sub total"},
+	{target: fieldSubtotal, phrase: "total before tax"},
+	{target: fieldSubtotal, phrase: "amount before tax"},
+	{target: fieldSubtotal, phrase: "net amount"},
+
+	{target: fieldVATRate, phrase: "thue suat"},
+	{target: fieldVATRate, phrase: "thue suat gtgt"},
+	{target: fieldVATRate, phrase: "vat rate"},
+	{target: fieldVATRate, phrase: "tax rate"},
+
+	{target: fieldVAT, phrase: "tong tien thue"},
+	{target: fieldVAT, phrase: "tien thue gtgt"},
+	{target: fieldVAT, phrase: "thue gtgt"},
+	{target: fieldVAT, phrase: "tien thue"},
+	{target: fieldVAT, phrase: "vat amount"},
+	{target: fieldVAT, phrase: "tax amount"},
+	{target: fieldVAT, phrase: "total vat"},
+	{target: fieldVAT, phrase: "total tax"},
+	{target: fieldVAT, phrase: "vat"},
+	{target: fieldVAT, phrase: "tax", exact: true},
+
+	{target: fieldTotal, phrase: "tong cong tien thanh toan"},
+	{target: fieldTotal, phrase: "tong tien thanh toan"},
+	{target: fieldTotal, phrase: "tong thanh toan"},
+	{target: fieldTotal, phrase: "tong cong"},
+	{target: fieldTotal, phrase: "tong tien"},
+	{target: fieldTotal, phrase: "thanh toan"},
+	{target: fieldTotal, phrase: "total"},
+	{target: fieldTotal, phrase: "grand total"},
+	{target: fieldTotal, phrase: "total amount"},
+	{target: fieldTotal, phrase: "amount due"},
+
+	{target: fieldAmountInWords, phrase: "bang chu"},
+	{target: fieldAmountInWords, phrase: "viet bang chu"},
+	{target: fieldAmountInWords, phrase: "so tien bang chu"},
+	{target: fieldAmountInWords, phrase: "so tien viet bang chu"},
+	{target: fieldAmountInWords, phrase: "tong tien bang chu"},
+	{target: fieldAmountInWords, phrase: "tong tien thanh toan bang chu"},
+	{target: fieldAmountInWords, phrase: "tong cong tien thanh toan bang chu"},
+	{target: fieldAmountInWords, phrase: "in words"},
+	{target: fieldAmountInWords, phrase: "amount in words"},
+	{target: fieldAmountInWords, phrase: "total in words"},
+}
+
+var columnSynonyms = []synonym[column]{
+	{target: columnDescription, phrase: "ten hang hoa dich vu"},
+	{target: columnDescription, phrase: "ten hang hoa"},
+	{target: columnDescription, phrase: "ten hang"},
+	{target: columnDescription, phrase: "hang hoa dich vu"},
+	{target: columnDescription, phrase: "san pham"},
+	{target: columnDescription, phrase: "dien giai"},
+	{target: columnDescription, phrase: "noi dung"},
+	{target: columnDescription, phrase: "mo ta"},
+	{target: columnDescription, phrase: "description"},
+	{target: columnDescription, phrase: "item"},
+	{target: columnDescription, phrase: "product"},
+	{target: columnDescription, phrase: "goods"},
+
+	{target: columnUnit, phrase: "don vi tinh"},
+	{target: columnUnit, phrase: "dvt"},
+	{target: columnUnit, phrase: "unit"},
+	{target: columnUnit, phrase: "uom"},
+
+	{target: columnQuantity, phrase: "so luong"},
+	{target: columnQuantity, phrase: "sl"},
+	{target: columnQuantity, phrase: "qty"},
+	{target: columnQuantity, phrase: "quantity"},
+
+	{target: columnUnitPrice, phrase: "don gia"},
+	{target: columnUnitPrice, phrase: "unit price"},
+	{target: columnUnitPrice, phrase: "price"},
+	{target: columnUnitPrice, phrase: "gia", exact: true},
+
+	{target: columnAmount, phrase: "thanh tien"},
+	{target: columnAmount, phrase: "amount"},
+	{target: columnAmount, phrase: "line total"},
+	{target: columnAmount, phrase: "total"},
+}
+
+// Normalize maps the key-value pairs, summary and table of extracted data
+// onto Fields. The first pair found for a field wins, and values that do not
+// parse as the field expects are skipped. Amounts are read in the locale of
+// the invoice currency, found before them.
+func Normalize(data ExtractedData) Fields {
+	type pair struct {
+		target field
+		value  string
+	}
+	var pairs []pair
+	var amounts []string
+	for _, kv := range append(append([]KeyValuePair{}, data.KeyValuePairs...), data.Summary...) {
+		value := strings.TrimSpace(kv.Value)
+		target, ok := match(fieldSynonyms, kv.Key)
+		if value == "" || !ok {
+			continue
+		}
+		pairs = append(pairs, pair{target: target, value: value})
+		if target == fieldSubtotal || target == fieldVAT || target == fieldTotal {
+			amounts = append(amounts, value)
+		}
+	}
+
+	rows, columns := tableColumns(data.Table)
+	for _, row := range rows {
+		amounts = append(amounts, cell(row, columns, columnUnitPrice), cell(row, columns, columnAmount))
+	}
+
+	var f Fields
+	for _, p := range pairs {
+		if p.target == fieldCurrency && f.Currency == "" {
+			f.Currency = money.DetectCurrency(p.value)
+		}
+	}
+	for _, amount := range amounts {
+		if f.Currency != "" {
+			break
+		}
+		f.Currency = money.DetectCurrency(amount)
+	}
+
+	loc := money.LocaleFor(f.Currency)
+	for _, p := range pairs {
+		f.set(p.target, p.value, loc)
+	}
+	f.LineItems = lineItems(rows, columns, f.Currency, loc)
+	return f
+}
+
+// set fills the field with value unless it is already filled
+func (f *Fields) set(target field, value string, loc money.Locale) {
+	var dst *string
+	var number **money.Decimal
+	parse := func(s string) (string, bool) { return s, true }
+
+	switch target {
+	case fieldInvoiceNumber:
+		dst = &f.InvoiceNumber
+	case fieldIssueDate:
+		if f.IssueDate != nil {
+			return
+		}
+		if date, ok := parseDate(value); ok {
+			f.IssueDate = &date
+		}
+		return
+	case fieldSellerName:
+		dst = &f.SellerName
+	case fieldSellerTaxCode:
+		dst, parse = &f.SellerTaxCode, parseTaxCode
+	case fieldBuyerName:
+		dst = &f.BuyerName
+	case fieldBuyerTaxCode:
+		dst, parse = &f.BuyerTaxCode, parseTaxCode
+	case fieldCurrency:
+		// read by Normalize before the amounts
+		return
+	case fieldVATRate:
+		if f.VATRate == nil {
+			f.VATRate = parseNumber(value, loc)
+		}
+		return
+	case fieldSubtotal:
+		number = &f.Subtotal
+	case fieldVAT:
+		number = &f.VAT
+	case fieldTotal:
+		number = &f.Total
+	case fieldAmountInWords:
+		dst = &f.AmountInWords
+	}
+
+	if number != nil {
+		if *number == nil {
+			*number = parseMoney(value, f.Currency, loc)
+		}
+		return
+	}
+	if *dst != "" {
+		return
+	}
+	if parsed, ok := parse(value); ok {
+		*dst = parsed
+	}
+}
+
+// tableColumns returns the rows of a table whose headers name its columns,
+// with the index of each column found
+func tableColumns(table TableData) ([][]string, map[column]int) {
+	columns := map[column]int{}
+	for i, header := range table.Headers {
+		target, ok := match(columnSynonyms, header)
+		if _, taken := columns[target]; ok && !taken {
+			columns[target] = i
+		}
+	}
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	return table.Rows, columns
+}
+
+func cell(row []string, columns map[column]int, target column) string {
+	i, ok := columns[target]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// lineItems reads the rows of the table, leaving out the ones with neither a
+// description nor an amount
+func lineItems(rows [][]string, columns map[column]int, currency string, loc money.Locale) []LineItem {
+	var items []LineItem
+	for _, row := range rows {
+		item := LineItem{
+			Description: cell(row, columns, columnDescription),
+			Unit:        cell(row, columns, columnUnit),
+			Quantity:    parseNumber(cell(row, columns, columnQuantity), loc),
+			UnitPrice:   parseMoney(cell(row, columns, columnUnitPrice), currency, loc),
+			Amount:      parseMoney(cell(row, columns, columnAmount), currency, loc),
+		}
+		if item.Description == "" && item.Amount == nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// parseMoney reads an amount in currency, nil when s is not one
+func parseMoney(s, currency string, loc money.Locale) *money.Decimal {
+	amount, err := money.ParseAmount(s, currency, loc)
+	if err != nil {
+		return nil
+	}
+	return &amount
+}
+
+// parseNumber reads a quantity or percentage, nil when s is not one
+func parseNumber(s string, loc money.Locale) *money.Decimal {
+	number, err := money.ParseNumber(s, loc)
+	if err != nil {
+		return nil
+	}
+	return &number
+}
+
+// match returns the target of the longest synonym key contains
+func match[T any](synonyms []synonym[T], key string) (T, bool) {
+	folded := " " + foldKey(key) + " "
+	var best synonym[T]
+	for _, s := range synonyms {
+		found := folded == " "+s.phrase+" "
+		if !s.exact {
+			found = strings.Contains(folded, " "+s.phrase+" ")
+		}
+		if found && len(s.phrase) > len(best.phrase) {
+			best = s
+		}
+	}
+	return best.target, best.phrase != ""
+}
+
+// foldKey lowercases s, strips its Vietnamese diacritics and replaces
+// everything but letters and digits with single spaces, so "Số hóa đơn (No.)"
+// becomes "so hoa don no"
+func foldKey(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == 'đ':
+			r = 'd'
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	vietnameseDate = regexp.MustCompile(`ngay\s*(\d{1,2})\s*thang\s*(\d{1,2})\s*nam\s*(\d{4})`)
+	numericDate    = regexp.MustCompile(`(\d{1,4})[/.\-](\d{1,2})[/.\-](\d{2,4})`)
+	dateLayouts    = []string{"January 2, 2006", "Jan 2, 2006", "2 January 2006", "2 Jan 2006"}
+)
+
+// parseDate reads "Ngày 15 tháng 03 năm 2024", day-first dates such as
+// 15/03/2024, ISO dates and English dates such as "March 15, 2024"
+func parseDate(s string) (time.Time, bool) {
+	if m := vietnameseDate.FindStringSubmatch(foldKey(s)); m != nil {
+		return makeDate(m[3], m[2], m[1])
+	}
+	if m := numericDate.FindStringSubmatch(s); m != nil {
+		if len(m[1]) == 4 {
+			return makeDate(m[1], m[2], m[3])
+		}
+		return makeDate(m[3], m[2], m[1])
+	}
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func makeDate(year, month, day string) (time.Time, bool) {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	if len(year) == 2 {
+		y += 2000
+	}
+	date := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	// time.Date rolls 31/02 over to March rather than failing
+	if date.Year() != y || date.Month() != time.Month(m) || date.Day() != d {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// parseTaxCode reads a valid tax code, e.g. "0100109106-001". Invalid codes
+// are left for a later pair, CheckTaxCodes flags them.
+func parseTaxCode(s string) (string, bool) {
+	code, err := taxcode.Parse(s)
+	return code, err == nil
+}
@@ -0,0 +1,259 @@
+package invoice
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/pkg/money"
+)
+
+func decimal(s string) *money.Decimal {
+	d := money.MustParseDecimal(s)
+	return &d
+}
+
+func TestNormalize_Vietnamese(t *testing.T) {
+	data := ExtractedData{
+		KeyValuePairs: []KeyValuePair{
+			{Key: "Ký hiệu", Value: "1C24TAA"},
+			{Key: "Số hóa đơn", Value: "0000123"},
+			{Key: "Ngày lập", Value: "Ngày 15 tháng 03 năm 2024"},
+			{Key: "Đơn vị bán hàng", Value: "CÔNG TY TNHH ABC"},
+			{Key: "Mã số thuế", Value: "0100109106"},
+			{Key: "Họ tên người mua hàng", Value: "Nguyễn Văn A"},
+			{Key: "Mã số thuế người mua", Value: "0312 345 673"},
+			{Key: "Hình thức thanh toán", Value: "TM/CK"},
+		},
+		Table: TableData{
+			Headers: []string{"STT", "Tên hàng hóa, dịch vụ", "Đơn vị tính", "Số lượng", "Đơn giá", "Thành tiền"},
+			Rows: [][]string{
+				{"1", "Cà phê sữa", "Ly", "2", "25.000", "50.000"},
+				{"2", "Bánh mì", "Cái", "1", "20.000", "20.000"},
+				{"", "", "", "", "", ""},
+			},
+		},
+		Summary: []KeyValuePair{
+			{Key: "Cộng tiền hàng", Value: "70.000"},
+			{Key: "Thuế suất GTGT", Value: "8%"},
+			{Key: "Tiền thuế GTGT", Value: "5.600"},
+			{Key: "Tổng cộng tiền thanh toán", Value: "75.600 đ"},
+			{Key: "Số tiền viết bằng chữ", Value: "Bảy mươi lăm nghìn sáu trăm đồng"},
+		},
+	}
+
+	issued := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	expected := Fields{
+		InvoiceNumber: "0000123",
+		IssueDate:     &issued,
+		SellerName:    "CÔNG TY TNHH ABC",
+		SellerTaxCode: "0100109106",
+		BuyerName:     "Nguyễn Văn A",
+		BuyerTaxCode:  "0312345673",
+		Currency:      "VND",
+		Subtotal:      decimal("70000"),
+		VATRate:       decimal("8"),
+		VAT:           decimal("5600"),
+		Total:         decimal("75600"),
+		AmountInWords: "Bảy mươi lăm nghìn sáu trăm đồng",
+		LineItems: []LineItem{
+			{Description: "Cà phê sữa", Unit: "Ly", Quantity: decimal("2"), UnitPrice: decimal("25000"), Amount: decimal("50000")},
+			{Description: "Bánh mì", Unit: "Cái", Quantity: decimal("1"), UnitPrice: decimal("20000"), Amount: decimal("20000")},
+		},
+	}
+
+	if got := Normalize(data); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Normalize() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestNormalize_English(t *testing.T) {
+	data := ExtractedData{
+		KeyValuePairs: []KeyValuePair{
+			{Key: "Invoice No.", Value: "INV-2024-001"},
+			{Key: "Invoice Date", Value: "March 5, 2024"},
+			{Key: "Seller", Value: "ABC Trading Ltd"},
+			{Key: "Tax Code", Value: "0100109106-001"},
+			{Key: "Bill To", Value: "XYZ Corp"},
+			{Key: "Currency", Value: "usd"},
+		},
+		Table: TableData{
+			Headers: []string{"Description", "Qty", "Unit Price", "Amount"},
+			Rows:    [][]string{{"Consulting", "1.5", "1,200.00", "1,800.00"}},
+		},
+		Summary: []KeyValuePair{
+			{Key: "Subtotal", Value: "1,800.00"},
+			{Key: "VAT Rate", Value: "10%"},
+			{Key: "Total VAT", Value: "180.00"},
+			{Key: "Grand Total", Value: "$1,980.00"},
+		},
+	}
+
+	issued := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	expected := Fields{
+		InvoiceNumber: "INV-2024-001",
+		IssueDate:     &issued,
+		SellerName:    "ABC Trading Ltd",
+		SellerTaxCode: "0100109106-001",
+		BuyerName:     "XYZ Corp",
+		Currency:      "USD",
+		Subtotal:      decimal("1800"),
+		VATRate:       decimal("10"),
+		VAT:           decimal("180"),
+		Total:         decimal("1980"),
+		LineItems:     []LineItem{{Description: "Consulting", Quantity: decimal("1.5"), UnitPrice: decimal("1200"), Amount: decimal("1800")}},
+	}
+
+	if got := Normalize(data); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Normalize() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestNormalize_FirstValueWins(t *testing.T) {
+	data := ExtractedData{
+		KeyValuePairs: []KeyValuePair{
+			{Key: "Ngày", Value: "không rõ"},
+			{Key: "Ngày hóa đơn", Value: "31/02/2024"},
+			{Key: "Date", Value: "2024-02-29"},
+			{Key: "Total", Value: "N/A"},
+		},
+		Summary: []KeyValuePair{
+			{Key: "Tổng tiền", Value: "1.000.000"},
+			{Key: "Tổng cộng", Value: "2.000.000"},
+		},
+	}
+
+	got := Normalize(data)
+	if got.IssueDate == nil || !got.IssueDate.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the first valid date 2024-02-29, got %v", got.IssueDate)
+	}
+	if got.Total == nil || got.Total.String() != "1000000" {
+		t.Errorf("Expected the first parsed total 1000000, got %v", got.Total)
+	}
+	if got.Currency != "" {
+		t.Errorf("Expected no currency, got %q", got.Currency)
+	}
+}
+
+func TestNormalize_CurrencyLocale(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		total    string
+		expected string
+	}{
+		{name: "dong written the english way", currency: "VND", total: "50,000", expected: "50000"},
+		{name: "dong written the vietnamese way", currency: "VND", total: "50.000", expected: "50000"},
+		{name: "dollars written the english way", currency: "USD", total: "1.500", expected: "1.5"},
+		{name: "dollars with decimal comma", currency: "USD", total: "1.234,50", expected: "1234.5"},
+		{name: "unknown currency reads as vietnamese", currency: "", total: "1.500", expected: "1500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Normalize(ExtractedData{
+				KeyValuePairs: []KeyValuePair{{Key: "Currency", Value: tt.currency}},
+				Summary:       []KeyValuePair{{Key: "Total", Value: tt.total}},
+			})
+			if got.Total == nil || got.Total.String() != tt.expected {
+				t.Errorf("Expected total %s, got %v", tt.expected, got.Total)
+			}
+		})
+	}
+}
+
+func TestNormalize_Empty(t *testing.T) {
+	if got := Normalize(ExtractedData{}); !got.IsZero() {
+		t.Errorf("Expected no fields, got %+v", got)
+	}
+	if got := NormalizeJSON(json.RawMessage(`{"raw": "not extracted data"`)); !got.IsZero() {
+		t.Errorf("Expected no fields for invalid JSON, got %+v", got)
+	}
+}
+
+func TestInvoice_SetExtractedData(t *testing.T) {
+	inv := New(ID("01HXYZ"), "/uploads/test.jpg")
+	inv.MarkCompleted(json.RawMessage(`{"key_value_pairs":[{"key":"Số hóa đơn","value":"0000042"}],"table":{"headers":[],"rows":[]},"summary":[]}`), "v2", 1)
+
+	if inv.Fields.InvoiceNumber != "0000042" {
+		t.Errorf("Expected invoice number 0000042, got %q", inv.Fields.InvoiceNumber)
+	}
+
+	inv.SetExtractedData(json.RawMessage(`{"key_value_pairs":[],"table":{"headers":[],"rows":[]},"summary":[]}`))
+	if !inv.Fields.IsZero() {
+		t.Errorf("Expected the fields to follow the edited data, got %+v", inv.Fields)
+	}
+}
+
+func TestFoldKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"Số hóa đơn (Invoice No.)", "so hoa don invoice no"},
+		{"  Tổng cộng tiền thanh toán:", "tong cong tien thanh toan"},
+		{"ĐƠN VỊ BÁN HÀNG", "don vi ban hang"},
+		{"M.S.T", "m s t"},
+	}
+
+	for _, tt := range tests {
+		if got := foldKey(tt.key); got != tt.expected {
+			t.Errorf("foldKey(%q) = %q, want %q", tt.key, got, tt.expected)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected field
+		ok       bool
+	}{
+		{"Số hóa đơn (Invoice No.)", fieldInvoiceNumber, true},
+		{"Số", fieldInvoiceNumber, true},
+		{"Số tài khoản", 0, false},
+		{"Tổng tiền hàng", fieldSubtotal, true},
+		{"Tổng tiền thanh toán", fieldTotal, true},
+		{"Tổng tiền thuế", fieldVAT, true},
+		{"Thuế suất GTGT", fieldVATRate, true},
+		{"Total amount before tax", fieldSubtotal, true},
+		{"MST người mua", fieldBuyerTaxCode, true},
+		{"Tổng cộng tiền thanh toán bằng chữ", fieldAmountInWords, true},
+		{"Amount in words", fieldAmountInWords, true},
+		{"Địa chỉ", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := match(fieldSynonyms, tt.key)
+		if ok != tt.ok || (ok && got != tt.expected) {
+			t.Errorf("match(%q) = %v, %v, want %v, %v", tt.key, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"Ngày 05 tháng 3 năm 2024", "2024-03-05"},
+		{"05/03/2024", "2024-03-05"},
+		{"5-3-24", "2024-03-05"},
+		{"2024-03-05", "2024-03-05"},
+		{"5 March 2024", "2024-03-05"},
+		{"31/04/2024", ""},
+		{"không rõ", ""},
+	}
+
+	for _, tt := range tests {
+		date, ok := parseDate(tt.value)
+		got := ""
+		if ok {
+			got = date.Format("2006-01-02")
+		}
+		if got != tt.expected {
+			t.Errorf("parseDate(%q) = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package invoice
+
+import "time"
+
+// MaxPages caps the images one invoice is made of
+const MaxPages = 10
+
+// Page is one image of an invoice, e.g. the front or back of a long receipt
+// photographed in parts. All pages of an invoice are extracted together, in
+// Number order.
+type Page struct {
+	ID        int64
+	InvoiceID ID
+	// Number is the position of the page in the invoice, from 1
+	Number int
+	// ImagePath is the image extraction reads, preprocessed when uploaded
+	ImagePath string
+	// OriginalPath is the image as it was uploaded when ImagePath is a
+	// preprocessed copy of it, empty otherwise
+	OriginalPath string
+	// ImageHash is the hex SHA-256 of the image at ImagePath, the same for
+	// every page of the same image
+	ImageHash string
+	MIMEType  string
+	CreatedAt time.Time
+}
+
+type Pages []*Page
+
+func NewPage(invoiceID ID, number int, imagePath, mimeType string) *Page {
+	return &Page{
+		InvoiceID: invoiceID,
+		Number:    number,
+		ImagePath: imagePath,
+		MIMEType:  mimeType,
+		CreatedAt: time.Now(),
+	}
+}
@@ -1,6 +1,13 @@
 package invoice
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when no invoice matches a lookup
+var ErrNotFound = errors.New("invoice not found")
 
 // PaginationParams defines pagination parameters for list queries
 type PaginationParams struct {
@@ -28,8 +35,23 @@ type PaginatedResult struct {
 type Repository interface {
 	NextID() ID
 	Create(ctx context.Context, invoice *Invoice) error
+	// GetByID returns the invoice with id, or ErrNotFound
 	GetByID(ctx context.Context, id ID) (*Invoice, error)
+	// GetForUpdate returns the invoice with id like GetByID and locks it until
+	// the transaction in ctx ends
+	GetForUpdate(ctx context.Context, id ID) (*Invoice, error)
 	List(ctx context.Context, params PaginationParams) (*PaginatedResult, error)
 	Update(ctx context.Context, invoice *Invoice, updateFunc func(*Invoice) error) error
 	Delete(ctx context.Context, id ID) error
+	// ListStale returns pending or processing invoices last updated before
+	// updatedBefore, oldest first
+	ListStale(ctx context.Context, updatedBefore time.Time, params PaginationParams) (Invoices, error)
+
+	// AddPages stores pages of an invoice, numbered by the caller
+	AddPages(ctx context.Context, pages Pages) error
+	// ListPages returns the pages of an invoice in page order
+	ListPages(ctx context.Context, invoiceID ID) (Pages, error)
+
+	CreateRevision(ctx context.Context, revision *Revision) error
+	ListRevisions(ctx context.Context, invoiceID ID) (Revisions, error)
 }
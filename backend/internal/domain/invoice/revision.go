@@ -0,0 +1,33 @@
+package invoice
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Revision is a snapshot of extracted data that was replaced by a later
+// extraction of the same invoice
+type Revision struct {
+	ID            int64
+	InvoiceID     ID
+	Number        int
+	ExtractedData json.RawMessage
+	PromptVersion string
+	CreatedAt     time.Time
+}
+
+type Revisions []*Revision
+
+// NewRevision snapshots the current extracted data of the invoice. It returns
+// nil when there is nothing to keep.
+func NewRevision(inv *Invoice) *Revision {
+	if len(inv.ExtractedData) == 0 {
+		return nil
+	}
+	return &Revision{
+		InvoiceID:     inv.ID,
+		ExtractedData: inv.ExtractedData,
+		PromptVersion: inv.PromptVersion,
+		CreatedAt:     time.Now(),
+	}
+}
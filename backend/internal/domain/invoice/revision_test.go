@@ -0,0 +1,31 @@
+package invoice
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewRevision(t *testing.T) {
+	inv := New(ID("01HXYZ123ABC456DEF789GHI"), "/uploads/test.jpg")
+
+	if rev := NewRevision(inv); rev != nil {
+		t.Errorf("Expected no revision without extracted data, got %+v", rev)
+	}
+
+	data := json.RawMessage(`{"key": "value"}`)
+	inv.MarkCompleted(data, "v1", 0)
+
+	rev := NewRevision(inv)
+	if rev == nil {
+		t.Fatal("Expected revision for extracted data")
+	}
+	if rev.InvoiceID != inv.ID {
+		t.Errorf("Expected invoice ID %v, got %v", inv.ID, rev.InvoiceID)
+	}
+	if string(rev.ExtractedData) != string(data) {
+		t.Errorf("Expected extracted data %s, got %s", data, rev.ExtractedData)
+	}
+	if rev.PromptVersion != "v1" {
+		t.Errorf("Expected prompt version v1, got %v", rev.PromptVersion)
+	}
+}
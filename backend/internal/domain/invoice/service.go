@@ -2,7 +2,38 @@ package invoice
 
 import "context"
 
+// ExtractOptions overrides the provider defaults for a single extraction.
+// Zero values keep the defaults.
+type ExtractOptions struct {
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// TenantID selects the tenant's own prompt templates when it has any
+	TenantID string `json:"tenant_id,omitempty"`
+	// Language, Currency and CustomFields describe the expected invoice to
+	// the prompt template
+	Language     string   `json:"language,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	CustomFields []string `json:"custom_fields,omitempty"`
+	// SkipCache extracts again even when a cached result exists
+	SkipCache bool `json:"skip_cache,omitempty"`
+}
+
+// Image is one page of an invoice: a photo, or a PDF document that may hold
+// several pages itself
+type Image struct {
+	Bytes    []byte
+	MIMEType string
+}
+
+// ImagePreprocessor prepares an uploaded image for extraction, e.g. rotating,
+// downscaling and cleaning up a phone photo
+type ImagePreprocessor interface {
+	Preprocess(ctx context.Context, image Image) (Image, error)
+}
+
 type ExtractionService interface {
-	Extract(ctx context.Context, imageBytes []byte, mimeType string) (ExtractedData, error)
+	// Extract reads one invoice from its images, in page order. All images
+	// are sent in one request so the model sees the whole document.
+	Extract(ctx context.Context, images []Image, opts ExtractOptions) (ExtractedData, error)
 	Close() error
 }
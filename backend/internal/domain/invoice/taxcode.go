@@ -0,0 +1,40 @@
+package invoice
+
+import "invoice-scan/backend/pkg/taxcode"
+
+// InvalidTaxCodeConfidence is the highest confidence of a pair holding a tax
+// code that fails validation, most likely a misread digit
+const InvalidTaxCodeConfidence = 0.2
+
+// CheckTaxCodes validates the tax codes of the pairs and summary: the first
+// valid seller and buyer codes fill SellerTaxCode and BuyerTaxCode, and pairs
+// whose code is invalid have their confidence lowered to at most
+// InvalidTaxCodeConfidence. Checking data twice changes nothing.
+func CheckTaxCodes(data *ExtractedData) {
+	for _, pairs := range [][]KeyValuePair{data.KeyValuePairs, data.Summary} {
+		for i := range pairs {
+			pair := &pairs[i]
+			target, ok := match(fieldSynonyms, pair.Key)
+			if !ok || (target != fieldSellerTaxCode && target != fieldBuyerTaxCode) {
+				continue
+			}
+
+			code, err := taxcode.Parse(pair.Value)
+			if err != nil {
+				if pair.Confidence == nil || *pair.Confidence > InvalidTaxCodeConfidence {
+					confidence := InvalidTaxCodeConfidence
+					pair.Confidence = &confidence
+				}
+				continue
+			}
+
+			dst := &data.SellerTaxCode
+			if target == fieldBuyerTaxCode {
+				dst = &data.BuyerTaxCode
+			}
+			if *dst == "" {
+				*dst = code
+			}
+		}
+	}
+}
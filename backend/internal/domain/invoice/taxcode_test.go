@@ -0,0 +1,63 @@
+package invoice
+
+import "testing"
+
+func confidence(c float64) *float64 {
+	return &c
+}
+
+func TestCheckTaxCodes(t *testing.T) {
+	data := ExtractedData{
+		KeyValuePairs: []KeyValuePair{
+			{Key: "Mã số thuế", Value: "0100109108", Confidence: confidence(0.95)},
+			{Key: "MST", Value: "0100 109 106", Confidence: confidence(0.9)},
+			{Key: "Mã số thuế người mua", Value: "0312345673-001"},
+			{Key: "Buyer tax code", Value: "not a code"},
+			{Key: "Số hóa đơn", Value: "0000123", Confidence: confidence(0.9)},
+		},
+		Summary: []KeyValuePair{
+			{Key: "MST người mua", Value: "0301447899", Confidence: confidence(0.1)},
+		},
+	}
+
+	CheckTaxCodes(&data)
+
+	if data.SellerTaxCode != "0100109106" {
+		t.Errorf("Expected seller tax code 0100109106, got %q", data.SellerTaxCode)
+	}
+	if data.BuyerTaxCode != "0312345673-001" {
+		t.Errorf("Expected buyer tax code 0312345673-001, got %q", data.BuyerTaxCode)
+	}
+
+	expected := []*float64{confidence(InvalidTaxCodeConfidence), confidence(0.9), nil, confidence(InvalidTaxCodeConfidence), confidence(0.9)}
+	for i, pair := range data.KeyValuePairs {
+		if !equalConfidence(pair.Confidence, expected[i]) {
+			t.Errorf("Expected confidence %v for %q, got %v", deref(expected[i]), pair.Key, deref(pair.Confidence))
+		}
+	}
+	// a lower confidence is kept
+	if c := data.Summary[0].Confidence; *c != 0.1 {
+		t.Errorf("Expected confidence 0.1 to be kept, got %v", *c)
+	}
+
+	// checking again changes nothing
+	before := *data.KeyValuePairs[0].Confidence
+	CheckTaxCodes(&data)
+	if *data.KeyValuePairs[0].Confidence != before {
+		t.Errorf("Expected confidence %v after a second check, got %v", before, *data.KeyValuePairs[0].Confidence)
+	}
+}
+
+func equalConfidence(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref(c *float64) interface{} {
+	if c == nil {
+		return nil
+	}
+	return *c
+}
@@ -0,0 +1,116 @@
+package invoice
+
+import (
+	"context"
+	"time"
+)
+
+// Usage is what a single provider call consumed
+type Usage struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model,omitempty"`
+	PromptTokens int    `json:"prompt_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	TotalTokens  int    `json:"total_tokens"`
+	LatencyMs    int64  `json:"latency_ms"`
+	// Error is set when the call failed, e.g. a provider that a composite
+	// provider fell back from
+	Error string `json:"error,omitempty"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// Pricing maps model names to their price
+type Pricing map[string]ModelPrice
+
+// Cost returns the estimated cost of usage in USD, 0 for unpriced models
+func (p Pricing) Cost(u Usage) float64 {
+	price, ok := p[u.Model]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*price.InputPerMillion + float64(u.OutputTokens)*price.OutputPerMillion) / 1_000_000
+}
+
+// ExtractionAttempt records the usage and cost of one provider call
+type ExtractionAttempt struct {
+	ID int64
+	// InvoiceID is empty for extractions that are not stored as invoices
+	InvoiceID    ID
+	JobID        string
+	TenantID     string
+	Provider     string
+	Model        string
+	PromptTokens int
+	OutputTokens int
+	TotalTokens  int
+	LatencyMs    int64
+	CostUSD      float64
+	Succeeded    bool
+	ErrorMessage *string
+	CreatedAt    time.Time
+}
+
+type ExtractionAttempts []*ExtractionAttempt
+
+// NewExtractionAttempts returns an attempt per provider call of an extraction.
+// Calls without an error of their own fail with extractErr, the error of the
+// whole extraction.
+func NewExtractionAttempts(usage []Usage, extractErr error, pricing Pricing) ExtractionAttempts {
+	now := time.Now()
+	attempts := make(ExtractionAttempts, 0, len(usage))
+	for _, u := range usage {
+		attempt := &ExtractionAttempt{
+			Provider:     u.Provider,
+			Model:        u.Model,
+			PromptTokens: u.PromptTokens,
+			OutputTokens: u.OutputTokens,
+			TotalTokens:  u.TotalTokens,
+			LatencyMs:    u.LatencyMs,
+			CostUSD:      pricing.Cost(u),
+			Succeeded:    u.Error == "" && extractErr == nil,
+			CreatedAt:    now,
+		}
+		if errMsg := u.Error; errMsg != "" {
+			attempt.ErrorMessage = &errMsg
+		} else if extractErr != nil {
+			errMsg := extractErr.Error()
+			attempt.ErrorMessage = &errMsg
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+// UsageFilter selects the attempts aggregated by SummarizeUsage. Zero values
+// do not filter.
+type UsageFilter struct {
+	From     time.Time
+	To       time.Time
+	TenantID string
+}
+
+// UsageSummary aggregates the attempts of a day, model and tenant
+type UsageSummary struct {
+	Day          string
+	Model        string
+	TenantID     string
+	Attempts     int64
+	Failed       int64
+	Invoices     int64
+	PromptTokens int64
+	OutputTokens int64
+	TotalTokens  int64
+	CostUSD      float64
+}
+
+type UsageRepository interface {
+	CreateAttempts(ctx context.Context, attempts ExtractionAttempts) error
+	ListAttempts(ctx context.Context, invoiceID ID) (ExtractionAttempts, error)
+	// SummarizeUsage aggregates attempts by day, model and tenant
+	SummarizeUsage(ctx context.Context, filter UsageFilter) ([]UsageSummary, error)
+}
@@ -0,0 +1,56 @@
+package invoice
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPricing_Cost(t *testing.T) {
+	pricing := Pricing{"gemini-2.5-flash": {InputPerMillion: 0.30, OutputPerMillion: 2.50}}
+
+	tests := []struct {
+		name  string
+		usage Usage
+		want  float64
+	}{
+		{"priced model", Usage{Model: "gemini-2.5-flash", PromptTokens: 2000, OutputTokens: 400}, 0.0016},
+		{"unpriced model", Usage{Model: "qwen2.5-vl", PromptTokens: 2000, OutputTokens: 400}, 0},
+		{"no tokens", Usage{Model: "gemini-2.5-flash"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pricing.Cost(tt.usage); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("Expected cost %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewExtractionAttempts(t *testing.T) {
+	usage := []Usage{
+		{Provider: "gemini", Model: "gemini-2.5-flash", Error: "gemini API error: 503"},
+		{Provider: "openai", Model: "qwen2.5-vl", TotalTokens: 900},
+	}
+
+	attempts := NewExtractionAttempts(usage, nil, nil)
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+	if attempts[0].Succeeded || *attempts[0].ErrorMessage != "gemini API error: 503" {
+		t.Errorf("Expected the first attempt to keep its own error, got %+v", attempts[0])
+	}
+	if !attempts[1].Succeeded || attempts[1].ErrorMessage != nil {
+		t.Errorf("Expected the second attempt to succeed, got %+v", attempts[1])
+	}
+
+	attempts = NewExtractionAttempts(usage[1:], errors.New("failed to parse response"), nil)
+	if attempts[0].Succeeded || *attempts[0].ErrorMessage != "failed to parse response" {
+		t.Errorf("Expected the extraction error on the attempt, got %+v", attempts[0])
+	}
+
+	if attempts := NewExtractionAttempts(nil, nil, nil); len(attempts) != 0 {
+		t.Errorf("Expected no attempts, got %d", len(attempts))
+	}
+}
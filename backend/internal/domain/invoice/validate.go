@@ -0,0 +1,132 @@
+package invoice
+
+import (
+	"fmt"
+
+	"invoice-scan/backend/pkg/money"
+	"invoice-scan/backend/pkg/numwords"
+)
+
+type FindingCode string
+
+const (
+	// FindingLineAmount is a row whose quantity × unit price is not its amount
+	FindingLineAmount FindingCode = "line_amount_mismatch"
+	// FindingSubtotal is a subtotal that is not the sum of the row amounts
+	FindingSubtotal FindingCode = "subtotal_mismatch"
+	// FindingTotal is a total that is not the subtotal plus VAT
+	FindingTotal FindingCode = "total_mismatch"
+	// FindingVATRate is a VAT rate Vietnam does not levy
+	FindingVATRate FindingCode = "invalid_vat_rate"
+	// FindingAmountInWords is a total that is not the amount in words
+	FindingAmountInWords FindingCode = "amount_in_words_mismatch"
+)
+
+// Finding is an inconsistency between the numbers of an invoice
+type Finding struct {
+	Code    FindingCode `json:"code"`
+	Message string      `json:"message"`
+	// Row is the line item the finding is about, from 1, 0 for none
+	Row      int            `json:"row,omitempty"`
+	Expected *money.Decimal `json:"expected,omitempty"`
+	Actual   *money.Decimal `json:"actual,omitempty"`
+}
+
+// vatRates are the Vietnamese VAT rates in percent
+var vatRates = []money.Decimal{
+	money.NewDecimal(0, 0),
+	money.NewDecimal(5, 0),
+	money.NewDecimal(8, 0),
+	money.NewDecimal(10, 0),
+}
+
+// Validate checks that the amounts of the fields add up: the line items, the
+// subtotal, VAT and total, and that the total is the amount in words. Checks
+// missing an amount, or with words that are not a Vietnamese number, are
+// skipped. Amounts may be off by one minor unit of the currency, the rounding
+// of a product.
+func Validate(f Fields) []Finding {
+	var findings []Finding
+	tolerance := money.NewDecimal(1, int32(money.MinorUnits(f.Currency)))
+	mismatch := func(expected, actual money.Decimal) bool {
+		return expected.Sub(actual).Abs().Cmp(tolerance) > 0
+	}
+
+	for i, item := range f.LineItems {
+		if item.Quantity == nil || item.UnitPrice == nil || item.Amount == nil {
+			continue
+		}
+		if expected := item.Quantity.Mul(*item.UnitPrice); mismatch(expected, *item.Amount) {
+			findings = append(findings, Finding{
+				Code:     FindingLineAmount,
+				Message:  fmt.Sprintf("row %d: %s × %s is %s, not %s", i+1, item.Quantity, item.UnitPrice, expected, item.Amount),
+				Row:      i + 1,
+				Expected: &expected,
+				Actual:   item.Amount,
+			})
+		}
+	}
+
+	if f.Subtotal != nil && len(f.LineItems) > 0 {
+		sum, complete := money.Decimal{}, true
+		for _, item := range f.LineItems {
+			if item.Amount == nil {
+				complete = false
+				break
+			}
+			sum = sum.Add(*item.Amount)
+		}
+		if complete && mismatch(sum, *f.Subtotal) {
+			findings = append(findings, Finding{
+				Code:     FindingSubtotal,
+				Message:  fmt.Sprintf("the rows add up to %s, not the subtotal %s", sum, f.Subtotal),
+				Expected: &sum,
+				Actual:   f.Subtotal,
+			})
+		}
+	}
+
+	if f.Subtotal != nil && f.VAT != nil && f.Total != nil {
+		if expected := f.Subtotal.Add(*f.VAT); mismatch(expected, *f.Total) {
+			findings = append(findings, Finding{
+				Code:     FindingTotal,
+				Message:  fmt.Sprintf("subtotal %s plus VAT %s is %s, not the total %s", f.Subtotal, f.VAT, expected, f.Total),
+				Expected: &expected,
+				Actual:   f.Total,
+			})
+		}
+	}
+
+	if f.Total != nil && f.AmountInWords != "" {
+		// the words are read more reliably than the digits, so they are
+		// expected and the total is what may be misread
+		if n, err := numwords.Parse(f.AmountInWords); err == nil {
+			if expected := money.NewDecimal(n, 0); mismatch(expected, *f.Total) {
+				findings = append(findings, Finding{
+					Code:     FindingAmountInWords,
+					Message:  fmt.Sprintf("the amount in words %q is %s, not the total %s", f.AmountInWords, expected, f.Total),
+					Expected: &expected,
+					Actual:   f.Total,
+				})
+			}
+		}
+	}
+
+	if f.VATRate != nil && !validVATRate(*f.VATRate) {
+		findings = append(findings, Finding{
+			Code:    FindingVATRate,
+			Message: fmt.Sprintf("VAT rate %s%% is not one of 0%%, 5%%, 8%% or 10%%", f.VATRate),
+			Actual:  f.VATRate,
+		})
+	}
+	return findings
+}
+
+func validVATRate(rate money.Decimal) bool {
+	for _, r := range vatRates {
+		if rate.Equal(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package invoice
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// consistentFields is a VND invoice whose amounts add up
+func consistentFields() Fields {
+	return Fields{
+		Currency: "VND",
+		Subtotal: decimal("70000"),
+		VATRate:  decimal("8"),
+		VAT:      decimal("5600"),
+		Total:    decimal("75600"),
+		LineItems: []LineItem{
+			{Description: "Cà phê sữa", Quantity: decimal("2"), UnitPrice: decimal("25000"), Amount: decimal("50000")},
+			{Description: "Bánh mì", Quantity: decimal("1"), UnitPrice: decimal("20000"), Amount: decimal("20000")},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(f *Fields)
+		expected []FindingCode
+	}{
+		{
+			name:   "consistent",
+			modify: func(f *Fields) {},
+		},
+		{
+			name: "line amount",
+			modify: func(f *Fields) {
+				f.LineItems[1].Amount = decimal("21000")
+				f.Subtotal = decimal("71000")
+				f.Total = decimal("76600")
+			},
+			expected: []FindingCode{FindingLineAmount},
+		},
+		{
+			name:   "line amount rounded to the dong",
+			modify: func(f *Fields) { f.LineItems[1].Quantity = decimal("1.5"); f.LineItems[1].UnitPrice = decimal("13333") },
+		},
+		{
+			name:     "subtotal",
+			modify:   func(f *Fields) { f.Subtotal = decimal("80000"); f.Total = decimal("85600") },
+			expected: []FindingCode{FindingSubtotal},
+		},
+		{
+			name:     "total",
+			modify:   func(f *Fields) { f.Total = decimal("75000") },
+			expected: []FindingCode{FindingTotal},
+		},
+		{
+			name:     "vat rate",
+			modify:   func(f *Fields) { f.VATRate = decimal("7") },
+			expected: []FindingCode{FindingVATRate},
+		},
+		{
+			name:   "amount in words",
+			modify: func(f *Fields) { f.AmountInWords = "Bảy mươi lăm nghìn sáu trăm đồng chẵn" },
+		},
+		{
+			name:     "amount in words mismatch",
+			modify:   func(f *Fields) { f.AmountInWords = "Bảy mươi sáu nghìn sáu trăm đồng" },
+			expected: []FindingCode{FindingAmountInWords},
+		},
+		{
+			name: "total misread",
+			modify: func(f *Fields) {
+				f.Total = decimal("76500")
+				f.AmountInWords = "Bảy mươi lăm nghìn sáu trăm đồng"
+			},
+			expected: []FindingCode{FindingTotal, FindingAmountInWords},
+		},
+		{
+			name:   "words that are not a number are skipped",
+			modify: func(f *Fields) { f.AmountInWords = "Seventy-five thousand six hundred dong" },
+		},
+		{
+			name:   "reduced vat rate",
+			modify: func(f *Fields) { f.VATRate = decimal("5.0") },
+		},
+		{
+			name: "missing amounts are skipped",
+			modify: func(f *Fields) {
+				f.LineItems[0].Amount = nil
+				f.VAT = nil
+				f.VATRate = nil
+			},
+		},
+		{
+			name: "several findings",
+			modify: func(f *Fields) {
+				f.LineItems[0].UnitPrice = decimal("20000")
+				f.Total = decimal("1")
+				f.VATRate = decimal("12")
+			},
+			expected: []FindingCode{FindingLineAmount, FindingTotal, FindingVATRate},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := consistentFields()
+			tt.modify(&fields)
+
+			findings := Validate(fields)
+			if len(findings) != len(tt.expected) {
+				t.Fatalf("Expected %d findings, got %+v", len(tt.expected), findings)
+			}
+			for i, finding := range findings {
+				if finding.Code != tt.expected[i] {
+					t.Errorf("Expected finding %s, got %s", tt.expected[i], finding.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_LineAmountFinding(t *testing.T) {
+	fields := consistentFields()
+	fields.LineItems[1].Amount = decimal("21000")
+
+	findings := Validate(fields)
+	if len(findings) == 0 {
+		t.Fatal("Expected findings")
+	}
+	finding := findings[0]
+	if finding.Row != 2 {
+		t.Errorf("Expected row 2, got %d", finding.Row)
+	}
+	if finding.Expected.String() != "20000" || finding.Actual.String() != "21000" {
+		t.Errorf("Expected 20000 instead of 21000, got %v instead of %v", finding.Expected, finding.Actual)
+	}
+	if finding.Message != "row 2: 1 × 20000 is 20000, not 21000" {
+		t.Errorf("Unexpected message %q", finding.Message)
+	}
+}
+
+func TestValidate_AmountInWordsFinding(t *testing.T) {
+	fields := consistentFields()
+	fields.Total = decimal("75000")
+	fields.VAT = nil
+	fields.AmountInWords = "Bảy mươi lăm nghìn sáu trăm đồng"
+
+	findings := Validate(fields)
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %+v", findings)
+	}
+	finding := findings[0]
+	if finding.Expected.String() != "75600" || finding.Actual.String() != "75000" {
+		t.Errorf("Expected 75600 instead of 75000, got %v instead of %v", finding.Expected, finding.Actual)
+	}
+	if finding.Message != `the amount in words "Bảy mươi lăm nghìn sáu trăm đồng" is 75600, not the total 75000` {
+		t.Errorf("Unexpected message %q", finding.Message)
+	}
+}
+
+func TestValidate_CentTolerance(t *testing.T) {
+	fields := Fields{
+		Currency:  "USD",
+		LineItems: []LineItem{{Quantity: decimal("3"), UnitPrice: decimal("0.333"), Amount: decimal("1.00")}},
+	}
+	if findings := Validate(fields); len(findings) != 0 {
+		t.Errorf("Expected no findings within a cent, got %+v", findings)
+	}
+
+	fields.LineItems[0].Amount = decimal("1.02")
+	if findings := Validate(fields); len(findings) != 1 {
+		t.Errorf("Expected a finding beyond a cent, got %+v", findings)
+	}
+}
+
+func TestInvoice_MarkCompleted_NeedsReview(t *testing.T) {
+	data := func(total string) json.RawMessage {
+		return json.RawMessage(`{"key_value_pairs":[],"table":{"headers":[],"rows":[]},"summary":[` +
+			`{"key":"Cộng tiền hàng","value":"70.000"},{"key":"Tiền thuế GTGT","value":"5.600"},` +
+			`{"key":"Tổng cộng","value":"` + total + `"}]}`)
+	}
+
+	inv := New(ID("01HXYZ"), "/uploads/test.jpg")
+	inv.MarkProcessing()
+	inv.MarkCompleted(data("75.000"), "v2", 1)
+	if inv.Status != StatusNeedsReview {
+		t.Errorf("Expected status %v, got %v", StatusNeedsReview, inv.Status)
+	}
+	if len(inv.Findings) != 1 || inv.Findings[0].Code != FindingTotal {
+		t.Errorf("Expected a total finding, got %+v", inv.Findings)
+	}
+
+	// correcting the total clears the review
+	inv.SetExtractedData(data("75.600"))
+	if inv.Status != StatusCompleted {
+		t.Errorf("Expected status %v, got %v", StatusCompleted, inv.Status)
+	}
+	if len(inv.Findings) != 0 {
+		t.Errorf("Expected no findings, got %+v", inv.Findings)
+	}
+}
+
+func TestInvoice_SetExtractedData_KeepsStatusBeforeExtraction(t *testing.T) {
+	inv := NewDraft(ID("01HXYZ"), "/uploads/test.jpg")
+	inv.SetExtractedData(json.RawMessage(`{"key_value_pairs":[],"table":{"headers":[],"rows":[]},"summary":[{"key":"VAT Rate","value":"7%"}]}`))
+
+	if inv.Status != StatusDraft {
+		t.Errorf("Expected status %v, got %v", StatusDraft, inv.Status)
+	}
+	if len(inv.Findings) != 1 {
+		t.Errorf("Expected 1 finding, got %+v", inv.Findings)
+	}
+}
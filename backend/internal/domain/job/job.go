@@ -0,0 +1,133 @@
+package job
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ID string
+
+func (id ID) String() string {
+	return string(id)
+}
+
+// Type identifies which handler processes a job
+type Type string
+
+func (t Type) String() string {
+	return string(t)
+}
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	// StatusDead jobs exhausted their retries or failed permanently. They stay
+	// in the dead-letter queue until requeued.
+	StatusDead Status = "dead"
+)
+
+func (s Status) String() string {
+	return string(s)
+}
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusQueued, StatusRunning, StatusSucceeded, StatusDead:
+		return true
+	}
+	return false
+}
+
+type Job struct {
+	ID   ID
+	Type Type
+	// ResourceID identifies the entity the job works on, e.g. an invoice ID
+	ResourceID  string
+	Payload     json.RawMessage
+	Status      Status
+	Attempts    int
+	LastError   *string
+	NextRunAt   time.Time
+	LockedBy    string
+	LockedUntil *time.Time
+	HeartbeatAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func New(id ID, jobType Type, resourceID string, payload json.RawMessage) *Job {
+	now := time.Now()
+	return &Job{
+		ID:         id,
+		Type:       jobType,
+		ResourceID: resourceID,
+		Payload:    payload,
+		Status:     StatusQueued,
+		NextRunAt:  now,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// MarkRunning records that workerID holds the job until the lease expires
+func (j *Job) MarkRunning(workerID string, lease time.Duration) {
+	now := time.Now()
+	lockedUntil := now.Add(lease)
+	j.Status = StatusRunning
+	j.Attempts++
+	j.LockedBy = workerID
+	j.LockedUntil = &lockedUntil
+	j.HeartbeatAt = &now
+	j.UpdatedAt = now
+}
+
+// ExtendLease pushes the lease expiry forward while the worker is still alive
+func (j *Job) ExtendLease(lease time.Duration) {
+	now := time.Now()
+	lockedUntil := now.Add(lease)
+	j.LockedUntil = &lockedUntil
+	j.HeartbeatAt = &now
+	j.UpdatedAt = now
+}
+
+func (j *Job) MarkSucceeded() {
+	j.Status = StatusSucceeded
+	j.LastError = nil
+	j.release()
+}
+
+// MarkRetry puts the job back in the queue to run again at runAt
+func (j *Job) MarkRetry(errMsg string, runAt time.Time) {
+	j.Status = StatusQueued
+	j.LastError = &errMsg
+	j.NextRunAt = runAt
+	j.release()
+}
+
+func (j *Job) MarkDead(errMsg string) {
+	j.Status = StatusDead
+	j.LastError = &errMsg
+	j.release()
+}
+
+// Requeue moves a dead job back to the queue with a fresh attempt budget
+func (j *Job) Requeue() {
+	j.Status = StatusQueued
+	j.Attempts = 0
+	j.NextRunAt = time.Now()
+	j.release()
+}
+
+// IsLeaseExpired reports whether a running job has been abandoned by its worker
+func (j *Job) IsLeaseExpired(now time.Time) bool {
+	return j.Status == StatusRunning && j.LockedUntil != nil && j.LockedUntil.Before(now)
+}
+
+func (j *Job) release() {
+	j.LockedBy = ""
+	j.LockedUntil = nil
+	j.UpdatedAt = time.Now()
+}
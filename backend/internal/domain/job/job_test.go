@@ -0,0 +1,148 @@
+package job
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStatus_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   Status
+		expected bool
+	}{
+		{"Queued", StatusQueued, true},
+		{"Running", StatusRunning, true},
+		{"Succeeded", StatusSucceeded, true},
+		{"Dead", StatusDead, true},
+		{"Invalid", Status("invalid"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.IsValid(); got != tt.expected {
+				t.Errorf("Status.IsValid() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	payload := json.RawMessage(`{"invoice_id":"01HXYZ"}`)
+	j := New(ID("01JOB"), Type("test"), "01HXYZ", payload)
+
+	if j.Status != StatusQueued {
+		t.Errorf("Expected status %v, got %v", StatusQueued, j.Status)
+	}
+	if j.Attempts != 0 {
+		t.Errorf("Expected 0 attempts, got %d", j.Attempts)
+	}
+	if j.NextRunAt.IsZero() {
+		t.Error("NextRunAt should not be zero")
+	}
+	if j.ResourceID != "01HXYZ" {
+		t.Errorf("Expected resource ID 01HXYZ, got %q", j.ResourceID)
+	}
+	if string(j.Payload) != string(payload) {
+		t.Errorf("Expected payload %s, got %s", payload, j.Payload)
+	}
+}
+
+func TestJob_MarkRunning(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+
+	j.MarkRunning("worker-1", time.Minute)
+
+	if j.Status != StatusRunning {
+		t.Errorf("Expected status %v, got %v", StatusRunning, j.Status)
+	}
+	if j.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", j.Attempts)
+	}
+	if j.LockedBy != "worker-1" {
+		t.Errorf("Expected locked by worker-1, got %q", j.LockedBy)
+	}
+	if j.LockedUntil == nil || !j.LockedUntil.After(time.Now()) {
+		t.Error("LockedUntil should be in the future")
+	}
+}
+
+func TestJob_IsLeaseExpired(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+	j.MarkRunning("worker-1", time.Minute)
+
+	if j.IsLeaseExpired(time.Now()) {
+		t.Error("Lease should not be expired yet")
+	}
+	if !j.IsLeaseExpired(time.Now().Add(2 * time.Minute)) {
+		t.Error("Lease should be expired")
+	}
+}
+
+func TestJob_MarkSucceeded(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+	j.MarkRunning("worker-1", time.Minute)
+
+	j.MarkSucceeded()
+
+	if j.Status != StatusSucceeded {
+		t.Errorf("Expected status %v, got %v", StatusSucceeded, j.Status)
+	}
+	if j.LockedBy != "" || j.LockedUntil != nil {
+		t.Error("Lease should be released")
+	}
+}
+
+func TestJob_MarkRetry(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+	j.MarkRunning("worker-1", time.Minute)
+	runAt := time.Now().Add(time.Hour)
+
+	j.MarkRetry("timeout", runAt)
+
+	if j.Status != StatusQueued {
+		t.Errorf("Expected status %v, got %v", StatusQueued, j.Status)
+	}
+	if !j.NextRunAt.Equal(runAt) {
+		t.Errorf("Expected next run at %v, got %v", runAt, j.NextRunAt)
+	}
+	if j.LastError == nil || *j.LastError != "timeout" {
+		t.Errorf("Expected last error timeout, got %v", j.LastError)
+	}
+	if j.Attempts != 1 {
+		t.Errorf("Expected attempts to be kept, got %d", j.Attempts)
+	}
+}
+
+func TestJob_Requeue(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+	j.MarkRunning("worker-1", time.Minute)
+	j.MarkDead("boom")
+
+	j.Requeue()
+
+	if j.Status != StatusQueued {
+		t.Errorf("Expected status %v, got %v", StatusQueued, j.Status)
+	}
+	if j.Attempts != 0 {
+		t.Errorf("Expected attempts to be reset, got %d", j.Attempts)
+	}
+}
+
+func TestJob_MarkDead(t *testing.T) {
+	j := New(ID("01JOB"), Type("test"), "", nil)
+	j.MarkRunning("worker-1", time.Minute)
+
+	j.MarkDead("boom")
+
+	if j.Status != StatusDead {
+		t.Errorf("Expected status %v, got %v", StatusDead, j.Status)
+	}
+	if j.LastError == nil || *j.LastError != "boom" {
+		t.Errorf("Expected last error boom, got %v", j.LastError)
+	}
+	if j.LockedBy != "" || j.LockedUntil != nil {
+		t.Error("Lease should be released")
+	}
+}
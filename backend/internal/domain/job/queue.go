@@ -0,0 +1,58 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNoJobAvailable is returned by Claim when nothing is ready to run
+	ErrNoJobAvailable = errors.New("no job available")
+	// ErrLeaseLost is returned when a worker no longer owns the job it is updating
+	ErrLeaseLost = errors.New("job lease lost")
+	// ErrNotDead is returned when requeueing a job that is not dead-lettered
+	ErrNotDead = errors.New("job is not in the dead-letter queue")
+	// ErrNotFound is returned when no job matches a lookup
+	ErrNotFound = errors.New("job not found")
+)
+
+// ListParams defines pagination parameters for job list queries
+type ListParams struct {
+	Page     int
+	PageSize int
+}
+
+// PaginatedResult contains paginated job results with metadata
+type PaginatedResult struct {
+	Jobs       []*Job
+	Total      int64
+	Page       int
+	PageSize   int
+	TotalPages int
+}
+
+// JobQueue is a persistent queue of jobs. A job is claimed by a single worker
+// for the duration of its lease; jobs whose lease expires without a heartbeat
+// become claimable again so that work survives worker crashes and restarts.
+type JobQueue interface {
+	NextID() ID
+	Enqueue(ctx context.Context, job *Job) error
+	Claim(ctx context.Context, workerID string, lease time.Duration) (*Job, error)
+	Heartbeat(ctx context.Context, job *Job, lease time.Duration) error
+	Complete(ctx context.Context, job *Job) error
+	Retry(ctx context.Context, job *Job, errMsg string, runAt time.Time) error
+	DeadLetter(ctx context.Context, job *Job, errMsg string) error
+
+	// Abandon dead-letters a running job whichever worker holds its lease. It
+	// is used to give up on workers that hang while still heartbeating.
+	Abandon(ctx context.Context, job *Job, errMsg string) error
+
+	// GetByID returns the job with id, or ErrNotFound
+	GetByID(ctx context.Context, id ID) (*Job, error)
+	// FindLatest returns the most recently created job of jobType for
+	// resourceID, or ErrNotFound
+	FindLatest(ctx context.Context, jobType Type, resourceID string) (*Job, error)
+	ListDeadLetters(ctx context.Context, params ListParams) (*PaginatedResult, error)
+	Requeue(ctx context.Context, id ID) (*Job, error)
+}
@@ -0,0 +1,81 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+
+	tests := []struct {
+		attempts int
+		expected bool
+	}{
+		{1, true},
+		{2, true},
+		{3, false},
+		{4, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempts=%d", tt.attempts), func(t *testing.T) {
+			if got := p.ShouldRetry(tt.attempts); got != tt.expected {
+				t.Errorf("ShouldRetry(%d) = %v, want %v", tt.attempts, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempts=%d", tt.attempts), func(t *testing.T) {
+			if got := p.Backoff(tt.attempts); got != tt.expected {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff_Jitter(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		got := p.Backoff(3)
+		if got < 2*time.Second || got > 6*time.Second {
+			t.Fatalf("Backoff(3) = %v, want within [2s, 6s]", got)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	cause := errors.New("invalid payload")
+	err := fmt.Errorf("handler: %w", Permanent(cause))
+
+	if !IsPermanent(err) {
+		t.Error("Expected wrapped permanent error to be detected")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected permanent error to unwrap to its cause")
+	}
+	if IsPermanent(cause) {
+		t.Error("Plain error should not be permanent")
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrBlobNotFound is returned for files that are not stored as blobs, such as
+// the ones saved before deduplication was enabled
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Blob is a file stored once under the SHA-256 of its content, however many
+// times it is saved. Refs counts the saves not deleted yet.
+type Blob struct {
+	Hash string
+	Path string
+	Refs int
+}
+
+// BlobRepository counts the references to the blobs of a content-addressed
+// storage
+type BlobRepository interface {
+	// AcquireExisting adds a reference to the blob with hash and returns it,
+	// or ErrBlobNotFound. It waits for a Release of the blob in progress.
+	AcquireExisting(ctx context.Context, hash string) (*Blob, error)
+	// Acquire adds a reference to the blob with hash, recording it at path
+	// with one reference when it is new
+	Acquire(ctx context.Context, hash, path string) error
+	// Release removes a reference to the blob at path and returns how many
+	// are left. A blob left with none is removed and remove deletes its file
+	// before the blob is unlocked, keeping the reference when it fails. Paths
+	// of no blob return ErrBlobNotFound.
+	Release(ctx context.Context, path string, remove func(ctx context.Context) error) (int, error)
+}
+
+// HashingStorage is a FileStorage that knows the hash of the files it saves
+type HashingStorage interface {
+	FileStorage
+	// SaveHashed saves data like Save and also returns its ContentHash
+	SaveHashed(ctx context.Context, filename string, data []byte, contentType string) (path, hash string, err error)
+}
+
+// SharingStorage is a FileStorage that may keep one file for several saves,
+// so deleting a save does not always remove the file
+type SharingStorage interface {
+	FileStorage
+	// DeleteShared deletes the file at path like Delete and reports whether
+	// the file itself was removed
+	DeleteShared(ctx context.Context, path string) (removed bool, err error)
+}
+
+// ContentHash is the hex SHA-256 of data, the same hash extraction results
+// are cached by
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveHashed saves data in files and returns its path and ContentHash,
+// letting storages that already hash files report it
+func SaveHashed(ctx context.Context, files FileStorage, filename string, data []byte, contentType string) (string, string, error) {
+	if hashing, ok := files.(HashingStorage); ok {
+		return hashing.SaveHashed(ctx, filename, data, contentType)
+	}
+	path, err := files.Save(ctx, filename, data, contentType)
+	if err != nil {
+		return "", "", err
+	}
+	return path, ContentHash(data), nil
+}
+
+// DeleteFile deletes the file at path from files and reports whether the file
+// itself was removed, always the case for storages that do not share files
+func DeleteFile(ctx context.Context, files FileStorage, path string) (bool, error) {
+	if sharing, ok := files.(SharingStorage); ok {
+		return sharing.DeleteShared(ctx, path)
+	}
+	if err := files.Delete(ctx, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
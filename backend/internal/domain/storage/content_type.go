@@ -0,0 +1,17 @@
+package storage
+
+import "strings"
+
+const ContentTypePDF = "application/pdf"
+
+// IsSupportedContentType reports whether invoices can be uploaded as files of
+// contentType: images and PDF documents
+func IsSupportedContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || IsPDF(contentType)
+}
+
+// IsPDF reports whether contentType is a PDF document, ignoring parameters
+func IsPDF(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), ContentTypePDF)
+}
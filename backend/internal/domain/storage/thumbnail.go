@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoThumbnail is returned for files thumbnails cannot be made of, such as
+// PDF documents
+var ErrNoThumbnail = errors.New("file has no thumbnail")
+
+// ThumbnailStorage keeps reduced copies of stored images so lists do not load
+// them in full
+type ThumbnailStorage interface {
+	// Generate stores the thumbnails of the image at path in every size
+	Generate(ctx context.Context, path string) error
+	// Get returns the thumbnail of the image at path in the smallest size of
+	// at least size pixels, generating it when it was not stored yet
+	Get(ctx context.Context, path string, size int) ([]byte, error)
+	// Delete removes the thumbnails of the image at path
+	Delete(ctx context.Context, path string) error
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/gin-gonic/gin"
+)
+
+var imageHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type CacheHandler struct {
+	cache invoice.ResultCache
+}
+
+func NewCacheHandler(cache invoice.ResultCache) *CacheHandler {
+	return &CacheHandler{
+		cache: cache,
+	}
+}
+
+// InvalidateImage drops the cached extraction results of an image, identified
+// by the image_hash reported in extracted data
+func (h *CacheHandler) InvalidateImage(c *gin.Context) {
+	imageHash := strings.ToLower(c.Param("image_hash"))
+	if !imageHashPattern.MatchString(imageHash) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid image hash, expected a hex SHA-256",
+		})
+		return
+	}
+
+	removed, err := h.cache.InvalidateImage(c.Request.Context(), imageHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to invalidate cache: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    gin.H{"removed": removed},
+	})
+}
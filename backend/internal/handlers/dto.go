@@ -2,7 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
 )
 
 type ErrorResponse struct {
@@ -25,32 +30,53 @@ type PaginatedInvoicesResponse struct {
 }
 
 type InvoiceData struct {
-	ID            string      `json:"id"`
-	Status        string      `json:"status"`
-	ImagePath     string      `json:"image_path"`
-	CreatedAt     string      `json:"created_at"`
-	UpdatedAt     string      `json:"updated_at,omitempty"`
-	ExtractedData interface{} `json:"extracted_data,omitempty"`
-	ErrorMessage  *string     `json:"error_message,omitempty"`
+	ID                 string            `json:"id"`
+	Status             string            `json:"status"`
+	TenantID           string            `json:"tenant_id,omitempty"`
+	ImagePath          string            `json:"image_path"`
+	ThumbnailURL       string            `json:"thumbnail_url,omitempty"`
+	CreatedAt          string            `json:"created_at"`
+	UpdatedAt          string            `json:"updated_at,omitempty"`
+	ExtractedData      interface{}       `json:"extracted_data,omitempty"`
+	Fields             *FieldsData       `json:"fields,omitempty"`
+	Findings           []invoice.Finding `json:"findings,omitempty"`
+	PromptVersion      string            `json:"prompt_version,omitempty"`
+	PageCount          int               `json:"page_count"`
+	Pages              []PageData        `json:"pages,omitempty"`
+	ErrorMessage       *string           `json:"error_message,omitempty"`
+	ExtractionAttempts int               `json:"extraction_attempts"`
 }
 
 func NewInvoiceData(inv *invoice.Invoice, imageURL string) InvoiceData {
 	data := InvoiceData{
-		ID:        inv.ID.String(),
-		Status:    inv.Status.String(),
-		ImagePath: imageURL,
-		CreatedAt: inv.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:                 inv.ID.String(),
+		Status:             inv.Status.String(),
+		TenantID:           inv.TenantID,
+		ImagePath:          imageURL,
+		CreatedAt:          inv.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		PageCount:          inv.PageCount,
+		ExtractionAttempts: inv.ExtractionAttempts,
+	}
+
+	// PDF documents have no thumbnail
+	if inv.ImagePath != "" && !strings.EqualFold(filepath.Ext(inv.ImagePath), ".pdf") {
+		data.ThumbnailURL = fmt.Sprintf("/api/v1/invoices/%s/thumbnail", inv.ID.String())
 	}
 
 	if !inv.UpdatedAt.IsZero() {
 		data.UpdatedAt = inv.UpdatedAt.Format("2006-01-02T15:04:05Z07:00")
 	}
 
-	if inv.Status == invoice.StatusCompleted && len(inv.ExtractedData) > 0 {
+	if inv.IsExtracted() && len(inv.ExtractedData) > 0 {
 		var extractedData interface{}
 		if err := json.Unmarshal(inv.ExtractedData, &extractedData); err == nil {
 			data.ExtractedData = extractedData
+			data.PromptVersion = inv.PromptVersion
+		}
+		if !inv.Fields.IsZero() {
+			data.Fields = NewFieldsData(inv.Fields)
 		}
+		data.Findings = inv.Findings
 	}
 
 	if inv.ErrorMessage != nil {
@@ -60,7 +86,160 @@ func NewInvoiceData(inv *invoice.Invoice, imageURL string) InvoiceData {
 	return data
 }
 
+// FieldsData is the normalized fields of an invoice, with the issue date as
+// YYYY-MM-DD
+type FieldsData struct {
+	invoice.Fields
+	IssueDate string `json:"issue_date,omitempty"`
+}
+
+func NewFieldsData(fields invoice.Fields) *FieldsData {
+	data := &FieldsData{Fields: fields}
+	if fields.IssueDate != nil {
+		data.IssueDate = fields.IssueDate.Format("2006-01-02")
+	}
+	return data
+}
+
+type PageData struct {
+	Number       int    `json:"number"`
+	ImagePath    string `json:"image_path"`
+	OriginalPath string `json:"original_path,omitempty"`
+	ImageHash    string `json:"image_hash,omitempty"`
+	MIMEType     string `json:"mime_type,omitempty"`
+}
+
+// NewPagesData describes pages with the URLs url returns for their files
+func NewPagesData(pages invoice.Pages, url func(path string) string) []PageData {
+	data := make([]PageData, len(pages))
+	for i, page := range pages {
+		data[i] = PageData{
+			Number:    page.Number,
+			ImagePath: url(page.ImagePath),
+			ImageHash: page.ImageHash,
+			MIMEType:  page.MIMEType,
+		}
+		if page.OriginalPath != "" {
+			data[i].OriginalPath = url(page.OriginalPath)
+		}
+	}
+	return data
+}
+
+type ReprocessInvoiceRequest struct {
+	Model         string   `json:"model"`
+	PromptVersion string   `json:"prompt_version"`
+	Language      string   `json:"language"`
+	Currency      string   `json:"currency"`
+	CustomFields  []string `json:"custom_fields"`
+}
+
+type RevisionData struct {
+	Revision      int         `json:"revision"`
+	ExtractedData interface{} `json:"extracted_data,omitempty"`
+	PromptVersion string      `json:"prompt_version,omitempty"`
+	CreatedAt     string      `json:"created_at"`
+}
+
+func NewRevisionData(rev *invoice.Revision) RevisionData {
+	data := RevisionData{
+		Revision:      rev.Number,
+		PromptVersion: rev.PromptVersion,
+		CreatedAt:     rev.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	var extractedData interface{}
+	if err := json.Unmarshal(rev.ExtractedData, &extractedData); err == nil {
+		data.ExtractedData = extractedData
+	}
+
+	return data
+}
+
+type PaginatedJobsResponse struct {
+	Success    bool      `json:"success"`
+	Data       []JobData `json:"data"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page"`
+	PageSize   int       `json:"page_size"`
+	TotalPages int       `json:"total_pages"`
+}
+
+type JobData struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Attempts  int             `json:"attempts"`
+	LastError *string         `json:"last_error,omitempty"`
+	NextRunAt string          `json:"next_run_at"`
+	CreatedAt string          `json:"created_at"`
+	UpdatedAt string          `json:"updated_at"`
+}
+
+func NewJobData(j *job.Job) JobData {
+	return JobData{
+		ID:        j.ID.String(),
+		Type:      j.Type.String(),
+		Status:    j.Status.String(),
+		Payload:   j.Payload,
+		Attempts:  j.Attempts,
+		LastError: j.LastError,
+		NextRunAt: j.NextRunAt.Format("2006-01-02T15:04:05Z07:00"),
+		CreatedAt: j.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: j.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
 type UpdateInvoiceRequest struct {
 	ExtractedData json.RawMessage `json:"extracted_data" binding:"required"`
 }
 
+type ExtractionAttemptData struct {
+	ID           int64   `json:"id"`
+	JobID        string  `json:"job_id,omitempty"`
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model,omitempty"`
+	PromptTokens int     `json:"prompt_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	LatencyMs    int64   `json:"latency_ms"`
+	CostUSD      float64 `json:"cost_usd"`
+	Succeeded    bool    `json:"succeeded"`
+	ErrorMessage *string `json:"error_message,omitempty"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+func NewExtractionAttemptData(attempt *invoice.ExtractionAttempt) ExtractionAttemptData {
+	return ExtractionAttemptData{
+		ID:           attempt.ID,
+		JobID:        attempt.JobID,
+		Provider:     attempt.Provider,
+		Model:        attempt.Model,
+		PromptTokens: attempt.PromptTokens,
+		OutputTokens: attempt.OutputTokens,
+		TotalTokens:  attempt.TotalTokens,
+		LatencyMs:    attempt.LatencyMs,
+		CostUSD:      attempt.CostUSD,
+		Succeeded:    attempt.Succeeded,
+		ErrorMessage: attempt.ErrorMessage,
+		CreatedAt:    attempt.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+type UsageSummaryData struct {
+	Day          string  `json:"day"`
+	Model        string  `json:"model"`
+	TenantID     string  `json:"tenant_id"`
+	Attempts     int64   `json:"attempts"`
+	Failed       int64   `json:"failed"`
+	Invoices     int64   `json:"invoices"`
+	PromptTokens int64   `json:"prompt_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+func NewUsageSummaryData(summary invoice.UsageSummary) UsageSummaryData {
+	return UsageSummaryData(summary)
+}
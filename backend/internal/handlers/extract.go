@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"io"
+	"context"
 	"net/http"
 	"strings"
 	"time"
 
 	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/pkg/log"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,79 +21,51 @@ type ExtractResponse struct {
 
 type ExtractHandler struct {
 	extractionService invoice.ExtractionService
+	preprocessor      invoice.ImagePreprocessor
+	usageRepo         invoice.UsageRepository
+	pricing           invoice.Pricing
 }
 
-func NewExtractHandler(extractionService invoice.ExtractionService) *ExtractHandler {
+func NewExtractHandler(extractionService invoice.ExtractionService, preprocessor invoice.ImagePreprocessor, usageRepo invoice.UsageRepository, pricing invoice.Pricing) *ExtractHandler {
 	return &ExtractHandler{
 		extractionService: extractionService,
+		preprocessor:      preprocessor,
+		usageRepo:         usageRepo,
+		pricing:           pricing,
 	}
 }
 
 func (h *ExtractHandler) Extract(c *gin.Context) {
 	startTime := time.Now()
 
-	file, err := c.FormFile("image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ExtractResponse{
-			Success: false,
-			Error:   "Image file is required: " + err.Error(),
-		})
-		return
-	}
-
-	if file.Size == 0 {
-		c.JSON(http.StatusBadRequest, ExtractResponse{
-			Success: false,
-			Error:   "Image file is empty",
-		})
-		return
-	}
-
-	const maxSize = 10 * 1024 * 1024
-	if file.Size > maxSize {
+	tenantID, ok := getTenantID(c)
+	if !ok {
 		c.JSON(http.StatusBadRequest, ExtractResponse{
 			Success: false,
-			Error:   "Image file too large (max 10MB)",
+			Error:   "Invalid X-Tenant-ID header",
 		})
 		return
 	}
 
-	src, err := file.Open()
+	images, err := readImages(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ExtractResponse{
 			Success: false,
-			Error:   "Failed to open image file: " + err.Error(),
-		})
-		return
-	}
-	defer src.Close()
-
-	imageBytes, err := io.ReadAll(src)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, ExtractResponse{
-			Success: false,
-			Error:   "Failed to read image file: " + err.Error(),
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	if len(imageBytes) == 0 {
-		c.JSON(http.StatusBadRequest, ExtractResponse{
-			Success: false,
-			Error:   "Image file is empty",
-		})
-		return
-	}
+	preprocessImages(c.Request.Context(), h.preprocessor, images)
 
-	mimeType := file.Header.Get("Content-Type")
-	if mimeType == "" {
-		mimeType = http.DetectContentType(imageBytes)
-	}
+	opts := getFormExtractOptions(c)
+	opts.TenantID = tenantID
 
-	invoiceData, err := h.extractionService.Extract(c.Request.Context(), imageBytes, mimeType)
+	invoiceData, err := h.extractionService.Extract(c.Request.Context(), extractionImages(images), opts)
+	h.recordAttempts(c, tenantID, invoiceData.Usage, err)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "gemini API") || strings.Contains(err.Error(), "Gemini API") {
+		if strings.Contains(err.Error(), "gemini API") || strings.Contains(err.Error(), "Gemini API") || strings.Contains(err.Error(), "openai API") {
 			statusCode = http.StatusBadGateway
 		} else if strings.Contains(err.Error(), "timeout") || strings.Contains(err.Error(), "context deadline exceeded") {
 			statusCode = http.StatusGatewayTimeout
@@ -109,9 +82,30 @@ func (h *ExtractHandler) Extract(c *gin.Context) {
 
 	processingTime := time.Since(startTime).Milliseconds()
 
+	if invoiceData.Cache != nil {
+		cacheStatus := "MISS"
+		if invoiceData.Cache.Hit {
+			cacheStatus = "HIT"
+		}
+		c.Header("X-Cache", cacheStatus)
+	}
+
 	c.JSON(http.StatusOK, ExtractResponse{
 		Success:        true,
 		Data:           invoiceData,
 		ProcessingTime: &processingTime,
 	})
 }
+
+// recordAttempts stores the usage of an extraction that is not kept as an
+// invoice, so its cost still shows up in the usage summary
+func (h *ExtractHandler) recordAttempts(c *gin.Context, tenantID string, usage []invoice.Usage, extractErr error) {
+	attempts := invoice.NewExtractionAttempts(usage, extractErr, h.pricing)
+	for _, attempt := range attempts {
+		attempt.TenantID = tenantID
+	}
+
+	if err := h.usageRepo.CreateAttempts(context.WithoutCancel(c.Request.Context()), attempts); err != nil {
+		log.Warnf("failed to record extraction attempts: %v", err)
+	}
+}
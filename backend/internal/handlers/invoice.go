@@ -2,111 +2,183 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"invoice-scan/backend/internal/domain"
 	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
 	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 type InvoiceHandler struct {
-	repo              invoice.Repository
-	storage           domainstorage.FileStorage
-	extractionService invoice.ExtractionService
+	repo      invoice.Repository
+	storage   domainstorage.FileStorage
+	jobQueue  job.JobQueue
+	txManager domain.TransactionManager
+	// preprocessor prepares uploaded photos for extraction, nil keeps them as
+	// uploaded
+	preprocessor invoice.ImagePreprocessor
+	thumbnails   domainstorage.ThumbnailStorage
 }
 
 func NewInvoiceHandler(
 	repo invoice.Repository,
 	storage domainstorage.FileStorage,
-	extractionService invoice.ExtractionService,
+	jobQueue job.JobQueue,
+	txManager domain.TransactionManager,
+	preprocessor invoice.ImagePreprocessor,
+	thumbnails domainstorage.ThumbnailStorage,
 ) *InvoiceHandler {
 	return &InvoiceHandler{
-		repo:              repo,
-		storage:           storage,
-		extractionService: extractionService,
+		repo:         repo,
+		storage:      storage,
+		jobQueue:     jobQueue,
+		txManager:    txManager,
+		preprocessor: preprocessor,
+		thumbnails:   thumbnails,
 	}
 }
 
 func (h *InvoiceHandler) Upload(c *gin.Context) {
-	file, err := c.FormFile("image")
-	if err != nil {
+	tenantID, ok := getTenantID(c)
+	if !ok {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Error:   "Image file is required: " + err.Error(),
+			Error:   "Invalid X-Tenant-ID header",
 		})
 		return
 	}
 
-	if file.Size == 0 {
+	images, err := readImages(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Error:   "Image file is empty",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	const maxSize = 10 * 1024 * 1024
-	if file.Size > maxSize {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+	preprocessImages(c.Request.Context(), h.preprocessor, images)
+
+	id := h.repo.NextID()
+	pages, err := h.savePages(c.Request.Context(), id, 1, images)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
-			Error:   "Image file too large (max 10MB)",
+			Error:   "Failed to save image: " + err.Error(),
 		})
 		return
 	}
 
-	src, err := file.Open()
+	// a draft waits for more pages until POST /invoices/:id/extract starts its
+	// extraction
+	draft, _ := strconv.ParseBool(c.PostForm("draft"))
+
+	inv := invoice.New(id, pages[0].ImagePath)
+	if draft {
+		inv = invoice.NewDraft(id, pages[0].ImagePath)
+	}
+	inv.TenantID = tenantID
+	inv.PageCount = len(pages)
+
+	persist := func(txCtx context.Context) error {
+		if err := h.repo.Create(txCtx, inv); err != nil {
+			return err
+		}
+		return h.repo.AddPages(txCtx, pages)
+	}
+	if draft {
+		err = h.withTx(c.Request.Context(), persist)
+	} else {
+		payload := invoice.ExtractionJobPayload{
+			InvoiceID:      inv.ID,
+			ExtractOptions: getFormExtractOptions(c),
+		}
+		err = h.withExtractionJob(c.Request.Context(), payload, persist)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		h.deletePageFiles(c.Request.Context(), pages)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
-			Error:   "Failed to open image file: " + err.Error(),
+			Error:   "Failed to create invoice: " + err.Error(),
 		})
 		return
 	}
-	defer src.Close()
 
-	imageBytes, err := io.ReadAll(src)
+	h.generateThumbnails(c.Request.Context(), pages)
+
+	data := NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath))
+	data.Pages = NewPagesData(pages, h.storage.GetURL)
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// AddPages appends the image parts of the request to a draft invoice, after
+// its current pages
+func (h *InvoiceHandler) AddPages(c *gin.Context) {
+	idStr := c.Param("id")
+	id := invoice.ID(idStr)
+
+	inv, err := h.repo.GetByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found",
+		})
+		return
+	}
+
+	if !inv.AcceptsPages() {
+		c.JSON(http.StatusConflict, ErrorResponse{
 			Success: false,
-			Error:   "Failed to read image file: " + err.Error(),
+			Error:   "Pages can only be added to draft invoices",
 		})
 		return
 	}
 
-	if len(imageBytes) == 0 {
+	images, err := readImages(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Error:   "Image file is empty",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	mimeType := file.Header.Get("Content-Type")
-	if mimeType == "" {
-		mimeType = http.DetectContentType(imageBytes)
+	existing, err := h.repo.ListPages(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list pages: " + err.Error(),
+		})
+		return
 	}
 
-	if !strings.HasPrefix(mimeType, "image/") {
+	if len(existing)+len(images) > invoice.MaxPages {
 		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Error:   "Invalid file type. Only images are allowed",
+			Error:   fmt.Sprintf("Too many pages (max %d)", invoice.MaxPages),
 		})
 		return
 	}
 
-	id := h.repo.NextID()
-	filename := fmt.Sprintf("%s%s", id.String(), filepath.Ext(file.Filename))
+	preprocessImages(c.Request.Context(), h.preprocessor, images)
 
-	imagePath, err := h.storage.Save(c.Request.Context(), filename, imageBytes, mimeType)
+	pages, err := h.savePages(c.Request.Context(), id, len(existing)+1, images)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
@@ -115,18 +187,28 @@ func (h *InvoiceHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	inv := invoice.New(id, imagePath)
-	if err := h.repo.Create(c.Request.Context(), inv); err != nil {
+	if err := h.withTx(c.Request.Context(), func(txCtx context.Context) error {
+		if err := h.repo.AddPages(txCtx, pages); err != nil {
+			return err
+		}
+		return h.repo.Update(txCtx, inv, func(i *invoice.Invoice) error {
+			i.PageCount = len(existing) + len(pages)
+			i.UpdatedAt = time.Now()
+			return nil
+		})
+	}); err != nil {
+		h.deletePageFiles(c.Request.Context(), pages)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Success: false,
-			Error:   "Failed to create invoice: " + err.Error(),
+			Error:   "Failed to add pages: " + err.Error(),
 		})
 		return
 	}
 
-	h.processExtractionAsync(id, imageBytes, mimeType)
+	h.generateThumbnails(c.Request.Context(), pages)
 
-	data := NewInvoiceData(inv, getImagePath(inv.ImagePath))
+	data := NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath))
+	data.Pages = NewPagesData(append(existing, pages...), h.storage.GetURL)
 
 	c.JSON(http.StatusOK, SuccessResponse{
 		Success: true,
@@ -134,53 +216,101 @@ func (h *InvoiceHandler) Upload(c *gin.Context) {
 	})
 }
 
-func (h *InvoiceHandler) processExtractionAsync(invoiceID invoice.ID, imageBytes []byte, mimeType string) {
-	go func() {
-		ctx := context.Background()
-
-		inv, err := h.repo.GetByID(ctx, invoiceID)
-		if err != nil {
-			log.Printf("Failed to get invoice %s: %v", invoiceID.String(), err)
-			return
+// savePages stores uploaded images as the pages of an invoice numbered from
+// firstNumber. The first page of an invoice is named after the invoice alone,
+// the originals of preprocessed images get an -original suffix. Pages saved
+// before a failure are deleted again.
+func (h *InvoiceHandler) savePages(ctx context.Context, id invoice.ID, firstNumber int, images []uploadedImage) (invoice.Pages, error) {
+	pages := make(invoice.Pages, 0, len(images))
+	for i, image := range images {
+		number := firstNumber + i
+
+		name := id.String()
+		if number > 1 {
+			name = fmt.Sprintf("%s-%d", id.String(), number)
 		}
 
-		if err := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
-			i.MarkProcessing()
-			return nil
-		}); err != nil {
-			log.Printf("Failed to update invoice %s to processing: %v", invoiceID.String(), err)
-			return
+		ext := filepath.Ext(image.Filename)
+		if image.Original != nil {
+			// preprocessed images are JPEG whatever was uploaded
+			ext = ".jpg"
 		}
 
-		data, err := h.extractionService.Extract(ctx, imageBytes, mimeType)
+		imagePath, imageHash, err := domainstorage.SaveHashed(ctx, h.storage, name+ext, image.Bytes, image.MIMEType)
 		if err != nil {
-			if updateErr := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
-				i.MarkFailed(err.Error())
-				return nil
-			}); updateErr != nil {
-				log.Printf("Failed to update invoice %s to failed: %v", invoiceID.String(), updateErr)
+			h.deletePageFiles(ctx, pages)
+			return nil, err
+		}
+		page := invoice.NewPage(id, number, imagePath, image.MIMEType)
+		page.ImageHash = imageHash
+		pages = append(pages, page)
+
+		if image.Original != nil {
+			page.OriginalPath, err = h.storage.Save(ctx, name+"-original"+filepath.Ext(image.Filename), image.Original.Bytes, image.Original.MIMEType)
+			if err != nil {
+				h.deletePageFiles(ctx, pages)
+				return nil, err
 			}
-			return
 		}
+	}
+	return pages, nil
+}
 
-		dataJSON, err := json.Marshal(data)
-		if err != nil {
-			if updateErr := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
-				i.MarkFailed("Failed to marshal extracted data: " + err.Error())
-				return nil
-			}); updateErr != nil {
-				log.Printf("Failed to update invoice %s to failed: %v", invoiceID.String(), updateErr)
+// generateThumbnails makes the thumbnails of new pages. A page without them
+// still gets them on first request, so failures are only logged.
+func (h *InvoiceHandler) generateThumbnails(ctx context.Context, pages invoice.Pages) {
+	for _, page := range pages {
+		if err := h.thumbnails.Generate(ctx, page.ImagePath); err != nil && !errors.Is(err, domainstorage.ErrNoThumbnail) {
+			log.Printf("Failed to generate thumbnails of %s: %v", page.ImagePath, err)
+		}
+	}
+}
+
+// deletePageFiles deletes the files of pages. The thumbnails of an image are
+// kept while other invoices still share the image.
+func (h *InvoiceHandler) deletePageFiles(ctx context.Context, pages invoice.Pages) {
+	for _, page := range pages {
+		if page.ImagePath != "" {
+			removed, err := domainstorage.DeleteFile(ctx, h.storage, page.ImagePath)
+			if err != nil {
+				log.Printf("Failed to delete image file %s: %v", page.ImagePath, err)
+			}
+			if removed {
+				if err := h.thumbnails.Delete(ctx, page.ImagePath); err != nil {
+					log.Printf("Failed to delete thumbnails of %s: %v", page.ImagePath, err)
+				}
+			}
+		}
+		if page.OriginalPath != "" {
+			if err := h.storage.Delete(ctx, page.OriginalPath); err != nil {
+				log.Printf("Failed to delete image file %s: %v", page.OriginalPath, err)
 			}
-			return
 		}
+	}
+}
 
-		if err := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
-			i.MarkCompleted(dataJSON)
-			return nil
-		}); err != nil {
-			log.Printf("Failed to update invoice %s to completed: %v", invoiceID.String(), err)
+// withTx runs fn in a transaction
+func (h *InvoiceHandler) withTx(ctx context.Context, fn func(txCtx context.Context) error) error {
+	tm := h.txManager.TxBegin()
+	defer tm.RecoverTx()
+
+	return tm.EndTx(fn(tm.AssignToContext(ctx)))
+}
+
+// withExtractionJob runs persist and queues an extraction job in one
+// transaction so an invoice is never left waiting without a job to process it
+func (h *InvoiceHandler) withExtractionJob(ctx context.Context, payload invoice.ExtractionJobPayload, persist func(txCtx context.Context) error) error {
+	extractionJob, err := invoice.NewExtractionJob(h.jobQueue.NextID(), payload)
+	if err != nil {
+		return err
+	}
+
+	return h.withTx(ctx, func(txCtx context.Context) error {
+		if err := persist(txCtx); err != nil {
+			return err
 		}
-	}()
+		return h.jobQueue.Enqueue(txCtx, extractionJob)
+	})
 }
 
 func (h *InvoiceHandler) List(c *gin.Context) {
@@ -210,7 +340,7 @@ func (h *InvoiceHandler) List(c *gin.Context) {
 
 	data := make([]InvoiceData, len(result.Invoices))
 	for i, inv := range result.Invoices {
-		data[i] = NewInvoiceData(inv, getImagePath(inv.ImagePath))
+		data[i] = NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath))
 	}
 
 	c.JSON(http.StatusOK, PaginatedInvoicesResponse{
@@ -236,7 +366,17 @@ func (h *InvoiceHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	data := NewInvoiceData(inv, getImagePath(inv.ImagePath))
+	pages, err := h.repo.ListPages(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list pages: " + err.Error(),
+		})
+		return
+	}
+
+	data := NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath))
+	data.Pages = NewPagesData(pages, h.storage.GetURL)
 
 	c.JSON(http.StatusOK, SuccessResponse{
 		Success: true,
@@ -244,6 +384,54 @@ func (h *InvoiceHandler) GetByID(c *gin.Context) {
 	})
 }
 
+// Thumbnail serves a reduced copy of the first page of an invoice. The size
+// query parameter asks for its longest side in pixels, the smallest stored
+// size by default. Pages never change once uploaded, so thumbnails are cached
+// for good.
+func (h *InvoiceHandler) Thumbnail(c *gin.Context) {
+	id := invoice.ID(c.Param("id"))
+
+	size := 0
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		var err error
+		if size, err = strconv.Atoi(sizeStr); err != nil || size <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Success: false,
+				Error:   "Invalid size",
+			})
+			return
+		}
+	}
+
+	inv, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found",
+		})
+		return
+	}
+
+	thumbnail, err := h.thumbnails.Get(c.Request.Context(), inv.ImagePath, size)
+	if errors.Is(err, domainstorage.ErrNoThumbnail) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice has no thumbnail",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to get thumbnail: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", middleware.ImmutableCacheControl)
+	c.Data(http.StatusOK, "image/jpeg", thumbnail)
+}
+
 func (h *InvoiceHandler) Delete(c *gin.Context) {
 	idStr := c.Param("id")
 	id := invoice.ID(idStr)
@@ -257,12 +445,19 @@ func (h *InvoiceHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// Delete image file
-	if inv.ImagePath != "" {
-		if err := h.storage.Delete(c.Request.Context(), inv.ImagePath); err != nil {
-			log.Printf("Failed to delete image file %s: %v", inv.ImagePath, err)
-		}
+	// Delete image files
+	pages, err := h.repo.ListPages(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list pages: " + err.Error(),
+		})
+		return
+	}
+	if len(pages) == 0 && inv.ImagePath != "" {
+		pages = invoice.Pages{invoice.NewPage(inv.ID, 1, inv.ImagePath, "")}
 	}
+	h.deletePageFiles(c.Request.Context(), pages)
 
 	// Delete database record
 	if err := h.repo.Delete(c.Request.Context(), id); err != nil {
@@ -302,7 +497,7 @@ func (h *InvoiceHandler) Update(c *gin.Context) {
 	}
 
 	if err := h.repo.Update(c.Request.Context(), inv, func(i *invoice.Invoice) error {
-		i.ExtractedData = req.ExtractedData
+		i.SetExtractedData(req.ExtractedData)
 		i.UpdatedAt = time.Now()
 		return nil
 	}); err != nil {
@@ -313,7 +508,127 @@ func (h *InvoiceHandler) Update(c *gin.Context) {
 		return
 	}
 
-	data := NewInvoiceData(inv, getImagePath(inv.ImagePath))
+	data := NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath))
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// errExtractionInProgress is returned by Reprocess transactions that find an
+// extraction already queued
+var errExtractionInProgress = errors.New("extraction is already in progress")
+
+// Reprocess queues a fresh extraction of the stored pages. The current
+// extracted data is kept as a revision once the new result replaces it. For a
+// draft invoice it starts the first extraction once all pages are added.
+func (h *InvoiceHandler) Reprocess(c *gin.Context) {
+	idStr := c.Param("id")
+	id := invoice.ID(idStr)
+
+	var req ReprocessInvoiceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Success: false,
+				Error:   "Invalid request body: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	inv, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found",
+		})
+		return
+	}
+
+	if inv.IsExtracting() {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Extraction is already in progress",
+		})
+		return
+	}
+
+	payload := invoice.ExtractionJobPayload{
+		InvoiceID: inv.ID,
+		ExtractOptions: invoice.ExtractOptions{
+			Model:         req.Model,
+			PromptVersion: req.PromptVersion,
+			Language:      req.Language,
+			Currency:      req.Currency,
+			CustomFields:  req.CustomFields,
+			// a re-run asks for a fresh result, which then replaces the cached one
+			SkipCache: inv.Status != invoice.StatusDraft,
+		},
+	}
+	err = h.withExtractionJob(c.Request.Context(), payload, func(txCtx context.Context) error {
+		// checked again with the row locked, so concurrent requests queue one
+		// extraction
+		locked, err := h.repo.GetForUpdate(txCtx, id)
+		if err != nil {
+			return err
+		}
+		if locked.IsExtracting() {
+			return errExtractionInProgress
+		}
+		inv = locked
+		return h.repo.Update(txCtx, inv, func(i *invoice.Invoice) error {
+			i.MarkPending()
+			return nil
+		})
+	})
+	if errors.Is(err, errExtractionInProgress) {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Extraction is already in progress",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to queue extraction: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Data:    NewInvoiceData(inv, h.storage.GetURL(inv.ImagePath)),
+	})
+}
+
+func (h *InvoiceHandler) ListRevisions(c *gin.Context) {
+	idStr := c.Param("id")
+	id := invoice.ID(idStr)
+
+	if _, err := h.repo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found",
+		})
+		return
+	}
+
+	revisions, err := h.repo.ListRevisions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list revisions: " + err.Error(),
+		})
+		return
+	}
+
+	data := make([]RevisionData, len(revisions))
+	for i, rev := range revisions {
+		data[i] = NewRevisionData(rev)
+	}
 
 	c.JSON(http.StatusOK, SuccessResponse{
 		Success: true,
@@ -321,8 +636,35 @@ func (h *InvoiceHandler) Update(c *gin.Context) {
 	})
 }
 
+// tenantIDPattern keeps tenant IDs usable as prompt template directories
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// getTenantID reads the optional X-Tenant-ID header. ok is false when the
+// header is set to an invalid ID.
+func getTenantID(c *gin.Context) (tenantID string, ok bool) {
+	tenantID = strings.TrimSpace(c.GetHeader("X-Tenant-ID"))
+	if tenantID != "" && !tenantIDPattern.MatchString(tenantID) {
+		return "", false
+	}
+	return tenantID, true
+}
+
+// getFormExtractOptions reads the prompt variables sent along with an upload.
+// custom_fields may be repeated or comma separated.
+func getFormExtractOptions(c *gin.Context) invoice.ExtractOptions {
+	var customFields []string
+	for _, value := range c.PostFormArray("custom_fields") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				customFields = append(customFields, field)
+			}
+		}
+	}
 
-func getImagePath(fullPath string) string {
-	filename := filepath.Base(fullPath)
-	return fmt.Sprintf("/uploads/%s", filename)
+	return invoice.ExtractOptions{
+		PromptVersion: c.PostForm("prompt_version"),
+		Language:      c.PostForm("language"),
+		Currency:      c.PostForm("currency"),
+		CustomFields:  customFields,
+	}
 }
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"invoice-scan/backend/internal/domain"
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	jobQueue    job.JobQueue
+	invoiceRepo invoice.Repository
+	txManager   domain.TransactionManager
+}
+
+func NewJobHandler(jobQueue job.JobQueue, invoiceRepo invoice.Repository, txManager domain.TransactionManager) *JobHandler {
+	return &JobHandler{
+		jobQueue:    jobQueue,
+		invoiceRepo: invoiceRepo,
+		txManager:   txManager,
+	}
+}
+
+func (h *JobHandler) ListDeadLetters(c *gin.Context) {
+	params := job.ListParams{Page: 1, PageSize: 10}
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if page, err := strconv.Atoi(pageStr); err == nil && page > 0 {
+			params.Page = page
+		}
+	}
+
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if pageSize, err := strconv.Atoi(pageSizeStr); err == nil && pageSize > 0 && pageSize <= 100 {
+			params.PageSize = pageSize
+		}
+	}
+
+	result, err := h.jobQueue.ListDeadLetters(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list dead-letter jobs: " + err.Error(),
+		})
+		return
+	}
+
+	data := make([]JobData, len(result.Jobs))
+	for i, j := range result.Jobs {
+		data[i] = NewJobData(j)
+	}
+
+	c.JSON(http.StatusOK, PaginatedJobsResponse{
+		Success:    true,
+		Data:       data,
+		Total:      result.Total,
+		Page:       result.Page,
+		PageSize:   result.PageSize,
+		TotalPages: result.TotalPages,
+	})
+}
+
+func (h *JobHandler) Requeue(c *gin.Context) {
+	id := job.ID(c.Param("id"))
+
+	// the job and its invoice are reset together, so a requeued job never
+	// leaves its invoice failed
+	var j *job.Job
+	tm := h.txManager.TxBegin()
+	defer tm.RecoverTx()
+	err := tm.EndTx(func(txCtx context.Context) error {
+		var err error
+		if j, err = h.jobQueue.Requeue(txCtx, id); err != nil {
+			return err
+		}
+		return h.resetInvoice(txCtx, j)
+	}(tm.AssignToContext(c.Request.Context())))
+	if err != nil {
+		switch {
+		case errors.Is(err, job.ErrNotDead), errors.Is(err, errInvoiceDeleted):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		case errors.Is(err, job.ErrNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Success: false,
+				Error:   "Job not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Success: false,
+				Error:   "Failed to requeue job: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    NewJobData(j),
+	})
+}
+
+// errInvoiceDeleted is returned for extraction jobs whose invoice no longer
+// exists, which there is no point in running again
+var errInvoiceDeleted = errors.New("the invoice of the job was deleted")
+
+// resetInvoice marks the invoice of a requeued extraction job pending again
+func (h *JobHandler) resetInvoice(ctx context.Context, j *job.Job) error {
+	if j.Type != invoice.JobTypeExtraction {
+		return nil
+	}
+
+	var payload invoice.ExtractionJobPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return fmt.Errorf("invalid extraction job payload: %w", err)
+	}
+	inv, err := h.invoiceRepo.GetByID(ctx, payload.InvoiceID)
+	if errors.Is(err, invoice.ErrNotFound) {
+		return errInvoiceDeleted
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get invoice %s: %w", payload.InvoiceID, err)
+	}
+	return h.invoiceRepo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkPending()
+		return nil
+	})
+}
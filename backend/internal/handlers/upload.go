@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxImageSize = 10 * 1024 * 1024
+
+// uploadedImage is an image part of a multipart request
+type uploadedImage struct {
+	Filename string
+	invoice.Image
+	// Original is the image as uploaded when Image was preprocessed
+	Original *invoice.Image
+}
+
+// readImages reads the image parts of a multipart request in the order they
+// were sent, one per page. Errors are meant to be shown to the client.
+func readImages(c *gin.Context) ([]uploadedImage, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, fmt.Errorf("Image file is required: %w", err)
+	}
+
+	files := form.File["image"]
+	if len(files) == 0 {
+		return nil, errors.New("Image file is required")
+	}
+	if len(files) > invoice.MaxPages {
+		return nil, fmt.Errorf("Too many images (max %d)", invoice.MaxPages)
+	}
+
+	images := make([]uploadedImage, len(files))
+	for i, file := range files {
+		image, err := readImage(file)
+		if err != nil {
+			if len(files) > 1 {
+				err = fmt.Errorf("Image %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+		images[i] = image
+	}
+	return images, nil
+}
+
+func readImage(file *multipart.FileHeader) (uploadedImage, error) {
+	if file.Size == 0 {
+		return uploadedImage{}, errors.New("Image file is empty")
+	}
+
+	if file.Size > maxImageSize {
+		return uploadedImage{}, errors.New("Image file too large (max 10MB)")
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return uploadedImage{}, fmt.Errorf("Failed to open image file: %w", err)
+	}
+	defer src.Close()
+
+	imageBytes, err := io.ReadAll(src)
+	if err != nil {
+		return uploadedImage{}, fmt.Errorf("Failed to read image file: %w", err)
+	}
+
+	if len(imageBytes) == 0 {
+		return uploadedImage{}, errors.New("Image file is empty")
+	}
+
+	mimeType := file.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = http.DetectContentType(imageBytes)
+	}
+
+	if !domainstorage.IsSupportedContentType(mimeType) {
+		return uploadedImage{}, errors.New("Invalid file type. Only images and PDF documents are allowed")
+	}
+
+	return uploadedImage{
+		Filename: file.Filename,
+		Image:    invoice.Image{Bytes: imageBytes, MIMEType: mimeType},
+	}, nil
+}
+
+// preprocessImages replaces uploaded photos by their preprocessed version,
+// keeping the original. PDF documents, and images the preprocessor cannot
+// handle, are extracted as uploaded.
+func preprocessImages(ctx context.Context, preprocessor invoice.ImagePreprocessor, images []uploadedImage) {
+	if preprocessor == nil {
+		return
+	}
+
+	for i := range images {
+		if domainstorage.IsPDF(images[i].MIMEType) {
+			continue
+		}
+
+		processed, err := preprocessor.Preprocess(ctx, images[i].Image)
+		if err != nil {
+			log.Printf("Failed to preprocess image %s, keeping it as uploaded: %v", images[i].Filename, err)
+			continue
+		}
+
+		original := images[i].Image
+		images[i].Original = &original
+		images[i].Image = processed
+	}
+}
+
+// extractionImages drops the file names of uploaded images
+func extractionImages(images []uploadedImage) []invoice.Image {
+	result := make([]invoice.Image, len(images))
+	for i, image := range images {
+		result[i] = image.Image
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/gin-gonic/gin"
+)
+
+const usageDayLayout = "2006-01-02"
+
+type UsageHandler struct {
+	usageRepo   invoice.UsageRepository
+	invoiceRepo invoice.Repository
+}
+
+func NewUsageHandler(usageRepo invoice.UsageRepository, invoiceRepo invoice.Repository) *UsageHandler {
+	return &UsageHandler{
+		usageRepo:   usageRepo,
+		invoiceRepo: invoiceRepo,
+	}
+}
+
+// Summarize aggregates token usage and cost by day, model and tenant. The
+// optional from and to query parameters are days (YYYY-MM-DD), both included,
+// and tenant_id restricts the summary to one tenant.
+func (h *UsageHandler) Summarize(c *gin.Context) {
+	var filter invoice.UsageFilter
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.ParseInLocation(usageDayLayout, fromStr, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Success: false,
+				Error:   "Invalid from date, expected YYYY-MM-DD",
+			})
+			return
+		}
+		filter.From = from
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.ParseInLocation(usageDayLayout, toStr, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Success: false,
+				Error:   "Invalid to date, expected YYYY-MM-DD",
+			})
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "The from date must not be after the to date",
+		})
+		return
+	}
+
+	filter.TenantID = c.Query("tenant_id")
+
+	summaries, err := h.usageRepo.SummarizeUsage(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to summarize usage: " + err.Error(),
+		})
+		return
+	}
+
+	data := make([]UsageSummaryData, len(summaries))
+	for i, summary := range summaries {
+		data[i] = NewUsageSummaryData(summary)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ListAttempts lists the provider calls made to extract an invoice with their
+// usage and cost
+func (h *UsageHandler) ListAttempts(c *gin.Context) {
+	id := invoice.ID(c.Param("id"))
+
+	if _, err := h.invoiceRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Invoice not found",
+		})
+		return
+	}
+
+	attempts, err := h.usageRepo.ListAttempts(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Success: false,
+			Error:   "Failed to list extraction attempts: " + err.Error(),
+		})
+		return
+	}
+
+	data := make([]ExtractionAttemptData, len(attempts))
+	for i, attempt := range attempts {
+		data[i] = NewExtractionAttemptData(attempt)
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Data:    data,
+	})
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// ImmutableCacheControl lets browsers and proxies keep a response for a year
+// without revalidating it
+const ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+// Immutable marks the responses of routes whose content never changes under
+// the same URL, such as uploaded invoice images named after their invoice
+func Immutable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", ImmutableCacheControl)
+		c.Next()
+	}
+}
@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"invoice-scan/backend/internal/domain"
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/log"
+)
+
+var (
+	_ Handler           = (*ExtractionHandler)(nil)
+	_ DeadLetterHandler = (*ExtractionHandler)(nil)
+)
+
+// ExtractionHandler runs invoice.JobTypeExtraction jobs: it reloads the
+// invoice pages from storage, extracts them and stores the result on the
+// invoice.
+// The usage and cost of every provider call is recorded as an extraction
+// attempt, whether it succeeded or not.
+type ExtractionHandler struct {
+	repo              invoice.Repository
+	txManager         domain.TransactionManager
+	storage           domainstorage.FileStorage
+	extractionService invoice.ExtractionService
+	usageRepo         invoice.UsageRepository
+	pricing           invoice.Pricing
+}
+
+func NewExtractionHandler(
+	repo invoice.Repository,
+	txManager domain.TransactionManager,
+	storage domainstorage.FileStorage,
+	extractionService invoice.ExtractionService,
+	usageRepo invoice.UsageRepository,
+	pricing invoice.Pricing,
+) *ExtractionHandler {
+	return &ExtractionHandler{
+		repo:              repo,
+		txManager:         txManager,
+		storage:           storage,
+		extractionService: extractionService,
+		usageRepo:         usageRepo,
+		pricing:           pricing,
+	}
+}
+
+func (h *ExtractionHandler) Handle(ctx context.Context, j *job.Job) error {
+	payload, err := decodePayload(j)
+	if err != nil {
+		return err
+	}
+
+	inv, err := h.getInvoice(ctx, payload.InvoiceID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkProcessing()
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to update invoice %s to processing: %w", inv.ID, err)
+	}
+
+	data, dataJSON, err := h.extract(ctx, inv, payload)
+	h.recordAttempts(ctx, j, inv, data.Usage, err)
+	if err != nil {
+		if ctx.Err() != nil {
+			// cancelled by shutdown or lease loss, the job will be picked up again
+			return ctx.Err()
+		}
+
+		retryable := invoice.IsRetryable(err)
+		if updateErr := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+			if retryable {
+				i.MarkRetryScheduled(err.Error())
+			} else {
+				i.MarkFailed(err.Error())
+			}
+			return nil
+		}); updateErr != nil {
+			return fmt.Errorf("failed to record extraction error on invoice %s: %w", inv.ID, updateErr)
+		}
+
+		if !retryable {
+			return job.Permanent(err)
+		}
+		return err
+	}
+
+	tm := h.txManager.TxBegin()
+	defer tm.RecoverTx()
+	return tm.EndTx(h.complete(tm.AssignToContext(ctx), inv, data, dataJSON))
+}
+
+// complete stores the extracted data on the invoice, keeping the data it
+// replaces as a revision, e.g. when an invoice is re-extracted. Both are
+// written in the transaction of ctx, so a retry after a failed update does
+// not store the revision twice.
+func (h *ExtractionHandler) complete(ctx context.Context, inv *invoice.Invoice, data invoice.ExtractedData, dataJSON json.RawMessage) error {
+	if revision := invoice.NewRevision(inv); revision != nil {
+		if err := h.repo.CreateRevision(ctx, revision); err != nil {
+			return fmt.Errorf("failed to store revision of invoice %s: %w", inv.ID, err)
+		}
+	}
+
+	if err := h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkCompleted(dataJSON, data.PromptVersion, data.PageCount)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to update invoice %s to completed: %w", inv.ID, err)
+	}
+	return nil
+}
+
+// HandleDeadLetter marks the invoice as failed once its job gives up retrying
+func (h *ExtractionHandler) HandleDeadLetter(ctx context.Context, j *job.Job, cause error) error {
+	payload, err := decodePayload(j)
+	if err != nil {
+		return err
+	}
+
+	inv, err := h.getInvoice(ctx, payload.InvoiceID)
+	if err != nil {
+		return err
+	}
+
+	return h.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkFailed(cause.Error())
+		return nil
+	})
+}
+
+// getInvoice returns the invoice of a job. An invoice deleted while its job
+// waited fails the job right away, retrying cannot bring it back.
+func (h *ExtractionHandler) getInvoice(ctx context.Context, id invoice.ID) (*invoice.Invoice, error) {
+	inv, err := h.repo.GetByID(ctx, id)
+	if errors.Is(err, invoice.ErrNotFound) {
+		return nil, job.Permanent(fmt.Errorf("invoice %s was deleted: %w", id, err))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice %s: %w", id, err)
+	}
+	return inv, nil
+}
+
+// recordAttempts stores the usage of an extraction. Failing to do so is logged
+// and does not fail the job, a retry would be charged again.
+func (h *ExtractionHandler) recordAttempts(ctx context.Context, j *job.Job, inv *invoice.Invoice, usage []invoice.Usage, extractErr error) {
+	attempts := invoice.NewExtractionAttempts(usage, extractErr, h.pricing)
+	for _, attempt := range attempts {
+		attempt.InvoiceID = inv.ID
+		attempt.JobID = string(j.ID)
+		attempt.TenantID = inv.TenantID
+	}
+
+	// the calls were made even when the job was cancelled meanwhile
+	if err := h.usageRepo.CreateAttempts(context.WithoutCancel(ctx), attempts); err != nil {
+		log.Warnf("failed to record extraction attempts of invoice %s: %v", inv.ID, err)
+	}
+}
+
+func decodePayload(j *job.Job) (invoice.ExtractionJobPayload, error) {
+	var payload invoice.ExtractionJobPayload
+	if err := json.Unmarshal(j.Payload, &payload); err != nil {
+		return payload, job.Permanent(fmt.Errorf("invalid extraction job payload: %w", err))
+	}
+	return payload, nil
+}
+
+// loadImages reads the pages of an invoice from storage, in page order.
+// Invoices without page records are their single image.
+func (h *ExtractionHandler) loadImages(ctx context.Context, inv *invoice.Invoice) ([]invoice.Image, error) {
+	pages, err := h.repo.ListPages(ctx, inv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+	if len(pages) == 0 {
+		pages = invoice.Pages{invoice.NewPage(inv.ID, 1, inv.ImagePath, "")}
+	}
+
+	images := make([]invoice.Image, len(pages))
+	for i, page := range pages {
+		imageBytes, err := h.storage.Get(ctx, page.ImagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load image of page %d: %w", page.Number, err)
+		}
+
+		mimeType := page.MIMEType
+		if mimeType == "" {
+			mimeType = http.DetectContentType(imageBytes)
+		}
+		images[i] = invoice.Image{Bytes: imageBytes, MIMEType: mimeType}
+	}
+	return images, nil
+}
+
+func (h *ExtractionHandler) extract(ctx context.Context, inv *invoice.Invoice, payload invoice.ExtractionJobPayload) (invoice.ExtractedData, json.RawMessage, error) {
+	images, err := h.loadImages(ctx, inv)
+	if err != nil {
+		return invoice.ExtractedData{}, nil, err
+	}
+
+	opts := payload.ExtractOptions
+	if opts.TenantID == "" {
+		opts.TenantID = inv.TenantID
+	}
+
+	data, err := h.extractionService.Extract(ctx, images, opts)
+	if err != nil {
+		// keep the usage of the failed calls
+		return data, nil, err
+	}
+
+	// usage is recorded as extraction attempts, not with the data
+	stored := data
+	stored.Usage = nil
+	dataJSON, err := json.Marshal(stored)
+	if err != nil {
+		return data, nil, invoice.NewPermanentError(fmt.Errorf("failed to marshal extracted data: %w", err))
+	}
+
+	return data, dataJSON, nil
+}
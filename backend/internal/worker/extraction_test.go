@@ -0,0 +1,325 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+)
+
+func TestExtractionHandler_Handle(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Invoice Number", Value: "INV-001"}},
+	}}
+
+	j, err := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+	if err != nil {
+		t.Fatalf("NewExtractionJob() error = %v", err)
+	}
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusCompleted {
+		t.Errorf("Expected status %v, got %v", invoice.StatusCompleted, got.Status)
+	}
+
+	var data invoice.ExtractedData
+	if err := json.Unmarshal(got.ExtractedData, &data); err != nil {
+		t.Fatalf("Failed to unmarshal extracted data: %v", err)
+	}
+	if len(data.KeyValuePairs) != 1 || data.KeyValuePairs[0].Value != "INV-001" {
+		t.Errorf("Unexpected extracted data: %+v", data)
+	}
+}
+
+func TestExtractionHandler_Handle_RetryableError(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{err: invoice.NewRetryableError(errors.New("gemini API error: 503"))}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	err := h.Handle(context.Background(), j)
+	if err == nil {
+		t.Fatal("Expected error from Handle()")
+	}
+	if job.IsPermanent(err) {
+		t.Error("Retryable extraction error should not be permanent")
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusPending {
+		t.Errorf("Expected status %v, got %v", invoice.StatusPending, got.Status)
+	}
+	if got.ExtractionAttempts != 1 {
+		t.Errorf("Expected 1 extraction attempt, got %d", got.ExtractionAttempts)
+	}
+	if got.ErrorMessage == nil || *got.ErrorMessage != "gemini API error: 503" {
+		t.Errorf("Expected error message to be recorded, got %v", got.ErrorMessage)
+	}
+}
+
+func TestExtractionHandler_Handle_PermanentError(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{err: invoice.NewPermanentError(errors.New("invalid image type"))}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); !job.IsPermanent(err) {
+		t.Fatalf("Expected permanent error, got %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusFailed {
+		t.Errorf("Expected status %v, got %v", invoice.StatusFailed, got.Status)
+	}
+}
+
+func TestExtractionHandler_HandleDeadLetter(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), &memStorage{}, &stubExtraction{}, &memUsageRepo{}, nil)
+	if err := h.HandleDeadLetter(context.Background(), j, errors.New("timeout")); err != nil {
+		t.Fatalf("HandleDeadLetter() error = %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusFailed {
+		t.Errorf("Expected status %v, got %v", invoice.StatusFailed, got.Status)
+	}
+}
+
+func TestExtractionHandler_DeletedInvoice(t *testing.T) {
+	repo := newMemInvoiceRepo()
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: "inv-1"})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), &memStorage{}, &stubExtraction{}, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); !job.IsPermanent(err) || !errors.Is(err, invoice.ErrNotFound) {
+		t.Errorf("Expected a permanent not found error from Handle(), got %v", err)
+	}
+	if err := h.HandleDeadLetter(context.Background(), j, errors.New("timeout")); !job.IsPermanent(err) || !errors.Is(err, invoice.ErrNotFound) {
+		t.Errorf("Expected a permanent not found error from HandleDeadLetter(), got %v", err)
+	}
+}
+
+func TestExtractionHandler_Handle_MissingImage(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{}}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, &stubExtraction{}, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); err == nil {
+		t.Fatal("Expected error when image is missing")
+	}
+}
+
+func TestExtractionHandler_Handle_Pages(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	_ = repo.AddPages(context.Background(), invoice.Pages{
+		invoice.NewPage(inv.ID, 2, "inv-1-2.png", "image/png"),
+		invoice.NewPage(inv.ID, 1, "inv-1.jpg", ""),
+	})
+	storage := &memStorage{files: map[string][]byte{
+		"inv-1.jpg":   []byte("\xff\xd8\xff\xe0 front"),
+		"inv-1-2.png": []byte("back"),
+	}}
+	extraction := &stubExtraction{data: invoice.ExtractedData{PageCount: 2}}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	expected := []invoice.Image{
+		{Bytes: []byte("\xff\xd8\xff\xe0 front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("back"), MIMEType: "image/png"},
+	}
+	if !reflect.DeepEqual(extraction.images, expected) {
+		t.Errorf("Expected pages %+v to be extracted in one call, got %+v", expected, extraction.images)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.PageCount != 2 {
+		t.Errorf("Expected page count 2, got %d", got.PageCount)
+	}
+}
+
+func TestExtractionHandler_Handle_Reprocess(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	inv.MarkCompleted(json.RawMessage(`{"key_value_pairs":[{"key":"Total","value":"100"}]}`), "v1", 1)
+	inv.TenantID = "acme"
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Total", Value: "200"}},
+		PromptVersion: "v2",
+	}}
+
+	opts := invoice.ExtractOptions{Model: "gemini-2.5-pro", PromptVersion: "v2", CustomFields: []string{"Mã số thuế"}}
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID, ExtractOptions: opts})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	expectedOpts := opts
+	expectedOpts.TenantID = "acme"
+	if !reflect.DeepEqual(extraction.opts, expectedOpts) {
+		t.Errorf("Expected options %+v to be passed to extraction, got %+v", expectedOpts, extraction.opts)
+	}
+
+	revisions, _ := repo.ListRevisions(context.Background(), inv.ID)
+	if len(revisions) != 1 {
+		t.Fatalf("Expected 1 revision, got %d", len(revisions))
+	}
+	if string(revisions[0].ExtractedData) != `{"key_value_pairs":[{"key":"Total","value":"100"}]}` {
+		t.Errorf("Expected previous data to be kept, got %s", revisions[0].ExtractedData)
+	}
+	if revisions[0].PromptVersion != "v1" {
+		t.Errorf("Expected previous prompt version v1, got %v", revisions[0].PromptVersion)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusCompleted {
+		t.Errorf("Expected status %v, got %v", invoice.StatusCompleted, got.Status)
+	}
+	if got.PromptVersion != "v2" {
+		t.Errorf("Expected prompt version v2, got %v", got.PromptVersion)
+	}
+}
+
+func TestExtractionHandler_Handle_RevisionRolledBack(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	inv.MarkCompleted(json.RawMessage(`{"key_value_pairs":[{"key":"Total","value":"100"}]}`), "v1", 1)
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Total", Value: "200"}},
+	}}
+	repo.updateErr = func(inv *invoice.Invoice) error {
+		if inv.Status == invoice.StatusCompleted {
+			return errors.New("connection lost")
+		}
+		return nil
+	}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, &memUsageRepo{}, nil)
+	if err := h.Handle(context.Background(), j); err == nil {
+		t.Fatal("Expected error from Handle()")
+	}
+	if revisions, _ := repo.ListRevisions(context.Background(), inv.ID); len(revisions) != 0 {
+		t.Fatalf("Expected the revision to be rolled back, got %d", len(revisions))
+	}
+
+	// the retry stores the revision once
+	repo.updateErr = nil
+	if err := h.Handle(context.Background(), j); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if revisions, _ := repo.ListRevisions(context.Background(), inv.ID); len(revisions) != 1 {
+		t.Errorf("Expected 1 revision, got %d", len(revisions))
+	}
+}
+
+func TestExtractionHandler_Handle_RecordsAttempts(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	inv.TenantID = "acme"
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Invoice Number", Value: "INV-001"}},
+		Usage: []invoice.Usage{
+			{Provider: "gemini", Model: "gemini-2.5-flash", PromptTokens: 1000, OutputTokens: 200, TotalTokens: 1200, Error: "gemini API error: 503"},
+			{Provider: "tesseract", Model: "tesseract", LatencyMs: 800},
+		},
+	}}
+	usageRepo := &memUsageRepo{}
+	pricing := invoice.Pricing{"gemini-2.5-flash": {InputPerMillion: 0.30, OutputPerMillion: 2.50}}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, usageRepo, pricing)
+	if err := h.Handle(context.Background(), j); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	attempts, _ := usageRepo.ListAttempts(context.Background(), inv.ID)
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+	if attempts[0].Succeeded || attempts[0].ErrorMessage == nil {
+		t.Errorf("Expected the gemini attempt to be failed, got %+v", attempts[0])
+	}
+	if attempts[0].CostUSD != 0.0008 {
+		t.Errorf("Expected cost 0.0008, got %v", attempts[0].CostUSD)
+	}
+	if !attempts[1].Succeeded || attempts[1].JobID != "job-1" || attempts[1].TenantID != "acme" {
+		t.Errorf("Unexpected tesseract attempt: %+v", attempts[1])
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	var data invoice.ExtractedData
+	if err := json.Unmarshal(got.ExtractedData, &data); err != nil {
+		t.Fatalf("Failed to unmarshal extracted data: %v", err)
+	}
+	if data.Usage != nil {
+		t.Errorf("Expected usage not to be stored with the data, got %+v", data.Usage)
+	}
+}
+
+func TestExtractionHandler_Handle_RecordsFailedAttempts(t *testing.T) {
+	inv := invoice.New("inv-1", "inv-1.jpg")
+	repo := newMemInvoiceRepo(inv)
+	storage := &memStorage{files: map[string][]byte{"inv-1.jpg": []byte("image")}}
+	extraction := &stubExtraction{
+		data: invoice.ExtractedData{Usage: []invoice.Usage{{Provider: "gemini", Model: "gemini-2.5-flash", TotalTokens: 5000}}},
+		err:  invoice.NewPermanentError(errors.New("failed to parse response")),
+	}
+	usageRepo := &memUsageRepo{}
+
+	j, _ := invoice.NewExtractionJob("job-1", invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	h := NewExtractionHandler(repo, newMemTxManager(repo), storage, extraction, usageRepo, nil)
+	if err := h.Handle(context.Background(), j); !job.IsPermanent(err) {
+		t.Fatalf("Expected permanent error, got %v", err)
+	}
+
+	if len(usageRepo.attempts) != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", len(usageRepo.attempts))
+	}
+	attempt := usageRepo.attempts[0]
+	if attempt.Succeeded || attempt.ErrorMessage == nil || *attempt.ErrorMessage != "failed to parse response" {
+		t.Errorf("Expected a failed attempt, got %+v", attempt)
+	}
+	if attempt.TotalTokens != 5000 {
+		t.Errorf("Expected 5000 tokens, got %d", attempt.TotalTokens)
+	}
+}
@@ -0,0 +1,428 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain"
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+)
+
+type memQueue struct {
+	mu   sync.Mutex
+	seq  int
+	jobs map[job.ID]*job.Job
+}
+
+func newMemQueue() *memQueue {
+	return &memQueue{jobs: make(map[job.ID]*job.Job)}
+}
+
+func (q *memQueue) NextID() job.ID {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	return job.ID(fmt.Sprintf("job-%d", q.seq))
+}
+
+func (q *memQueue) Enqueue(ctx context.Context, j *job.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored := *j
+	q.jobs[j.ID] = &stored
+	return nil
+}
+
+func (q *memQueue) Claim(ctx context.Context, workerID string, lease time.Duration) (*job.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	for _, j := range q.jobs {
+		if (j.Status == job.StatusQueued && !j.NextRunAt.After(now)) || j.IsLeaseExpired(now) {
+			j.MarkRunning(workerID, lease)
+			claimed := *j
+			return &claimed, nil
+		}
+	}
+	return nil, job.ErrNoJobAvailable
+}
+
+func (q *memQueue) Heartbeat(ctx context.Context, j *job.Job, lease time.Duration) error {
+	return q.update(j.ID, j.LockedBy, func(stored *job.Job) {
+		stored.ExtendLease(lease)
+	})
+}
+
+func (q *memQueue) Complete(ctx context.Context, j *job.Job) error {
+	workerID := j.LockedBy
+	j.MarkSucceeded()
+	return q.update(j.ID, workerID, func(stored *job.Job) {
+		stored.MarkSucceeded()
+	})
+}
+
+func (q *memQueue) Retry(ctx context.Context, j *job.Job, errMsg string, runAt time.Time) error {
+	workerID := j.LockedBy
+	j.MarkRetry(errMsg, runAt)
+	return q.update(j.ID, workerID, func(stored *job.Job) {
+		stored.MarkRetry(errMsg, runAt)
+	})
+}
+
+func (q *memQueue) DeadLetter(ctx context.Context, j *job.Job, errMsg string) error {
+	workerID := j.LockedBy
+	j.MarkDead(errMsg)
+	return q.update(j.ID, workerID, func(stored *job.Job) {
+		stored.MarkDead(errMsg)
+	})
+}
+
+func (q *memQueue) Abandon(ctx context.Context, j *job.Job, errMsg string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, ok := q.jobs[j.ID]
+	if !ok || stored.Status != job.StatusRunning {
+		return job.ErrLeaseLost
+	}
+	j.MarkDead(errMsg)
+	stored.MarkDead(errMsg)
+	return nil
+}
+
+func (q *memQueue) GetByID(ctx context.Context, id job.ID) (*job.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, ok := q.jobs[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	clone := *stored
+	return &clone, nil
+}
+
+func (q *memQueue) FindLatest(ctx context.Context, jobType job.Type, resourceID string) (*job.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var latest *job.Job
+	for _, stored := range q.jobs {
+		if stored.Type != jobType || stored.ResourceID != resourceID {
+			continue
+		}
+		if latest == nil || stored.CreatedAt.After(latest.CreatedAt) {
+			latest = stored
+		}
+	}
+	if latest == nil {
+		return nil, job.ErrNotFound
+	}
+	clone := *latest
+	return &clone, nil
+}
+
+func (q *memQueue) ListDeadLetters(ctx context.Context, params job.ListParams) (*job.PaginatedResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := &job.PaginatedResult{Page: params.Page, PageSize: params.PageSize}
+	for _, stored := range q.jobs {
+		if stored.Status == job.StatusDead {
+			clone := *stored
+			result.Jobs = append(result.Jobs, &clone)
+		}
+	}
+	result.Total = int64(len(result.Jobs))
+	return result, nil
+}
+
+func (q *memQueue) Requeue(ctx context.Context, id job.ID) (*job.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, ok := q.jobs[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	if stored.Status != job.StatusDead {
+		return nil, job.ErrNotDead
+	}
+	stored.Requeue()
+	clone := *stored
+	return &clone, nil
+}
+
+func (q *memQueue) update(id job.ID, workerID string, fn func(*job.Job)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stored, ok := q.jobs[id]
+	if !ok || stored.LockedBy != workerID {
+		return job.ErrLeaseLost
+	}
+	fn(stored)
+	return nil
+}
+
+func (q *memQueue) get(id job.ID) job.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.jobs[id]
+}
+
+type memInvoiceRepo struct {
+	mu        sync.Mutex
+	invoices  map[invoice.ID]*invoice.Invoice
+	pages     invoice.Pages
+	revisions invoice.Revisions
+	// updateErr, when set, fails the updates it returns an error for
+	updateErr func(inv *invoice.Invoice) error
+}
+
+func newMemInvoiceRepo(invoices ...*invoice.Invoice) *memInvoiceRepo {
+	r := &memInvoiceRepo{invoices: make(map[invoice.ID]*invoice.Invoice)}
+	for _, inv := range invoices {
+		r.invoices[inv.ID] = inv
+	}
+	return r
+}
+
+func (r *memInvoiceRepo) NextID() invoice.ID {
+	return invoice.ID(fmt.Sprintf("inv-%d", len(r.invoices)+1))
+}
+
+func (r *memInvoiceRepo) Create(ctx context.Context, inv *invoice.Invoice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invoices[inv.ID] = inv
+	return nil
+}
+
+func (r *memInvoiceRepo) GetByID(ctx context.Context, id invoice.ID) (*invoice.Invoice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inv, ok := r.invoices[id]
+	if !ok {
+		return nil, invoice.ErrNotFound
+	}
+	clone := *inv
+	return &clone, nil
+}
+
+func (r *memInvoiceRepo) GetForUpdate(ctx context.Context, id invoice.ID) (*invoice.Invoice, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *memInvoiceRepo) List(ctx context.Context, params invoice.PaginationParams) (*invoice.PaginatedResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *memInvoiceRepo) Update(ctx context.Context, inv *invoice.Invoice, updateFunc func(*invoice.Invoice) error) error {
+	if err := updateFunc(inv); err != nil {
+		return err
+	}
+	if r.updateErr != nil {
+		if err := r.updateErr(inv); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clone := *inv
+	r.invoices[inv.ID] = &clone
+	return nil
+}
+
+func (r *memInvoiceRepo) Delete(ctx context.Context, id invoice.ID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.invoices, id)
+	return nil
+}
+
+func (r *memInvoiceRepo) ListStale(ctx context.Context, updatedBefore time.Time, params invoice.PaginationParams) (invoice.Invoices, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stale invoice.Invoices
+	for _, inv := range r.invoices {
+		if (inv.Status == invoice.StatusPending || inv.Status == invoice.StatusProcessing) && inv.UpdatedAt.Before(updatedBefore) {
+			clone := *inv
+			stale = append(stale, &clone)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].UpdatedAt.Before(stale[j].UpdatedAt) })
+
+	start := (params.Page - 1) * params.PageSize
+	if start >= len(stale) {
+		return nil, nil
+	}
+	return stale[start:min(start+params.PageSize, len(stale))], nil
+}
+
+func (r *memInvoiceRepo) AddPages(ctx context.Context, pages invoice.Pages) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pages = append(r.pages, pages...)
+	return nil
+}
+
+func (r *memInvoiceRepo) ListPages(ctx context.Context, invoiceID invoice.ID) (invoice.Pages, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pages invoice.Pages
+	for _, page := range r.pages {
+		if page.InvoiceID == invoiceID {
+			pages = append(pages, page)
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Number < pages[j].Number })
+	return pages, nil
+}
+
+func (r *memInvoiceRepo) CreateRevision(ctx context.Context, rev *invoice.Revision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rev.ID = int64(len(r.revisions) + 1)
+	rev.Number = len(r.revisions) + 1
+	r.revisions = append(r.revisions, rev)
+	return nil
+}
+
+func (r *memInvoiceRepo) ListRevisions(ctx context.Context, invoiceID invoice.ID) (invoice.Revisions, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revisions invoice.Revisions
+	for _, rev := range r.revisions {
+		if rev.InvoiceID == invoiceID {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+// memTxManager rolls back the revisions of its invoice repo created since
+// TxBegin
+type memTxManager struct {
+	repo      *memInvoiceRepo
+	revisions int
+	done      bool
+}
+
+func newMemTxManager(repo *memInvoiceRepo) *memTxManager {
+	return &memTxManager{repo: repo}
+}
+
+func (tm *memTxManager) TxBegin() domain.TransactionManager {
+	tm.repo.mu.Lock()
+	defer tm.repo.mu.Unlock()
+	return &memTxManager{repo: tm.repo, revisions: len(tm.repo.revisions)}
+}
+
+func (tm *memTxManager) TxCommit() error {
+	tm.done = true
+	return nil
+}
+
+func (tm *memTxManager) TxRollback() {
+	if tm.done {
+		return
+	}
+	tm.repo.mu.Lock()
+	defer tm.repo.mu.Unlock()
+	tm.repo.revisions = tm.repo.revisions[:tm.revisions]
+	tm.done = true
+}
+
+func (tm *memTxManager) GetTx() interface{} {
+	return tm
+}
+
+func (tm *memTxManager) EndTx(err error) error {
+	if err != nil {
+		tm.TxRollback()
+		return err
+	}
+	return tm.TxCommit()
+}
+
+func (tm *memTxManager) RecoverTx() {
+	if p := recover(); p != nil {
+		tm.TxRollback()
+		panic(p)
+	}
+}
+
+func (tm *memTxManager) AssignToContext(parentCtx context.Context) context.Context {
+	return parentCtx
+}
+
+type memUsageRepo struct {
+	mu       sync.Mutex
+	attempts invoice.ExtractionAttempts
+}
+
+func (r *memUsageRepo) CreateAttempts(ctx context.Context, attempts invoice.ExtractionAttempts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, attempts...)
+	return nil
+}
+
+func (r *memUsageRepo) ListAttempts(ctx context.Context, invoiceID invoice.ID) (invoice.ExtractionAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var attempts invoice.ExtractionAttempts
+	for _, attempt := range r.attempts {
+		if attempt.InvoiceID == invoiceID {
+			attempts = append(attempts, attempt)
+		}
+	}
+	return attempts, nil
+}
+
+func (r *memUsageRepo) SummarizeUsage(ctx context.Context, filter invoice.UsageFilter) ([]invoice.UsageSummary, error) {
+	return nil, errors.New("not implemented")
+}
+
+type memStorage struct {
+	files map[string][]byte
+}
+
+func (s *memStorage) Save(ctx context.Context, filename string, data []byte, contentType string) (string, error) {
+	s.files[filename] = data
+	return filename, nil
+}
+
+func (s *memStorage) Get(ctx context.Context, path string) ([]byte, error) {
+	data, ok := s.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, path string) error {
+	delete(s.files, path)
+	return nil
+}
+
+func (s *memStorage) GetURL(path string) string {
+	return "/uploads/" + path
+}
+
+type stubExtraction struct {
+	data   invoice.ExtractedData
+	err    error
+	images []invoice.Image
+	opts   invoice.ExtractOptions
+}
+
+func (s *stubExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	s.images = images
+	s.opts = opts
+	return s.data, s.err
+}
+
+func (s *stubExtraction) Close() error {
+	return nil
+}
@@ -0,0 +1,233 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain/job"
+	"invoice-scan/backend/pkg/log"
+)
+
+// Handler processes a single claimed job. Returning an error fails the job.
+type Handler interface {
+	Handle(ctx context.Context, j *job.Job) error
+}
+
+// DeadLetterHandler is implemented by handlers that need to react when one of
+// their jobs is moved to the dead-letter queue
+type DeadLetterHandler interface {
+	HandleDeadLetter(ctx context.Context, j *job.Job, cause error) error
+}
+
+type HandlerFunc func(ctx context.Context, j *job.Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, j *job.Job) error {
+	return f(ctx, j)
+}
+
+type Config struct {
+	Concurrency   int
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	Retry         job.RetryPolicy
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Concurrency:   4,
+		PollInterval:  time.Second,
+		LeaseDuration: 30 * time.Second,
+		Retry:         job.DefaultRetryPolicy(),
+	}
+}
+
+// Pool runs Concurrency workers that claim jobs from the queue and dispatch
+// them to the handler registered for the job type
+type Pool struct {
+	queue    job.JobQueue
+	cfg      Config
+	handlers map[job.Type]Handler
+	workerID string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewPool(queue job.JobQueue, cfg Config) *Pool {
+	defaults := DefaultConfig()
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaults.Concurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaults.PollInterval
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = defaults.LeaseDuration
+	}
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry.MaxAttempts = defaults.Retry.MaxAttempts
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+
+	return &Pool{
+		queue:    queue,
+		cfg:      cfg,
+		handlers: make(map[job.Type]Handler),
+		workerID: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+func (p *Pool) Register(jobType job.Type, handler Handler) {
+	p.handlers[jobType] = handler
+}
+
+func (p *Pool) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < p.cfg.Concurrency; i++ {
+		workerID := fmt.Sprintf("%s-%d", p.workerID, i)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx, workerID)
+		}()
+	}
+
+	log.Infof("worker pool started with %d workers", p.cfg.Concurrency)
+}
+
+// Stop cancels running jobs and waits for all workers to return. Cancelled jobs
+// keep their lease and are picked up again once it expires.
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) run(ctx context.Context, workerID string) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		j, err := p.queue.Claim(ctx, workerID, p.cfg.LeaseDuration)
+		if err != nil {
+			if !errors.Is(err, job.ErrNoJobAvailable) && ctx.Err() == nil {
+				log.Errorf("worker %s: failed to claim job: %v", workerID, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.cfg.PollInterval):
+			}
+			continue
+		}
+
+		p.process(ctx, j)
+	}
+}
+
+func (p *Pool) process(ctx context.Context, j *job.Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		p.heartbeat(jobCtx, cancel, j)
+	}()
+
+	err := p.handle(jobCtx, j)
+	cancel()
+	<-heartbeatDone
+
+	if ctx.Err() != nil {
+		// shutting down, leave the lease to expire so the job is retried
+		return
+	}
+
+	// use a fresh context, the job context is already cancelled
+	finishCtx := context.Background()
+	if err != nil {
+		p.fail(finishCtx, j, err)
+		return
+	}
+
+	if err := p.queue.Complete(finishCtx, j); err != nil {
+		log.Errorf("failed to mark job %s as completed: %v", j.ID, err)
+	}
+}
+
+// fail schedules a retry with backoff, or moves the job to the dead-letter
+// queue when the error is permanent or the attempts are exhausted
+func (p *Pool) fail(ctx context.Context, j *job.Job, cause error) {
+	if !job.IsPermanent(cause) && p.cfg.Retry.ShouldRetry(j.Attempts) {
+		delay := p.cfg.Retry.Backoff(j.Attempts)
+		log.Warnf("job %s (%s) failed on attempt %d, retrying in %s: %v", j.ID, j.Type, j.Attempts, delay, cause)
+		if err := p.queue.Retry(ctx, j, cause.Error(), time.Now().Add(delay)); err != nil {
+			log.Errorf("failed to schedule retry for job %s: %v", j.ID, err)
+		}
+		return
+	}
+
+	log.Errorf("job %s (%s) failed on attempt %d, moving to dead-letter queue: %v", j.ID, j.Type, j.Attempts, cause)
+	if err := p.queue.DeadLetter(ctx, j, cause.Error()); err != nil {
+		log.Errorf("failed to dead-letter job %s: %v", j.ID, err)
+		return
+	}
+
+	if handler, ok := p.handlers[j.Type].(DeadLetterHandler); ok {
+		if err := handler.HandleDeadLetter(ctx, j, cause); err != nil {
+			log.Errorf("dead-letter handler for job %s failed: %v", j.ID, err)
+		}
+	}
+}
+
+func (p *Pool) handle(ctx context.Context, j *job.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	handler, ok := p.handlers[j.Type]
+	if !ok {
+		return job.Permanent(fmt.Errorf("no handler registered for job type %s", j.Type))
+	}
+
+	return handler.Handle(ctx, j)
+}
+
+// heartbeat extends the lease until ctx is done. If the lease is lost to
+// another worker the job context is cancelled.
+func (p *Pool) heartbeat(ctx context.Context, cancel context.CancelFunc, j *job.Job) {
+	ticker := time.NewTicker(p.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.queue.Heartbeat(ctx, j, p.cfg.LeaseDuration); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Errorf("heartbeat for job %s failed: %v", j.ID, err)
+				if errors.Is(err, job.ErrLeaseLost) {
+					cancel()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/job"
+)
+
+func waitForStatus(t *testing.T, q *memQueue, id job.ID, status job.Status) job.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if j := q.get(id); j.Status == status {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	j := q.get(id)
+	t.Fatalf("job %s has status %v, want %v", id, j.Status, status)
+	return j
+}
+
+func newTestPool(q *memQueue) *Pool {
+	return NewPool(q, Config{
+		Concurrency:   2,
+		PollInterval:  5 * time.Millisecond,
+		LeaseDuration: time.Second,
+		Retry:         job.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	})
+}
+
+type deadLetterRecorder struct {
+	HandlerFunc
+	dead chan error
+}
+
+func (r *deadLetterRecorder) HandleDeadLetter(ctx context.Context, j *job.Job, cause error) error {
+	r.dead <- cause
+	return nil
+}
+
+func TestPool_CompletesJob(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	handled := make(chan job.ID, 1)
+	pool := newTestPool(q)
+	pool.Register("test", HandlerFunc(func(ctx context.Context, j *job.Job) error {
+		handled <- j.ID
+		return nil
+	}))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	waitForStatus(t, q, j.ID, job.StatusSucceeded)
+	if got := <-handled; got != j.ID {
+		t.Errorf("Expected job %v to be handled, got %v", j.ID, got)
+	}
+}
+
+func TestPool_RetriesUntilSuccess(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	pool := newTestPool(q)
+	pool.Register("test", HandlerFunc(func(ctx context.Context, j *job.Job) error {
+		if j.Attempts < 2 {
+			return errors.New("timeout")
+		}
+		return nil
+	}))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	done := waitForStatus(t, q, j.ID, job.StatusSucceeded)
+	if done.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", done.Attempts)
+	}
+}
+
+func TestPool_DeadLettersAfterMaxAttempts(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	handler := &deadLetterRecorder{
+		HandlerFunc: func(ctx context.Context, j *job.Job) error {
+			return errors.New("boom")
+		},
+		dead: make(chan error, 1),
+	}
+
+	pool := newTestPool(q)
+	pool.Register("test", handler)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	dead := waitForStatus(t, q, j.ID, job.StatusDead)
+	if dead.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", dead.Attempts)
+	}
+	if dead.LastError == nil || *dead.LastError != "boom" {
+		t.Errorf("Expected last error boom, got %v", dead.LastError)
+	}
+
+	select {
+	case cause := <-handler.dead:
+		if cause.Error() != "boom" {
+			t.Errorf("Expected dead-letter cause boom, got %v", cause)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected dead-letter handler to be called")
+	}
+}
+
+func TestPool_DeadLettersPermanentError(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	pool := newTestPool(q)
+	pool.Register("test", HandlerFunc(func(ctx context.Context, j *job.Job) error {
+		return job.Permanent(errors.New("invalid image type"))
+	}))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	dead := waitForStatus(t, q, j.ID, job.StatusDead)
+	if dead.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", dead.Attempts)
+	}
+}
+
+func TestPool_FailsJobWithoutHandler(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "unknown", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	pool := newTestPool(q)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	waitForStatus(t, q, j.ID, job.StatusDead)
+}
+
+func TestPool_RecoversPanic(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	pool := newTestPool(q)
+	pool.Register("test", HandlerFunc(func(ctx context.Context, j *job.Job) error {
+		panic("unexpected")
+	}))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	waitForStatus(t, q, j.ID, job.StatusDead)
+}
+
+func TestPool_ReclaimsExpiredLease(t *testing.T) {
+	q := newMemQueue()
+	j := job.New(q.NextID(), "test", "", nil)
+	_ = q.Enqueue(context.Background(), j)
+
+	// simulate a worker that crashed after claiming the job
+	if _, err := q.Claim(context.Background(), "crashed-worker", time.Millisecond); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	pool := newTestPool(q)
+	pool.Register("test", HandlerFunc(func(ctx context.Context, j *job.Job) error {
+		return nil
+	}))
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	done := waitForStatus(t, q, j.ID, job.StatusSucceeded)
+	if done.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", done.Attempts)
+	}
+}
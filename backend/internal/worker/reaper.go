@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+	"invoice-scan/backend/pkg/log"
+)
+
+type ReaperConfig struct {
+	Interval time.Duration
+	// StaleAfter is how long an invoice may stay processing before its worker
+	// is considered hung
+	StaleAfter time.Duration
+	BatchSize  int
+	// MaxAttempts caps how many attempts the last extraction job of an
+	// orphaned invoice may have had before the invoice is failed instead of
+	// requeued. It is a budget per job, like the retry policy of the pool.
+	MaxAttempts int
+}
+
+func DefaultReaperConfig() ReaperConfig {
+	return ReaperConfig{
+		Interval:    time.Minute,
+		StaleAfter:  15 * time.Minute,
+		BatchSize:   100,
+		MaxAttempts: job.DefaultRetryPolicy().MaxAttempts,
+	}
+}
+
+// Reaper recovers invoices stuck in pending or processing: invoices without a
+// live extraction job are requeued or failed, and invoices whose worker hangs
+// past StaleAfter are failed so they stop spinning in the list page.
+type Reaper struct {
+	repo  invoice.Repository
+	queue job.JobQueue
+	cfg   ReaperConfig
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewReaper(repo invoice.Repository, queue job.JobQueue, cfg ReaperConfig) *Reaper {
+	defaults := DefaultReaperConfig()
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.StaleAfter <= 0 {
+		cfg.StaleAfter = defaults.StaleAfter
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaults.BatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaults.MaxAttempts
+	}
+
+	return &Reaper{
+		repo:  repo,
+		queue: queue,
+		cfg:   cfg,
+	}
+}
+
+// Start sweeps invoices older than StaleAfter every Interval until Stop
+func (r *Reaper) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.Sweep(ctx, time.Now().Add(-r.cfg.StaleAfter)); err != nil && ctx.Err() == nil {
+					log.Errorf("reaper: sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *Reaper) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// Sweep inspects every pending or processing invoice last updated before
+// updatedBefore and returns how many were requeued or failed. On startup it is
+// called with the current time to recover everything a crash left behind.
+func (r *Reaper) Sweep(ctx context.Context, updatedBefore time.Time) (int, error) {
+	params := invoice.PaginationParams{Page: 1, PageSize: r.cfg.BatchSize}
+	recovered := 0
+
+	for {
+		invoices, err := r.repo.ListStale(ctx, updatedBefore, params)
+		if err != nil {
+			return recovered, fmt.Errorf("failed to list stale invoices: %w", err)
+		}
+
+		skipped := 0
+		for _, inv := range invoices {
+			ok, err := r.recover(ctx, inv)
+			if err != nil {
+				if ctx.Err() != nil {
+					return recovered, ctx.Err()
+				}
+				log.Errorf("reaper: failed to recover invoice %s: %v", inv.ID, err)
+				ok = false
+			}
+			if ok {
+				recovered++
+			} else {
+				skipped++
+			}
+		}
+
+		if len(invoices) < params.PageSize {
+			break
+		}
+		// recovered invoices drop out of the result, only skip past the rest
+		if skipped == params.PageSize {
+			params.Page++
+		}
+	}
+
+	if recovered > 0 {
+		log.Infof("reaper: recovered %d stuck invoices", recovered)
+	}
+	return recovered, nil
+}
+
+// recover reports whether inv was requeued or failed. Invoices whose job is
+// queued, or running with a lease that will expire on its own, are left alone.
+func (r *Reaper) recover(ctx context.Context, inv *invoice.Invoice) (bool, error) {
+	latest, err := r.queue.FindLatest(ctx, invoice.JobTypeExtraction, inv.ID.String())
+	if err != nil && !errors.Is(err, job.ErrNotFound) {
+		return false, fmt.Errorf("failed to find extraction job: %w", err)
+	}
+
+	switch {
+	case latest == nil:
+		return true, r.requeue(ctx, inv, invoice.ExtractionJobPayload{InvoiceID: inv.ID})
+
+	case latest.Status == job.StatusSucceeded:
+		if latest.Attempts >= r.cfg.MaxAttempts {
+			return true, r.fail(ctx, inv, fmt.Sprintf("extraction abandoned after %d attempts of job %s without the invoice being updated", latest.Attempts, latest.ID))
+		}
+		// keep the model and prompt overrides of the last request
+		payload, err := decodePayload(latest)
+		if err != nil {
+			payload = invoice.ExtractionJobPayload{InvoiceID: inv.ID}
+		}
+		return true, r.requeue(ctx, inv, payload)
+
+	case latest.Status == job.StatusDead:
+		reason := "extraction job was dead-lettered"
+		if latest.LastError != nil {
+			reason = *latest.LastError
+		}
+		return true, r.fail(ctx, inv, reason)
+
+	case latest.Status == job.StatusRunning && !latest.IsLeaseExpired(time.Now()) &&
+		inv.Status == invoice.StatusProcessing && time.Since(inv.UpdatedAt) > r.cfg.StaleAfter:
+		reason := fmt.Sprintf("extraction timed out: worker %s did not finish within %s", latest.LockedBy, r.cfg.StaleAfter)
+		if err := r.queue.Abandon(ctx, latest, reason); err != nil {
+			if errors.Is(err, job.ErrLeaseLost) {
+				// the job finished in the meantime
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to abandon job %s: %w", latest.ID, err)
+		}
+		return true, r.fail(ctx, inv, reason)
+	}
+
+	return false, nil
+}
+
+// requeue queues a fresh extraction job for an invoice that has none. The job
+// is enqueued first: if updating the invoice fails the job still processes it.
+func (r *Reaper) requeue(ctx context.Context, inv *invoice.Invoice, payload invoice.ExtractionJobPayload) error {
+	extractionJob, err := invoice.NewExtractionJob(r.queue.NextID(), payload)
+	if err != nil {
+		return err
+	}
+	if err := r.queue.Enqueue(ctx, extractionJob); err != nil {
+		return fmt.Errorf("failed to enqueue extraction job: %w", err)
+	}
+
+	log.Warnf("reaper: requeued orphaned invoice %s as job %s", inv.ID, extractionJob.ID)
+	return r.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkPending()
+		return nil
+	})
+}
+
+func (r *Reaper) fail(ctx context.Context, inv *invoice.Invoice, reason string) error {
+	log.Warnf("reaper: marking invoice %s as failed: %s", inv.ID, reason)
+	return r.repo.Update(ctx, inv, func(i *invoice.Invoice) error {
+		i.MarkFailed(reason)
+		return nil
+	})
+}
@@ -0,0 +1,218 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/internal/domain/job"
+)
+
+func newStaleInvoice(id invoice.ID, status invoice.Status, age time.Duration) *invoice.Invoice {
+	inv := invoice.New(id, id.String()+".jpg")
+	inv.Status = status
+	inv.UpdatedAt = time.Now().Add(-age)
+	return inv
+}
+
+func enqueueExtraction(t *testing.T, q *memQueue, invoiceID invoice.ID) *job.Job {
+	t.Helper()
+	j, err := invoice.NewExtractionJob(q.NextID(), invoice.ExtractionJobPayload{InvoiceID: invoiceID})
+	if err != nil {
+		t.Fatalf("NewExtractionJob() error = %v", err)
+	}
+	_ = q.Enqueue(context.Background(), j)
+	return j
+}
+
+func newTestReaper(repo *memInvoiceRepo, q *memQueue) *Reaper {
+	return NewReaper(repo, q, ReaperConfig{StaleAfter: time.Minute, BatchSize: 2, MaxAttempts: 3})
+}
+
+func TestReaper_RequeuesOrphanedInvoice(t *testing.T) {
+	inv := newStaleInvoice("inv-1", invoice.StatusProcessing, time.Hour)
+	repo := newMemInvoiceRepo(inv)
+	q := newMemQueue()
+
+	recovered, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("Expected 1 recovered invoice, got %d", recovered)
+	}
+
+	j, err := q.FindLatest(context.Background(), invoice.JobTypeExtraction, inv.ID.String())
+	if err != nil {
+		t.Fatalf("Expected an extraction job to be queued, got %v", err)
+	}
+	if j.Status != job.StatusQueued {
+		t.Errorf("Expected job status %v, got %v", job.StatusQueued, j.Status)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusPending {
+		t.Errorf("Expected status %v, got %v", invoice.StatusPending, got.Status)
+	}
+}
+
+// finishJob runs an extraction job of invoiceID to success after attempts
+// tries without the invoice being updated
+func finishJob(t *testing.T, q *memQueue, invoiceID invoice.ID, attempts int) {
+	t.Helper()
+	j, err := invoice.NewExtractionJob(q.NextID(), invoice.ExtractionJobPayload{InvoiceID: invoiceID})
+	if err != nil {
+		t.Fatalf("NewExtractionJob() error = %v", err)
+	}
+	j.Attempts = attempts - 1
+	_ = q.Enqueue(context.Background(), j)
+	claimed, err := q.Claim(context.Background(), "worker-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if err := q.Complete(context.Background(), claimed); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+}
+
+func TestReaper_FailsOrphanWithExhaustedAttempts(t *testing.T) {
+	inv := newStaleInvoice("inv-1", invoice.StatusProcessing, time.Hour)
+	repo := newMemInvoiceRepo(inv)
+	q := newMemQueue()
+	finishJob(t, q, inv.ID, 3)
+
+	if _, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now()); err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusFailed {
+		t.Errorf("Expected status %v, got %v", invoice.StatusFailed, got.Status)
+	}
+	if len(q.jobs) != 1 {
+		t.Errorf("Expected no job to be queued, got %d jobs", len(q.jobs))
+	}
+}
+
+func TestReaper_RequeuesOrphanWithManyLifetimeAttempts(t *testing.T) {
+	// reprocessed and requeued many times, but its last job ran once
+	inv := newStaleInvoice("inv-1", invoice.StatusProcessing, time.Hour)
+	inv.ExtractionAttempts = 10
+	repo := newMemInvoiceRepo(inv)
+	q := newMemQueue()
+	finishJob(t, q, inv.ID, 1)
+
+	recovered, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("Expected 1 recovered invoice, got %d", recovered)
+	}
+
+	if len(q.jobs) != 2 {
+		t.Errorf("Expected a new extraction job to be queued, got %d jobs", len(q.jobs))
+	}
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusPending {
+		t.Errorf("Expected status %v, got %v", invoice.StatusPending, got.Status)
+	}
+}
+
+func TestReaper_FailsInvoiceOfDeadJob(t *testing.T) {
+	inv := newStaleInvoice("inv-1", invoice.StatusPending, time.Hour)
+	repo := newMemInvoiceRepo(inv)
+	q := newMemQueue()
+	j := enqueueExtraction(t, q, inv.ID)
+	claimed, _ := q.Claim(context.Background(), "worker-1", time.Minute)
+	_ = q.DeadLetter(context.Background(), claimed, "quota exhausted")
+
+	if _, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now()); err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusFailed {
+		t.Errorf("Expected status %v, got %v", invoice.StatusFailed, got.Status)
+	}
+	if got.ErrorMessage == nil || *got.ErrorMessage != "quota exhausted" {
+		t.Errorf("Expected error message of job %s, got %v", j.ID, got.ErrorMessage)
+	}
+}
+
+func TestReaper_LeavesLiveJobsAlone(t *testing.T) {
+	queued := newStaleInvoice("inv-1", invoice.StatusPending, time.Hour)
+	running := newStaleInvoice("inv-2", invoice.StatusProcessing, time.Second)
+	repo := newMemInvoiceRepo(queued, running)
+	q := newMemQueue()
+	enqueueExtraction(t, q, running.ID)
+	if _, err := q.Claim(context.Background(), "worker-1", time.Minute); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	queuedJob := enqueueExtraction(t, q, queued.ID)
+
+	recovered, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if recovered != 0 {
+		t.Errorf("Expected no recovered invoices, got %d", recovered)
+	}
+	if got := q.get(queuedJob.ID); got.Status != job.StatusQueued {
+		t.Errorf("Expected queued job to be untouched, got %v", got.Status)
+	}
+	if got, _ := repo.GetByID(context.Background(), running.ID); got.Status != invoice.StatusProcessing {
+		t.Errorf("Expected status %v, got %v", invoice.StatusProcessing, got.Status)
+	}
+}
+
+func TestReaper_FailsHungWorker(t *testing.T) {
+	inv := newStaleInvoice("inv-1", invoice.StatusProcessing, time.Hour)
+	repo := newMemInvoiceRepo(inv)
+	q := newMemQueue()
+	j := enqueueExtraction(t, q, inv.ID)
+	// the worker keeps heartbeating but never finishes
+	if _, err := q.Claim(context.Background(), "worker-1", time.Hour); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+
+	if _, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+
+	if got := q.get(j.ID); got.Status != job.StatusDead {
+		t.Errorf("Expected job status %v, got %v", job.StatusDead, got.Status)
+	}
+
+	got, _ := repo.GetByID(context.Background(), inv.ID)
+	if got.Status != invoice.StatusFailed {
+		t.Errorf("Expected status %v, got %v", invoice.StatusFailed, got.Status)
+	}
+	if got.ErrorMessage == nil || !strings.Contains(*got.ErrorMessage, "timed out") {
+		t.Errorf("Expected timeout error message, got %v", got.ErrorMessage)
+	}
+}
+
+func TestReaper_SweepsAllPages(t *testing.T) {
+	repo := newMemInvoiceRepo(
+		newStaleInvoice("inv-1", invoice.StatusPending, 5*time.Hour),
+		newStaleInvoice("inv-2", invoice.StatusPending, 4*time.Hour),
+		newStaleInvoice("inv-3", invoice.StatusPending, 3*time.Hour),
+		newStaleInvoice("inv-4", invoice.StatusPending, 2*time.Hour),
+		newStaleInvoice("inv-5", invoice.StatusPending, time.Hour),
+	)
+	q := newMemQueue()
+	// the two oldest invoices are waiting in the queue and fill the first page
+	enqueueExtraction(t, q, "inv-1")
+	enqueueExtraction(t, q, "inv-2")
+
+	recovered, err := newTestReaper(repo, q).Sweep(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if recovered != 3 {
+		t.Errorf("Expected 3 recovered invoices, got %d", recovered)
+	}
+}
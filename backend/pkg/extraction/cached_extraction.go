@@ -0,0 +1,115 @@
+package extraction
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/pkg/log"
+)
+
+// CacheConfig configures CachedExtraction
+type CacheConfig struct {
+	// Provider and Model identify the wrapped service in cache keys; Model
+	// is the configured default, overridden by ExtractOptions.Model
+	Provider string
+	Model    string
+	// Prompts resolves the default prompt version, nil uses the built-in prompts
+	Prompts *PromptStore
+	// TTL is how long results stay cached, 0 keeps them until invalidated
+	TTL time.Duration
+}
+
+// CachedExtraction serves repeated extractions of the same image from a
+// cache. Results are keyed by the image SHA-256, provider, model, prompt
+// version and the other prompt inputs, and the returned data tells whether it
+// was a cache hit. Cache errors are logged and fall through to the service.
+type CachedExtraction struct {
+	service  invoice.ExtractionService
+	cache    invoice.ResultCache
+	provider string
+	model    string
+	prompts  *PromptStore
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+func NewCachedExtraction(service invoice.ExtractionService, cache invoice.ResultCache, cfg CacheConfig) *CachedExtraction {
+	return &CachedExtraction{
+		service:  service,
+		cache:    cache,
+		provider: cfg.Provider,
+		model:    cfg.Model,
+		prompts:  promptStoreOrDefault(cfg.Prompts),
+		ttl:      cfg.TTL,
+		now:      time.Now,
+	}
+}
+
+func (s *CachedExtraction) Close() error {
+	return s.service.Close()
+}
+
+func (s *CachedExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	key := s.cacheKey(images, opts)
+
+	if !opts.SkipCache {
+		entry, err := s.cache.Get(ctx, key)
+		if err != nil {
+			log.Warnf("failed to read extraction cache: %v", err)
+		}
+		if entry != nil {
+			data := entry.Data
+			cachedAt := entry.CreatedAt
+			data.Cache = &invoice.CacheInfo{Hit: true, ImageHash: key.ImageHash, CachedAt: &cachedAt}
+			return data, nil
+		}
+	}
+
+	data, err := s.service.Extract(ctx, images, opts)
+	if err != nil {
+		return data, err
+	}
+
+	now := s.now()
+	entry := &invoice.CacheEntry{Key: key, Data: data, CreatedAt: now}
+	entry.Data.Cache = nil
+	entry.Data.Usage = nil
+	if s.ttl > 0 {
+		entry.ExpiresAt = now.Add(s.ttl)
+	}
+	if err := s.cache.Set(ctx, entry); err != nil {
+		log.Warnf("failed to write extraction cache: %v", err)
+	}
+
+	data.Cache = &invoice.CacheInfo{Hit: false, ImageHash: key.ImageHash}
+	return data, nil
+}
+
+func (s *CachedExtraction) cacheKey(images []invoice.Image, opts invoice.ExtractOptions) invoice.CacheKey {
+	model := s.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	promptVersion := opts.PromptVersion
+	if promptVersion == "" {
+		promptVersion = s.prompts.DefaultVersion()
+	}
+
+	variant := strings.Join([]string{
+		opts.TenantID,
+		opts.Language,
+		opts.Currency,
+		strings.Join(opts.CustomFields, "\x1f"),
+	}, "\x1e")
+
+	return invoice.CacheKey{
+		ImageHash:     imagesHash(images),
+		Provider:      s.provider,
+		Model:         model,
+		PromptVersion: promptVersion,
+		Variant:       variant,
+	}
+}
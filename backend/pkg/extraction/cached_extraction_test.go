@@ -0,0 +1,133 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache is a ResultCache without eviction
+type mapCache struct {
+	entries map[string]*invoice.CacheEntry
+	getErr  error
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{entries: make(map[string]*invoice.CacheEntry)}
+}
+
+func (c *mapCache) Get(ctx context.Context, key invoice.CacheKey) (*invoice.CacheEntry, error) {
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
+	return c.entries[key.String()], nil
+}
+
+func (c *mapCache) Set(ctx context.Context, entry *invoice.CacheEntry) error {
+	c.entries[entry.Key.String()] = entry
+	return nil
+}
+
+func (c *mapCache) InvalidateImage(ctx context.Context, imageHash string) (int, error) {
+	removed := 0
+	for k, e := range c.entries {
+		if e.Key.ImageHash == imageHash {
+			delete(c.entries, k)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func TestCachedExtraction_Extract(t *testing.T) {
+	inner := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "0001234"}},
+	}}
+	cache := newMapCache()
+	s := NewCachedExtraction(inner, cache, CacheConfig{Provider: ProviderGemini, Model: "gemini-2.5-flash", TTL: time.Hour})
+
+	image := []byte("image")
+	first, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, first.Cache)
+	assert.False(t, first.Cache.Hit)
+	assert.Equal(t, imageHash(image), first.Cache.ImageHash)
+
+	require.Len(t, cache.entries, 1)
+	for _, entry := range cache.entries {
+		assert.Nil(t, entry.Data.Cache, "cache info is not stored")
+		assert.Equal(t, "v1", entry.Key.PromptVersion)
+		assert.Equal(t, time.Hour, entry.ExpiresAt.Sub(entry.CreatedAt))
+	}
+
+	second, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, inner.calls)
+	require.NotNil(t, second.Cache)
+	assert.True(t, second.Cache.Hit)
+	assert.NotNil(t, second.Cache.CachedAt)
+	assert.Equal(t, "0001234", second.KeyValuePairs[0].Value)
+}
+
+func TestCachedExtraction_Extract_KeyedByInputs(t *testing.T) {
+	inner := &stubService{data: invoice.ExtractedData{}}
+	s := NewCachedExtraction(inner, newMapCache(), CacheConfig{Provider: ProviderGemini})
+
+	image := []byte("image")
+	for _, opts := range []invoice.ExtractOptions{
+		{},
+		{Model: "gemini-2.5-pro"},
+		{PromptVersion: "v2"},
+		{TenantID: "acme"},
+		{CustomFields: []string{"Mã số thuế"}},
+	} {
+		_, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), opts)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 5, inner.calls, "each distinct input misses the cache")
+
+	_, err := s.Extract(context.Background(), oneImage([]byte("other image"), "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, inner.calls)
+}
+
+func TestCachedExtraction_Extract_SkipCache(t *testing.T) {
+	inner := &stubService{data: invoice.ExtractedData{Summary: []invoice.KeyValuePair{{Key: "Total", Value: "1"}}}}
+	s := NewCachedExtraction(inner, newMapCache(), CacheConfig{Provider: ProviderGemini})
+
+	image := []byte("image")
+	_, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	inner.data.Summary[0].Value = "2"
+	data, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{SkipCache: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+	assert.False(t, data.Cache.Hit)
+
+	data, err = s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.True(t, data.Cache.Hit)
+	assert.Equal(t, "2", data.Summary[0].Value, "the fresh result replaces the cached one")
+}
+
+func TestCachedExtraction_Extract_Errors(t *testing.T) {
+	inner := &stubService{err: invoice.NewRetryableError(errors.New("gemini API error: 503"))}
+	cache := newMapCache()
+	s := NewCachedExtraction(inner, cache, CacheConfig{Provider: ProviderGemini})
+
+	_, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	assert.Error(t, err)
+	assert.Empty(t, cache.entries, "failures are not cached")
+
+	inner.err = nil
+	cache.getErr = errors.New("connection refused")
+	_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	assert.NoError(t, err, "a broken cache falls through to the service")
+}
@@ -0,0 +1,310 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/pkg/log"
+)
+
+const (
+	// ModeFallback tries providers in order until one succeeds
+	ModeFallback = "fallback"
+	// ModeEnsemble runs every provider and merges their results
+	ModeEnsemble = "ensemble"
+)
+
+func init() {
+	Register(ProviderComposite, func(cfg ProviderConfig) (invoice.ExtractionService, error) {
+		return NewCompositeExtractionFromConfig(cfg)
+	})
+}
+
+// NamedService is an extraction service together with the provider name it is
+// reported under
+type NamedService struct {
+	Name    string
+	Service invoice.ExtractionService
+}
+
+// CompositeExtraction combines several providers. In fallback mode the next
+// provider is tried when one fails or times out; in ensemble mode all of them
+// run and their key/value pairs are merged. Every pair records the provider it
+// came from.
+type CompositeExtraction struct {
+	mode     string
+	services []NamedService
+}
+
+func NewCompositeExtraction(mode string, services []NamedService) (*CompositeExtraction, error) {
+	if mode == "" {
+		mode = ModeFallback
+	}
+	if mode != ModeFallback && mode != ModeEnsemble {
+		return nil, fmt.Errorf("invalid composite mode %q", mode)
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("composite extraction needs at least one provider")
+	}
+
+	return &CompositeExtraction{
+		mode:     mode,
+		services: services,
+	}, nil
+}
+
+// NewCompositeExtractionFromConfig creates every provider listed in
+// cfg.Providers through the registry
+func NewCompositeExtractionFromConfig(cfg ProviderConfig) (*CompositeExtraction, error) {
+	services := make([]NamedService, 0, len(cfg.Providers))
+	closeAll := func() {
+		for _, s := range services {
+			_ = s.Service.Close()
+		}
+	}
+
+	for _, child := range cfg.Providers {
+		if child.Name == ProviderComposite {
+			closeAll()
+			return nil, fmt.Errorf("composite providers cannot be nested")
+		}
+
+		if child.Prompts == nil {
+			child.Prompts = cfg.Prompts
+		}
+
+		service, err := New(child.Name, child)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to create provider %s: %w", child.Name, err)
+		}
+		services = append(services, NamedService{Name: child.Name, Service: service})
+	}
+
+	composite, err := NewCompositeExtraction(cfg.Mode, services)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	return composite, nil
+}
+
+// readsPDF: each provider combined handles PDF documents on its own
+func (s *CompositeExtraction) readsPDF() bool {
+	return true
+}
+
+func (s *CompositeExtraction) Close() error {
+	var errs []error
+	for _, service := range s.services {
+		if err := service.Service.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", service.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *CompositeExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	if s.mode == ModeEnsemble {
+		return s.extractEnsemble(ctx, images, opts)
+	}
+	return s.extractFallback(ctx, images, opts)
+}
+
+func (s *CompositeExtraction) extractFallback(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	var (
+		usage []invoice.Usage
+		errs  []error
+	)
+	for _, service := range s.services {
+		data, err := service.Service.Extract(ctx, images, opts)
+		usage = append(usage, failedUsage(data.Usage, err)...)
+		if err == nil {
+			data = tagProvider(data, service.Name)
+			data.Usage = usage
+			return data, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", service.Name, err))
+		if ctx.Err() != nil {
+			break
+		}
+		log.Warnf("extraction provider %s failed, trying the next one: %v", service.Name, err)
+	}
+
+	return invoice.ExtractedData{Usage: usage}, joinProviderErrors(errs)
+}
+
+func (s *CompositeExtraction) extractEnsemble(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	type result struct {
+		data invoice.ExtractedData
+		err  error
+	}
+
+	results := make([]result, len(s.services))
+	var wg sync.WaitGroup
+	for i, service := range s.services {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := service.Service.Extract(ctx, images, opts)
+			results[i] = result{data: tagProvider(data, service.Name), err: err}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		succeeded []invoice.ExtractedData
+		usage     []invoice.Usage
+		errs      []error
+	)
+	for i, r := range results {
+		usage = append(usage, failedUsage(r.data.Usage, r.err)...)
+		if r.err != nil {
+			log.Warnf("extraction provider %s failed in ensemble: %v", s.services[i].Name, r.err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.services[i].Name, r.err))
+			continue
+		}
+		succeeded = append(succeeded, r.data)
+	}
+
+	if len(succeeded) == 0 {
+		return invoice.ExtractedData{Usage: usage}, joinProviderErrors(errs)
+	}
+
+	merged := mergeExtractedData(succeeded)
+	merged.Usage = usage
+	return merged, nil
+}
+
+// failedUsage marks the calls of a provider that failed with err, so they are
+// accounted as failed even when another provider succeeds
+func failedUsage(usage []invoice.Usage, err error) []invoice.Usage {
+	if err == nil {
+		return usage
+	}
+
+	failed := make([]invoice.Usage, len(usage))
+	for i, u := range usage {
+		if u.Error == "" {
+			u.Error = err.Error()
+		}
+		failed[i] = u
+	}
+	return failed
+}
+
+// joinProviderErrors combines the errors of every provider. The result is
+// retryable if any provider may succeed on retry.
+func joinProviderErrors(errs []error) error {
+	err := fmt.Errorf("all extraction providers failed: %w", errors.Join(errs...))
+	for _, e := range errs {
+		if invoice.IsRetryable(e) {
+			return invoice.NewRetryableError(err)
+		}
+	}
+	return invoice.NewPermanentError(err)
+}
+
+func tagProvider(data invoice.ExtractedData, provider string) invoice.ExtractedData {
+	data.Provider = provider
+	for i := range data.KeyValuePairs {
+		data.KeyValuePairs[i].Provider = provider
+	}
+	for i := range data.Summary {
+		data.Summary[i].Provider = provider
+	}
+	return data
+}
+
+// mergeExtractedData merges results in provider order. Pairs are matched by
+// key and the value with the higher confidence wins; differing values are kept
+// as disagreements. The table and overall confidence come from the result with
+// the highest overall confidence.
+func mergeExtractedData(results []invoice.ExtractedData) invoice.ExtractedData {
+	best := results[0]
+	for _, r := range results[1:] {
+		if confidenceOf(r.Confidence) > confidenceOf(best.Confidence) {
+			best = r
+		}
+	}
+
+	keyValuePairs := make([][]invoice.KeyValuePair, len(results))
+	summaries := make([][]invoice.KeyValuePair, len(results))
+	for i, r := range results {
+		keyValuePairs[i] = r.KeyValuePairs
+		summaries[i] = r.Summary
+	}
+
+	return invoice.ExtractedData{
+		KeyValuePairs: mergeKeyValuePairs(keyValuePairs),
+		Table:         best.Table,
+		Summary:       mergeKeyValuePairs(summaries),
+		Confidence:    best.Confidence,
+		Provider:      best.Provider,
+		PromptVersion: best.PromptVersion,
+	}
+}
+
+func mergeKeyValuePairs(sets [][]invoice.KeyValuePair) []invoice.KeyValuePair {
+	var (
+		keys   []string
+		groups = make(map[string][]invoice.KeyValuePair)
+	)
+	for _, pairs := range sets {
+		for _, pair := range pairs {
+			key := normalizeText(pair.Key)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], pair)
+		}
+	}
+
+	merged := make([]invoice.KeyValuePair, 0, len(keys))
+	for _, key := range keys {
+		merged = append(merged, mergePairs(groups[key]))
+	}
+	return merged
+}
+
+// mergePairs picks the pair with the highest confidence, the earlier provider
+// winning ties, and lists the differing values of the others as disagreements
+func mergePairs(pairs []invoice.KeyValuePair) invoice.KeyValuePair {
+	winner := pairs[0]
+	for _, pair := range pairs[1:] {
+		if confidenceOf(pair.Confidence) > confidenceOf(winner.Confidence) {
+			winner = pair
+		}
+	}
+
+	winner.Disagreements = nil
+	for _, pair := range pairs {
+		if normalizeText(pair.Value) == normalizeText(winner.Value) {
+			continue
+		}
+		winner.Disagreements = append(winner.Disagreements, invoice.Candidate{
+			Value:      pair.Value,
+			Confidence: pair.Confidence,
+			Provider:   pair.Provider,
+		})
+	}
+	return winner
+}
+
+// normalizeText makes keys and values comparable across providers by ignoring
+// case and whitespace
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+func confidenceOf(c *float64) float64 {
+	if c == nil {
+		return 0
+	}
+	return *c
+}
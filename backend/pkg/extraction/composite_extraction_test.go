@@ -0,0 +1,246 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubService struct {
+	data   invoice.ExtractedData
+	err    error
+	calls  int
+	closed bool
+}
+
+func (s *stubService) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	s.calls++
+	return s.data, s.err
+}
+
+func (s *stubService) Close() error {
+	s.closed = true
+	return nil
+}
+
+func conf(v float64) *float64 {
+	return &v
+}
+
+func oneImage(imageBytes []byte, mimeType string) []invoice.Image {
+	return []invoice.Image{{Bytes: imageBytes, MIMEType: mimeType}}
+}
+
+func TestCompositeExtraction_Fallback(t *testing.T) {
+	primary := &stubService{err: invoice.NewRetryableError(errors.New("gemini API error: 503"))}
+	secondary := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "0001234"}},
+		Summary:       []invoice.KeyValuePair{{Key: "Tổng cộng", Value: "65.000"}},
+	}}
+
+	s, err := NewCompositeExtraction(ModeFallback, []NamedService{
+		{Name: "gemini", Service: primary},
+		{Name: "tesseract", Service: secondary},
+	})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, "tesseract", data.Provider)
+	assert.Equal(t, "tesseract", data.KeyValuePairs[0].Provider)
+	assert.Equal(t, "tesseract", data.Summary[0].Provider)
+}
+
+func TestCompositeExtraction_Fallback_StopsAtFirstSuccess(t *testing.T) {
+	primary := &stubService{data: invoice.ExtractedData{KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "1"}}}}
+	secondary := &stubService{}
+
+	s, err := NewCompositeExtraction(ModeFallback, []NamedService{
+		{Name: "gemini", Service: primary},
+		{Name: "tesseract", Service: secondary},
+	})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "gemini", data.Provider)
+	assert.Equal(t, 0, secondary.calls)
+}
+
+func TestCompositeExtraction_AllFail(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		retryable bool
+	}{
+		{
+			name:      "one retryable",
+			errs:      []error{invoice.NewRetryableError(errors.New("timeout")), invoice.NewPermanentError(errors.New("no text"))},
+			retryable: true,
+		},
+		{
+			name:      "all permanent",
+			errs:      []error{invoice.NewPermanentError(errors.New("bad request")), invoice.NewPermanentError(errors.New("no text"))},
+			retryable: false,
+		},
+	}
+
+	for _, mode := range []string{ModeFallback, ModeEnsemble} {
+		for _, test := range tests {
+			t.Run(mode+" "+test.name, func(t *testing.T) {
+				s, err := NewCompositeExtraction(mode, []NamedService{
+					{Name: "gemini", Service: &stubService{err: test.errs[0]}},
+					{Name: "tesseract", Service: &stubService{err: test.errs[1]}},
+				})
+				require.NoError(t, err)
+
+				_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+				require.Error(t, err)
+				assert.ErrorContains(t, err, "gemini")
+				assert.ErrorContains(t, err, "tesseract")
+				assert.Equal(t, test.retryable, invoice.IsRetryable(err))
+			})
+		}
+	}
+}
+
+func TestCompositeExtraction_Ensemble(t *testing.T) {
+	gemini := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{
+			{Key: "Số hóa đơn", Value: "0001234", Confidence: conf(0.95)},
+			{Key: "Ngày", Value: "15/03/2024", Confidence: conf(0.7)},
+			{Key: "Người bán", Value: "Công ty ABC", Confidence: conf(0.9)},
+		},
+		Table:      invoice.TableData{Headers: []string{"Tên hàng"}, Rows: [][]string{{"Cà phê"}}},
+		Summary:    []invoice.KeyValuePair{{Key: "Tổng cộng", Value: "65.000", Confidence: conf(0.9)}},
+		Confidence: conf(0.9),
+	}}
+	tesseract := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{
+			{Key: "số  hóa đơn", Value: "0001284", Confidence: conf(0.6)},
+			{Key: "Ngày", Value: "16/03/2024", Confidence: conf(0.8)},
+			{Key: "Người bán", Value: "công ty  ABC", Confidence: conf(0.5)},
+			{Key: "Mã số thuế", Value: "0101234567", Confidence: conf(0.85)},
+		},
+		Table:      invoice.TableData{Headers: []string{"Ten hang"}, Rows: [][]string{{"Ca phe"}}},
+		Summary:    []invoice.KeyValuePair{{Key: "Tổng cộng", Value: "65.000", Confidence: conf(0.8)}},
+		Confidence: conf(0.7),
+	}}
+
+	s, err := NewCompositeExtraction(ModeEnsemble, []NamedService{
+		{Name: "gemini", Service: gemini},
+		{Name: "tesseract", Service: tesseract},
+	})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, data.KeyValuePairs, 4)
+
+	invoiceNumber := data.KeyValuePairs[0]
+	assert.Equal(t, "0001234", invoiceNumber.Value)
+	assert.Equal(t, "gemini", invoiceNumber.Provider)
+	require.Len(t, invoiceNumber.Disagreements, 1)
+	assert.Equal(t, invoice.Candidate{Value: "0001284", Confidence: conf(0.6), Provider: "tesseract"}, invoiceNumber.Disagreements[0])
+
+	date := data.KeyValuePairs[1]
+	assert.Equal(t, "16/03/2024", date.Value)
+	assert.Equal(t, "tesseract", date.Provider)
+	require.Len(t, date.Disagreements, 1)
+	assert.Equal(t, "gemini", date.Disagreements[0].Provider)
+
+	seller := data.KeyValuePairs[2]
+	assert.Equal(t, "Công ty ABC", seller.Value)
+	assert.Empty(t, seller.Disagreements, "values differing only in case and spacing agree")
+
+	taxCode := data.KeyValuePairs[3]
+	assert.Equal(t, "tesseract", taxCode.Provider)
+	assert.Empty(t, taxCode.Disagreements)
+
+	require.Len(t, data.Summary, 1)
+	assert.Equal(t, "gemini", data.Summary[0].Provider)
+	assert.Empty(t, data.Summary[0].Disagreements)
+
+	assert.Equal(t, "gemini", data.Provider)
+	assert.Equal(t, []string{"Tên hàng"}, data.Table.Headers)
+	assert.Equal(t, 0.9, *data.Confidence)
+}
+
+func TestCompositeExtraction_Ensemble_PartialFailure(t *testing.T) {
+	s, err := NewCompositeExtraction(ModeEnsemble, []NamedService{
+		{Name: "gemini", Service: &stubService{err: errors.New("gemini API error: unavailable")}},
+		{Name: "tesseract", Service: &stubService{data: invoice.ExtractedData{
+			KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "1"}},
+		}}},
+	})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tesseract", data.Provider)
+	assert.Equal(t, "tesseract", data.KeyValuePairs[0].Provider)
+}
+
+func TestNewCompositeExtraction_Invalid(t *testing.T) {
+	_, err := NewCompositeExtraction("vote", []NamedService{{Name: "gemini", Service: &stubService{}}})
+	assert.Error(t, err)
+
+	_, err = NewCompositeExtraction(ModeFallback, nil)
+	assert.Error(t, err)
+}
+
+func TestNewCompositeExtractionFromConfig(t *testing.T) {
+	s, err := New(ProviderComposite, ProviderConfig{
+		Mode: ModeEnsemble,
+		Providers: []ProviderConfig{
+			{Name: ProviderOpenAI, Model: "qwen2.5-vl"},
+			{Name: ProviderTesseract},
+		},
+	})
+	require.NoError(t, err)
+
+	pdf := s.(*PDFExtraction)
+	assert.True(t, pdf.native)
+	composite := pdf.service.(*CompositeExtraction)
+	assert.Equal(t, ModeEnsemble, composite.mode)
+	require.Len(t, composite.services, 2)
+	assert.Equal(t, ProviderOpenAI, composite.services[0].Name)
+	assert.Equal(t, ProviderTesseract, composite.services[1].Name)
+
+	_, err = New(ProviderComposite, ProviderConfig{Providers: []ProviderConfig{{Name: ProviderComposite}}})
+	assert.Error(t, err)
+
+	_, err = New(ProviderComposite, ProviderConfig{Providers: []ProviderConfig{{Name: ProviderTesseract}, {Name: "unknown"}}})
+	assert.Error(t, err)
+}
+
+func TestCompositeExtraction_Fallback_KeepsUsageOfFailedProviders(t *testing.T) {
+	primary := &stubService{
+		data: invoice.ExtractedData{Usage: []invoice.Usage{{Provider: "gemini", TotalTokens: 1500}}},
+		err:  invoice.NewRetryableError(errors.New("failed to parse response")),
+	}
+	secondary := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "1"}},
+		Usage:         []invoice.Usage{{Provider: "tesseract", LatencyMs: 700}},
+	}}
+
+	s, err := NewCompositeExtraction(ModeFallback, []NamedService{
+		{Name: "gemini", Service: primary},
+		{Name: "tesseract", Service: secondary},
+	})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	require.Len(t, data.Usage, 2)
+	assert.Equal(t, "failed to parse response", data.Usage[0].Error)
+	assert.Equal(t, 1500, data.Usage[0].TotalTokens)
+	assert.Empty(t, data.Usage[1].Error)
+}
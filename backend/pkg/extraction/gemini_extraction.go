@@ -2,9 +2,8 @@ package extraction
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"invoice-scan/backend/internal/domain/invoice"
@@ -12,134 +11,163 @@ import (
 	"google.golang.org/genai"
 )
 
+const defaultGeminiModel = "gemini-2.5-flash"
+
+func init() {
+	Register(ProviderGemini, func(cfg ProviderConfig) (invoice.ExtractionService, error) {
+		return NewGeminiExtraction(cfg)
+	})
+}
+
 type GeminiExtraction struct {
 	client  *genai.Client
 	apiKey  string
+	model   string
 	timeout time.Duration
+	prompts *PromptStore
 }
 
-func NewGeminiExtraction(apiKey string) (*GeminiExtraction, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
+func NewGeminiExtraction(cfg ProviderConfig) (*GeminiExtraction, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("gemini API key is required")
+	}
+
+	clientConfig := &genai.ClientConfig{
+		APIKey:  cfg.APIKey,
 		Backend: genai.BackendGeminiAPI,
-	})
+	}
+	if cfg.BaseURL != "" {
+		clientConfig.HTTPOptions.BaseURL = cfg.BaseURL
+	}
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, clientConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
+	model := cfg.Model
+	if model == "" {
+		model = defaultGeminiModel
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
 	return &GeminiExtraction{
 		client:  client,
-		apiKey:  apiKey,
-		timeout: 90 * time.Second,
+		apiKey:  cfg.APIKey,
+		model:   model,
+		timeout: timeout,
+		prompts: promptStoreOrDefault(cfg.Prompts),
 	}, nil
 }
 
+// readsPDF: Gemini reads PDF documents natively
+func (s *GeminiExtraction) readsPDF() bool {
+	return true
+}
+
 func (s *GeminiExtraction) Close() error {
 	return nil
 }
 
-func (s *GeminiExtraction) Extract(ctx context.Context, imageBytes []byte, mimeType string) (invoice.ExtractedData, error) {
+func (s *GeminiExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	const maxSize = 10 * 1024 * 1024
-	if len(imageBytes) > maxSize {
-		return invoice.ExtractedData{}, fmt.Errorf("image too large (max 10MB)")
-	}
-
-	if len(imageBytes) == 0 {
-		return invoice.ExtractedData{}, fmt.Errorf("empty image data")
+	images, err := validateImages(images, validateDocument)
+	if err != nil {
+		return invoice.ExtractedData{}, err
 	}
 
-	if mimeType == "" {
-		mimeType = "image/jpeg"
+	prompt, promptVersion, err := s.prompts.Render(opts, true)
+	if err != nil {
+		return invoice.ExtractedData{}, err
 	}
 
-	if !strings.HasPrefix(mimeType, "image/") {
-		return invoice.ExtractedData{}, fmt.Errorf("invalid image type: %s", mimeType)
+	model := s.model
+	if opts.Model != "" {
+		model = opts.Model
 	}
 
-	prompt := buildExtractionPrompt()
-
 	parts := []*genai.Part{
 		{Text: prompt},
-		{InlineData: &genai.Blob{Data: imageBytes, MIMEType: mimeType}},
+	}
+	if len(images) > 1 {
+		parts = append(parts, &genai.Part{Text: pagesNote})
+	}
+	for _, image := range images {
+		parts = append(parts, &genai.Part{InlineData: &genai.Blob{Data: image.Bytes, MIMEType: image.MIMEType}})
 	}
 
 	contents := []*genai.Content{
 		{Parts: parts},
 	}
 
-	result, err := s.client.Models.GenerateContent(ctx, "gemini-2.5-flash", contents, nil)
+	config := &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   extractionSchema,
+	}
+
+	start := time.Now()
+	result, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	usage := geminiUsage(model, start, result)
 	if err != nil {
-		return invoice.ExtractedData{}, fmt.Errorf("gemini API error: %w", err)
+		return usageOnly(usage), classifyGeminiError(fmt.Errorf("gemini API error: %w", err))
 	}
 
 	if result == nil || len(result.Candidates) == 0 {
-		return invoice.ExtractedData{}, fmt.Errorf("empty response from Gemini API")
+		return usageOnly(usage), invoice.NewRetryableError(fmt.Errorf("empty response from Gemini API"))
 	}
 
 	text := result.Text()
 	if text == "" {
-		return invoice.ExtractedData{}, fmt.Errorf("empty text in Gemini response")
+		return usageOnly(usage), invoice.NewRetryableError(fmt.Errorf("empty text in Gemini response"))
 	}
 
-	invoiceData, err := parseGeminiResponse(text)
+	invoiceData, err := parseModelResponse(text)
 	if err != nil {
-		return invoice.ExtractedData{}, fmt.Errorf("failed to parse response: %w", err)
+		err = fmt.Errorf("failed to parse response: %w", err)
+		if isTruncatedJSON(err) || result.Candidates[0].FinishReason == genai.FinishReasonMaxTokens {
+			return usageOnly(usage), invoice.NewRetryableError(err)
+		}
+		return usageOnly(usage), invoice.NewPermanentError(err)
 	}
 
+	cleanBoundingBoxes(&invoiceData, countPages(images))
+	invoiceData.PromptVersion = promptVersion
+	invoiceData.Usage = []invoice.Usage{usage}
 	return invoiceData, nil
 }
 
-func buildExtractionPrompt() string {
-	return `Extract invoice data from this image and return ONLY valid JSON in the following format:
-
-{
-  "key_value_pairs": [
-    {"key": "Invoice Number", "value": "...", "confidence": 0.95},
-    {"key": "Date", "value": "...", "confidence": 0.90},
-    {"key": "Vendor", "value": "...", "confidence": 0.85}
-  ],
-  "table": {
-    "headers": ["Item", "Quantity", "Price", "Total"],
-    "rows": [
-      ["Item 1", "2", "100000", "200000"],
-      ["Item 2", "1", "50000", "50000"]
-    ]
-  },
-  "summary": [
-    {"key": "Subtotal", "value": "...", "confidence": 0.95},
-    {"key": "Tax", "value": "...", "confidence": 0.90},
-    {"key": "Total", "value": "...", "confidence": 0.95}
-  ],
-  "confidence": 0.90
-}
+// geminiUsage reads the token counts of a response. Thinking tokens are billed
+// as output.
+func geminiUsage(model string, start time.Time, result *genai.GenerateContentResponse) invoice.Usage {
+	usage := newUsage(ProviderGemini, model, start)
+	if result == nil || result.UsageMetadata == nil {
+		return usage
+	}
 
-Rules:
-- Extract all visible invoice information
-- Support Vietnamese language (both printed and handwritten)
-- If table exists, extract it with headers and rows
-- If no table, set "table" to null
-- Include confidence scores (0.0 to 1.0) for each field
-- Return ONLY the JSON object, no additional text or markdown
-- Use Vietnamese field names if the invoice is in Vietnamese
-- Extract dates, amounts, and numbers accurately`
+	metadata := result.UsageMetadata
+	usage.PromptTokens = int(metadata.PromptTokenCount)
+	usage.OutputTokens = int(metadata.CandidatesTokenCount + metadata.ThoughtsTokenCount)
+	usage.TotalTokens = int(metadata.TotalTokenCount)
+	return usage
 }
 
-func parseGeminiResponse(text string) (invoice.ExtractedData, error) {
-	text = strings.TrimSpace(text)
-
-	text = strings.TrimPrefix(text, "```json")
-	text = strings.TrimPrefix(text, "```")
-	text = strings.TrimSuffix(text, "```")
-	text = strings.TrimSpace(text)
-
-	var extractedData invoice.ExtractedData
-	if err := json.Unmarshal([]byte(text), &extractedData); err != nil {
-		return invoice.ExtractedData{}, fmt.Errorf("failed to unmarshal JSON: %w", err)
+// classifyGeminiError marks timeouts, rate limits and server errors as
+// retryable. Other API errors (bad request, auth, ...) are permanent.
+func classifyGeminiError(err error) error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		if isRetryableStatus(apiErr.Code) {
+			return invoice.NewRetryableError(err)
+		}
+		return invoice.NewPermanentError(err)
 	}
 
-	return extractedData, nil
+	return classifyTransportError(err)
 }
@@ -0,0 +1,223 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestClassifyGeminiError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "rate limited", err: genai.APIError{Code: 429}, retryable: true},
+		{name: "server error", err: genai.APIError{Code: 503}, retryable: true},
+		{name: "bad request", err: genai.APIError{Code: 400}, retryable: false},
+		{name: "unauthorized", err: genai.APIError{Code: 401}, retryable: false},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, retryable: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := classifyGeminiError(fmt.Errorf("gemini API error: %w", test.err))
+			assert.Equal(t, test.retryable, invoice.IsRetryable(err))
+		})
+	}
+}
+
+func TestIsTruncatedJSON(t *testing.T) {
+	_, err := parseModelResponse(`{"key_value_pairs": [{"key": "Invoice Number", "value": "INV`)
+	assert.Error(t, err)
+	assert.True(t, isTruncatedJSON(err))
+
+	_, err = parseModelResponse(`not json at all`)
+	assert.Error(t, err)
+	assert.False(t, isTruncatedJSON(err))
+
+	assert.False(t, isTruncatedJSON(errors.New("some error")))
+}
+
+func TestParseModelResponse(t *testing.T) {
+	text := "```json\n" + `{
+  "key_value_pairs": [{"key": "Invoice Number", "value": "INV-001", "confidence": 0.95}],
+  "table": {"headers": ["Item"], "rows": [["Coffee"]]},
+  "summary": [{"key": "Total", "value": "50000"}]
+}` + "\n```"
+
+	data, err := parseModelResponse(text)
+	assert.NoError(t, err)
+	assert.Len(t, data.KeyValuePairs, 1)
+	assert.Equal(t, "INV-001", data.KeyValuePairs[0].Value)
+	assert.Equal(t, []string{"Item"}, data.Table.Headers)
+	assert.Equal(t, "50000", data.Summary[0].Value)
+}
+
+func TestGeminiExtraction_Extract_StructuredOutput(t *testing.T) {
+	var body struct {
+		GenerationConfig struct {
+			ResponseMIMEType string        `json:"responseMimeType"`
+			ResponseSchema   *genai.Schema `json:"responseSchema"`
+		} `json:"generationConfig"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.URL.Path, "gemini-2.5-flash:generateContent")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{
+				{"content": map[string]interface{}{"parts": []map[string]string{{"text": testInvoiceJSON}}}, "finishReason": "STOP"},
+			},
+			"usageMetadata": map[string]int{
+				"promptTokenCount":     1290,
+				"candidatesTokenCount": 310,
+				"thoughtsTokenCount":   100,
+				"totalTokenCount":      1700,
+			},
+		})
+	}))
+	defer server.Close()
+
+	s, err := NewGeminiExtraction(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "INV-001", data.KeyValuePairs[0].Value)
+	assert.Equal(t, "v1", data.PromptVersion)
+
+	require.Len(t, data.Usage, 1)
+	assert.Equal(t, ProviderGemini, data.Usage[0].Provider)
+	assert.Equal(t, "gemini-2.5-flash", data.Usage[0].Model)
+	assert.Equal(t, 1290, data.Usage[0].PromptTokens)
+	assert.Equal(t, 410, data.Usage[0].OutputTokens)
+	assert.Equal(t, 1700, data.Usage[0].TotalTokens)
+
+	assert.Equal(t, "application/json", body.GenerationConfig.ResponseMIMEType)
+	require.NotNil(t, body.GenerationConfig.ResponseSchema)
+	assert.Equal(t, extractionSchema.Required, body.GenerationConfig.ResponseSchema.Required)
+}
+
+func TestGeminiExtraction_Extract_Pages(t *testing.T) {
+	var body struct {
+		Contents []*genai.Content `json:"contents"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{
+				{"content": map[string]interface{}{"parts": []map[string]string{{"text": testInvoiceJSON}}}, "finishReason": "STOP"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	s, err := NewGeminiExtraction(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("%PDF-1.4"), MIMEType: "application/pdf"},
+	}, invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	// one request with the prompt, the note on pages and every page in order
+	require.Len(t, body.Contents, 1)
+	parts := body.Contents[0].Parts
+	require.Len(t, parts, 4)
+	assert.Equal(t, pagesNote, parts[1].Text)
+	assert.Equal(t, []byte("front"), parts[2].InlineData.Data)
+	assert.Equal(t, "image/jpeg", parts[2].InlineData.MIMEType)
+	assert.Equal(t, "application/pdf", parts[3].InlineData.MIMEType)
+}
+
+func TestGeminiExtraction_Extract_BoundingBoxes(t *testing.T) {
+	var body struct {
+		Contents []*genai.Content `json:"contents"`
+	}
+	response := `{
+  "key_value_pairs": [
+    {"key": "Invoice Number", "value": "INV-001", "bounding_box": {"page": 1, "x": 0.1, "y": 0.05, "width": 0.3, "height": 0.02}},
+    {"key": "Date", "value": "2024-03-15", "bounding_box": {"x": 120, "y": 40, "width": 200, "height": 16}}
+  ],
+  "table": {"headers": ["Item"], "rows": [["Coffee"], ["Tea"]], "cell_boxes": [[{"x": 0.1, "y": 0.4, "width": 0.2, "height": 0.02}]]},
+  "summary": [{"key": "Total", "value": "50000", "bounding_box": {"page": 2, "x": 0.6, "y": 0.9, "width": 0.3, "height": 0.03}}]
+}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{
+				{"content": map[string]interface{}{"parts": []map[string]string{{"text": response}}}, "finishReason": "STOP"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	s, err := NewGeminiExtraction(ProviderConfig{APIKey: "key", BaseURL: server.URL})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{PromptVersion: "v2"})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, body.Contents)
+	assert.Contains(t, body.Contents[0].Parts[0].Text, `"bounding_box"`)
+
+	assert.Equal(t, &invoice.BoundingBox{Page: 1, X: 0.1, Y: 0.05, Width: 0.3, Height: 0.02}, data.KeyValuePairs[0].BoundingBox)
+	assert.Nil(t, data.KeyValuePairs[1].BoundingBox, "boxes in pixels are dropped")
+	assert.Nil(t, data.Summary[0].BoundingBox, "boxes on missing pages are dropped")
+
+	// cell boxes follow the shape of the rows
+	require.Len(t, data.Table.CellBoxes, 2)
+	assert.Equal(t, 1, data.Table.CellBoxes[0][0].Page)
+	assert.Equal(t, []*invoice.BoundingBox{nil}, data.Table.CellBoxes[1])
+}
+
+func TestCleanBoundingBox(t *testing.T) {
+	tests := []struct {
+		name  string
+		box   *invoice.BoundingBox
+		pages int
+		want  *invoice.BoundingBox
+	}{
+		{name: "nil", box: nil, pages: 1, want: nil},
+		{name: "valid", box: &invoice.BoundingBox{Page: 2, X: 0.5, Y: 0.5, Width: 0.1, Height: 0.1}, pages: 2, want: &invoice.BoundingBox{Page: 2, X: 0.5, Y: 0.5, Width: 0.1, Height: 0.1}},
+		{name: "no page", box: &invoice.BoundingBox{X: 0.5, Y: 0.5, Width: 0.1, Height: 0.1}, pages: 1, want: &invoice.BoundingBox{Page: 1, X: 0.5, Y: 0.5, Width: 0.1, Height: 0.1}},
+		{name: "page out of range", box: &invoice.BoundingBox{Page: 3, X: 0.5, Y: 0.5, Width: 0.1, Height: 0.1}, pages: 2, want: nil},
+		{name: "pixels", box: &invoice.BoundingBox{X: 120, Y: 40, Width: 200, Height: 16}, pages: 1, want: nil},
+		{name: "empty", box: &invoice.BoundingBox{X: 0.5, Y: 0.5}, pages: 1, want: nil},
+		{name: "rounding overflow", box: &invoice.BoundingBox{Page: 1, X: 0.5, Y: 0.9, Width: 0.505, Height: 0.1}, pages: 1, want: &invoice.BoundingBox{Page: 1, X: 0.5, Y: 0.9, Width: 0.5, Height: 0.1}},
+		{name: "overflow", box: &invoice.BoundingBox{X: 0.5, Y: 0.5, Width: 0.8, Height: 0.1}, pages: 1, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := cleanBoundingBox(test.box, test.pages)
+			if test.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, test.want.Page, got.Page)
+			assert.InDelta(t, test.want.X, got.X, 1e-9)
+			assert.InDelta(t, test.want.Y, got.Y, 1e-9)
+			assert.InDelta(t, test.want.Width, got.Width, 1e-9)
+			assert.InDelta(t, test.want.Height, got.Height, 1e-9)
+		})
+	}
+}
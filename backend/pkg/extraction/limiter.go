@@ -0,0 +1,299 @@
+package extraction
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+const defaultEstimatedTokens = 2000
+
+// LimitConfig limits the calls made to one provider. Zero values disable the
+// corresponding limit.
+type LimitConfig struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+	// EstimatedTokens is the number of tokens reserved per request against
+	// TokensPerMinute, defaults to defaultEstimatedTokens
+	EstimatedTokens int
+	MaxConcurrency  int
+}
+
+func (c LimitConfig) enabled() bool {
+	return c.RequestsPerMinute > 0 || c.TokensPerMinute > 0 || c.MaxConcurrency > 0
+}
+
+// LimiterState is a snapshot of a provider limiter, for monitoring
+type LimiterState struct {
+	Provider          string  `json:"provider"`
+	RequestsPerMinute int     `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int     `json:"tokens_per_minute,omitempty"`
+	MaxConcurrency    int     `json:"max_concurrency,omitempty"`
+	AvailableRequests float64 `json:"available_requests"`
+	AvailableTokens   float64 `json:"available_tokens"`
+	InFlight          int     `json:"in_flight"`
+	Waiting           int     `json:"waiting"`
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[*LimitedExtraction]struct{})
+)
+
+// LimiterStates returns the state of every open provider limiter
+func LimiterStates() []LimiterState {
+	limitersMu.Lock()
+	all := make([]*LimitedExtraction, 0, len(limiters))
+	for l := range limiters {
+		all = append(all, l)
+	}
+	limitersMu.Unlock()
+
+	states := make([]LimiterState, len(all))
+	for i, l := range all {
+		states[i] = l.State()
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Provider < states[j].Provider })
+	return states
+}
+
+// LimitedExtraction wraps a provider with token bucket rate limits on requests
+// and tokens per minute and a cap on concurrent calls. Calls over the limits
+// wait for their turn, in order, until their context is done.
+type LimitedExtraction struct {
+	service  invoice.ExtractionService
+	provider string
+	cfg      LimitConfig
+
+	requests *tokenBucket
+	tokens   *tokenBucket
+	slots    chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	waiting  int
+}
+
+func NewLimitedExtraction(provider string, service invoice.ExtractionService, cfg LimitConfig) *LimitedExtraction {
+	if cfg.EstimatedTokens <= 0 {
+		cfg.EstimatedTokens = defaultEstimatedTokens
+	}
+
+	l := &LimitedExtraction{
+		service:  service,
+		provider: provider,
+		cfg:      cfg,
+	}
+	if cfg.RequestsPerMinute > 0 {
+		l.requests = newTokenBucket(cfg.RequestsPerMinute, time.Minute)
+	}
+	if cfg.TokensPerMinute > 0 {
+		l.tokens = newTokenBucket(cfg.TokensPerMinute, time.Minute)
+	}
+	if cfg.MaxConcurrency > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrency)
+	}
+
+	limitersMu.Lock()
+	limiters[l] = struct{}{}
+	limitersMu.Unlock()
+
+	return l
+}
+
+func (l *LimitedExtraction) Close() error {
+	limitersMu.Lock()
+	delete(limiters, l)
+	limitersMu.Unlock()
+
+	return l.service.Close()
+}
+
+func (l *LimitedExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	release, err := l.acquire(ctx)
+	if err != nil {
+		return invoice.ExtractedData{}, err
+	}
+	defer release()
+
+	data, err := l.service.Extract(ctx, images, opts)
+	if l.tokens != nil {
+		// settle the estimate reserved for the call with the tokens it used
+		if used := totalTokens(data.Usage); used > 0 {
+			l.tokens.refund(float64(l.cfg.EstimatedTokens - used))
+		}
+	}
+	return data, err
+}
+
+// acquire waits for a concurrency slot and for the rate limits. The returned
+// release frees the slot.
+func (l *LimitedExtraction) acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+
+	waited := func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			waited()
+			return nil, l.waitError(ctx)
+		}
+	}
+
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if err := l.waitRate(ctx); err != nil {
+		waited()
+		release()
+		return nil, err
+	}
+
+	l.mu.Lock()
+	l.waiting--
+	l.inFlight++
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+		release()
+	}, nil
+}
+
+// waitRate reserves a request and the estimated tokens, then sleeps until both
+// buckets have refilled enough. The reservations are returned when ctx is done
+// first.
+func (l *LimitedExtraction) waitRate(ctx context.Context) error {
+	var delay time.Duration
+	if l.requests != nil {
+		delay = max(delay, l.requests.reserve(1))
+	}
+	if l.tokens != nil {
+		delay = max(delay, l.tokens.reserve(float64(l.cfg.EstimatedTokens)))
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if l.requests != nil {
+			l.requests.refund(1)
+		}
+		if l.tokens != nil {
+			l.tokens.refund(float64(l.cfg.EstimatedTokens))
+		}
+		return l.waitError(ctx)
+	}
+}
+
+func (l *LimitedExtraction) waitError(ctx context.Context) error {
+	return invoice.NewRetryableError(fmt.Errorf("%s rate limit: gave up waiting: %w", l.provider, ctx.Err()))
+}
+
+func (l *LimitedExtraction) State() LimiterState {
+	l.mu.Lock()
+	inFlight, waiting := l.inFlight, l.waiting
+	l.mu.Unlock()
+
+	state := LimiterState{
+		Provider:          l.provider,
+		RequestsPerMinute: l.cfg.RequestsPerMinute,
+		TokensPerMinute:   l.cfg.TokensPerMinute,
+		MaxConcurrency:    l.cfg.MaxConcurrency,
+		InFlight:          inFlight,
+		Waiting:           waiting,
+	}
+	if l.requests != nil {
+		state.AvailableRequests = l.requests.available()
+	}
+	if l.tokens != nil {
+		state.AvailableTokens = l.tokens.available()
+	}
+	return state
+}
+
+// tokenBucket holds up to capacity tokens and refills capacity tokens per
+// period. Reservations may drive it below zero; the caller then waits until it
+// is back at zero, so waiting callers are served in order.
+type tokenBucket struct {
+	capacity float64
+	perNano  float64
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(capacity int, period time.Duration) *tokenBucket {
+	now := time.Now
+	return &tokenBucket{
+		capacity: float64(capacity),
+		perNano:  float64(capacity) / float64(period),
+		now:      now,
+		tokens:   float64(capacity),
+		last:     now(),
+	}
+}
+
+func (b *tokenBucket) refill() {
+	now := b.now()
+	b.tokens = min(b.capacity, b.tokens+float64(now.Sub(b.last))*b.perNano)
+	b.last = now
+}
+
+// reserve takes n tokens and returns how long to wait before using them. n is
+// capped at the capacity so a single large request can still go through.
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens -= min(n, b.capacity)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-b.tokens / b.perNano))
+}
+
+// refund gives back n reserved tokens that were not used. A negative n takes
+// the tokens used over a reservation.
+func (b *tokenBucket) refund(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens = min(b.capacity, b.tokens+min(n, b.capacity))
+}
+
+func (b *tokenBucket) available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	return b.tokens
+}
@@ -0,0 +1,160 @@
+package extraction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingService holds every call until release is closed
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingService() *blockingService {
+	return &blockingService{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (s *blockingService) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return invoice.ExtractedData{}, nil
+}
+
+func (s *blockingService) Close() error {
+	return nil
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(60, time.Minute)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	assert.Zero(t, b.reserve(60))
+	assert.Equal(t, time.Second, b.reserve(1))
+	assert.Equal(t, 2*time.Second, b.reserve(1))
+
+	now = now.Add(2 * time.Second)
+	assert.InDelta(t, 0, b.available(), 1e-9)
+
+	b.refund(1)
+	assert.InDelta(t, 1, b.available(), 1e-9)
+
+	// larger than the bucket, capped so it still goes through
+	now = now.Add(time.Minute)
+	assert.Zero(t, b.reserve(1000))
+	assert.InDelta(t, 0, b.available(), 1e-9)
+}
+
+func TestLimitedExtraction_QueuesOverConcurrency(t *testing.T) {
+	service := newBlockingService()
+	l := NewLimitedExtraction("gemini", service, LimitConfig{MaxConcurrency: 1})
+	defer l.Close()
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := l.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+			errs <- err
+		}()
+	}
+
+	<-service.started
+	require.Eventually(t, func() bool {
+		state := l.State()
+		return state.InFlight == 1 && state.Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	select {
+	case <-service.started:
+		t.Fatal("second call started over the concurrency limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(service.release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+
+	state := l.State()
+	assert.Equal(t, 0, state.InFlight)
+	assert.Equal(t, 0, state.Waiting)
+}
+
+func TestLimitedExtraction_GivesUpWhenContextDone(t *testing.T) {
+	service := newBlockingService()
+	l := NewLimitedExtraction("openai", service, LimitConfig{RequestsPerMinute: 1})
+	defer l.Close()
+	close(service.release)
+
+	_, err := l.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = l.Extract(ctx, oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.True(t, invoice.IsRetryable(err))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the cancelled reservation is given back
+	assert.InDelta(t, 0, l.State().AvailableRequests, 0.01)
+	assert.Equal(t, 0, l.State().Waiting)
+}
+
+func TestLimitedExtraction_SettlesTokensWithUsage(t *testing.T) {
+	service := &stubService{data: invoice.ExtractedData{Usage: []invoice.Usage{{TotalTokens: 500}}}}
+	l := NewLimitedExtraction("gemini", service, LimitConfig{TokensPerMinute: 10000, EstimatedTokens: 2000})
+	defer l.Close()
+
+	_, err := l.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.InDelta(t, 9500, l.State().AvailableTokens, 1)
+
+	service.data.Usage[0].TotalTokens = 4000
+	_, err = l.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.InDelta(t, 5500, l.State().AvailableTokens, 1)
+}
+
+func TestNew_WrapsLimitedProviders(t *testing.T) {
+	s, err := New(ProviderReplay, ProviderConfig{
+		FixturesDir: t.TempDir(),
+		Limits:      LimitConfig{RequestsPerMinute: 30, TokensPerMinute: 1000},
+	})
+	require.NoError(t, err)
+
+	_, ok := s.(*PDFExtraction).service.(*LimitedExtraction)
+	require.True(t, ok)
+
+	var state *LimiterState
+	for _, st := range LimiterStates() {
+		if st.Provider == ProviderReplay {
+			state = &st
+		}
+	}
+	require.NotNil(t, state)
+	assert.Equal(t, 30, state.RequestsPerMinute)
+	assert.InDelta(t, 30, state.AvailableRequests, 0.01)
+	assert.InDelta(t, 1000, state.AvailableTokens, 0.01)
+
+	require.NoError(t, s.Close())
+	for _, st := range LimiterStates() {
+		assert.NotEqual(t, ProviderReplay, st.Provider)
+	}
+
+	s, err = New(ProviderReplay, ProviderConfig{FixturesDir: t.TempDir()})
+	require.NoError(t, err)
+	_, ok = s.(*PDFExtraction).service.(*LimitedExtraction)
+	assert.False(t, ok)
+}
@@ -0,0 +1,244 @@
+package extraction
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+const defaultOpenAIBaseURL = "http://localhost:8000/v1"
+
+func init() {
+	Register(ProviderOpenAI, func(cfg ProviderConfig) (invoice.ExtractionService, error) {
+		return NewOpenAIExtraction(cfg)
+	})
+}
+
+// OpenAIExtraction extracts invoices through the OpenAI chat completions API
+// with an image_url content part per page. It works with any compatible server
+// such as vLLM or llama.cpp; vLLM takes more than one image per request only
+// with --limit-mm-per-prompt.
+type OpenAIExtraction struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	maxTokens  int
+	jsonMode   bool
+	timeout    time.Duration
+	prompts    *PromptStore
+}
+
+func NewOpenAIExtraction(cfg ProviderConfig) (*OpenAIExtraction, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("openai model is required")
+	}
+
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &OpenAIExtraction{
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		maxTokens:  cfg.MaxTokens,
+		jsonMode:   cfg.JSONMode,
+		timeout:    timeout,
+		prompts:    promptStoreOrDefault(cfg.Prompts),
+	}, nil
+}
+
+func (s *OpenAIExtraction) Close() error {
+	s.httpClient.CloseIdleConnections()
+	return nil
+}
+
+type (
+	openAIChatRequest struct {
+		Model          string                `json:"model"`
+		Messages       []openAIMessage       `json:"messages"`
+		Temperature    float64               `json:"temperature"`
+		MaxTokens      int                   `json:"max_tokens,omitempty"`
+		ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+	}
+
+	openAIMessage struct {
+		Role    string              `json:"role"`
+		Content []openAIContentPart `json:"content"`
+	}
+
+	openAIContentPart struct {
+		Type     string          `json:"type"`
+		Text     string          `json:"text,omitempty"`
+		ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	}
+
+	openAIImageURL struct {
+		URL string `json:"url"`
+	}
+
+	openAIResponseFormat struct {
+		Type string `json:"type"`
+	}
+
+	openAIChatResponse struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+
+	openAIErrorResponse struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+func (s *OpenAIExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	images, err := validateImages(images, validateImage)
+	if err != nil {
+		return invoice.ExtractedData{}, err
+	}
+
+	prompt, promptVersion, err := s.prompts.Render(opts, false)
+	if err != nil {
+		return invoice.ExtractedData{}, err
+	}
+
+	model := s.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	content := []openAIContentPart{
+		{Type: "text", Text: prompt},
+	}
+	if len(images) > 1 {
+		content = append(content, openAIContentPart{Type: "text", Text: pagesNote})
+	}
+	for _, image := range images {
+		imageURL := "data:" + image.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(image.Bytes)
+		content = append(content, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: imageURL}})
+	}
+
+	chatRequest := openAIChatRequest{
+		Model: model,
+		Messages: []openAIMessage{
+			{Role: "user", Content: content},
+		},
+		MaxTokens: s.maxTokens,
+	}
+	if s.jsonMode {
+		chatRequest.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+	}
+
+	start := time.Now()
+	result, err := s.createChatCompletion(ctx, chatRequest)
+	usage := newUsage(ProviderOpenAI, model, start)
+	if err != nil {
+		return usageOnly(usage), err
+	}
+	if result.Usage != nil {
+		usage.PromptTokens = result.Usage.PromptTokens
+		usage.OutputTokens = result.Usage.CompletionTokens
+		usage.TotalTokens = result.Usage.TotalTokens
+	}
+
+	if len(result.Choices) == 0 {
+		return usageOnly(usage), invoice.NewRetryableError(fmt.Errorf("empty response from openai API"))
+	}
+
+	choice := result.Choices[0]
+	if choice.Message.Content == "" {
+		return usageOnly(usage), invoice.NewRetryableError(fmt.Errorf("empty text in openai response"))
+	}
+
+	invoiceData, err := parseModelResponse(choice.Message.Content)
+	if err != nil {
+		err = fmt.Errorf("failed to parse response: %w", err)
+		if isTruncatedJSON(err) || choice.FinishReason == "length" {
+			return usageOnly(usage), invoice.NewRetryableError(err)
+		}
+		return usageOnly(usage), invoice.NewPermanentError(err)
+	}
+
+	cleanBoundingBoxes(&invoiceData, countPages(images))
+	invoiceData.PromptVersion = promptVersion
+	invoiceData.Usage = []invoice.Usage{usage}
+	return invoiceData, nil
+}
+
+func (s *OpenAIExtraction) createChatCompletion(ctx context.Context, chatRequest openAIChatRequest) (*openAIChatResponse, error) {
+	body, err := json.Marshal(chatRequest)
+	if err != nil {
+		return nil, invoice.NewPermanentError(fmt.Errorf("failed to marshal request: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, invoice.NewPermanentError(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, classifyTransportError(fmt.Errorf("openai API error: %w", err))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, classifyTransportError(fmt.Errorf("openai API error: failed to read response: %w", err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(respBody))
+		var errResp openAIErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			message = errResp.Error.Message
+		}
+
+		err := fmt.Errorf("openai API error: status %d: %s", resp.StatusCode, message)
+		if isRetryableStatus(resp.StatusCode) {
+			return nil, invoice.NewRetryableError(err)
+		}
+		return nil, invoice.NewPermanentError(err)
+	}
+
+	var result openAIChatResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, invoice.NewRetryableError(fmt.Errorf("openai API error: invalid response: %w", err))
+	}
+
+	return &result, nil
+}
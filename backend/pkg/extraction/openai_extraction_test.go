@@ -0,0 +1,252 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInvoiceJSON = `{"key_value_pairs": [{"key": "Invoice Number", "value": "INV-001", "confidence": 0.95}], "table": {"headers": ["Item"], "rows": [["Coffee"]]}, "summary": [{"key": "Total", "value": "50000"}]}`
+
+func newOpenAIServer(t *testing.T, handler func(w http.ResponseWriter, req openAIChatRequest)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+
+		var req openAIChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		handler(w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeChatResponse(w http.ResponseWriter, content, finishReason string) {
+	resp := map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]string{"role": "assistant", "content": content}, "finish_reason": finishReason},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestOpenAIExtraction_Extract(t *testing.T) {
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+
+		var req openAIChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		assert.Equal(t, "qwen2.5-vl", req.Model)
+		require.Len(t, req.Messages, 1)
+		require.Len(t, req.Messages[0].Content, 2)
+		assert.Contains(t, req.Messages[0].Content[0].Text, "Extract invoice data from this image")
+		assert.Equal(t, "data:image/png;base64,aW1hZ2U=", req.Messages[0].Content[1].ImageURL.URL)
+		assert.Nil(t, req.ResponseFormat)
+
+		writeChatResponse(w, "```json\n"+testInvoiceJSON+"\n```", "stop")
+	}))
+	defer server.Close()
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", APIKey: "secret", BaseURL: server.URL + "/"})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authHeader)
+	assert.Equal(t, "INV-001", data.KeyValuePairs[0].Value)
+	assert.Equal(t, []string{"Item"}, data.Table.Headers)
+	assert.Equal(t, "v1", data.PromptVersion)
+}
+
+func TestOpenAIExtraction_Extract_Pages(t *testing.T) {
+	server := newOpenAIServer(t, func(w http.ResponseWriter, req openAIChatRequest) {
+		require.Len(t, req.Messages, 1)
+		content := req.Messages[0].Content
+		require.Len(t, content, 4)
+		assert.Equal(t, pagesNote, content[1].Text)
+		assert.Equal(t, "data:image/jpeg;base64,ZnJvbnQ=", content[2].ImageURL.URL)
+		assert.Equal(t, "data:image/jpeg;base64,YmFjaw==", content[3].ImageURL.URL)
+
+		writeChatResponse(w, testInvoiceJSON, "stop")
+	})
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("back"), MIMEType: "image/jpeg"},
+	}, invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: nil, MIMEType: "image/jpeg"},
+	}, invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "image 2: empty image data")
+
+	_, err = s.Extract(context.Background(), nil, invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "no image to extract")
+}
+
+func TestOpenAIExtraction_Extract_Usage(t *testing.T) {
+	server := newOpenAIServer(t, func(w http.ResponseWriter, req openAIChatRequest) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"choices": [{"message": {"content": "not json"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 900, "completion_tokens": 12, "total_tokens": 912}
+		}`))
+	})
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	// the tokens of a response that cannot be parsed are still accounted for
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	require.Len(t, data.Usage, 1)
+	assert.Equal(t, invoice.Usage{
+		Provider:     ProviderOpenAI,
+		Model:        "qwen2.5-vl",
+		PromptTokens: 900,
+		OutputTokens: 12,
+		TotalTokens:  912,
+		LatencyMs:    data.Usage[0].LatencyMs,
+	}, data.Usage[0])
+}
+
+func TestOpenAIExtraction_Extract_Options(t *testing.T) {
+	server := newOpenAIServer(t, func(w http.ResponseWriter, req openAIChatRequest) {
+		assert.Equal(t, "llava", req.Model)
+		assert.Equal(t, 2048, req.MaxTokens)
+		require.NotNil(t, req.ResponseFormat)
+		assert.Equal(t, "json_object", req.ResponseFormat.Type)
+		writeChatResponse(w, testInvoiceJSON, "stop")
+	})
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL + "/v1", MaxTokens: 2048, JSONMode: true})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{Model: "llava"})
+	assert.NoError(t, err)
+}
+
+func TestOpenAIExtraction_Extract_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   func(w http.ResponseWriter, req openAIChatRequest)
+		retryable bool
+	}{
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error": {"message": "slow down"}}`))
+			},
+			retryable: true,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			retryable: true,
+		},
+		{
+			name: "bad request",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": {"message": "model does not support images"}}`))
+			},
+			retryable: false,
+		},
+		{
+			name: "truncated output",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				writeChatResponse(w, `{"key_value_pairs": [{"key": "Invoice`, "length")
+			},
+			retryable: true,
+		},
+		{
+			name: "not json",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				writeChatResponse(w, "I cannot read this invoice", "stop")
+			},
+			retryable: false,
+		},
+		{
+			name: "no choices",
+			handler: func(w http.ResponseWriter, req openAIChatRequest) {
+				_, _ = w.Write([]byte(`{"choices": []}`))
+			},
+			retryable: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newOpenAIServer(t, test.handler)
+
+			s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL + "/v1"})
+			require.NoError(t, err)
+
+			_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+			require.Error(t, err)
+			assert.Equal(t, test.retryable, invoice.IsRetryable(err))
+		})
+	}
+}
+
+func TestOpenAIExtraction_Extract_ErrorMessage(t *testing.T) {
+	server := newOpenAIServer(t, func(w http.ResponseWriter, req openAIChatRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": {"message": "model does not support images"}}`))
+	})
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL + "/v1"})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "model does not support images")
+}
+
+func TestOpenAIExtraction_Extract_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl", BaseURL: server.URL, Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.True(t, invoice.IsRetryable(err))
+}
+
+func TestOpenAIExtraction_Extract_InvalidImage(t *testing.T) {
+	s, err := NewOpenAIExtraction(ProviderConfig{Model: "qwen2.5-vl"})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage(nil, "image/jpeg"), invoice.ExtractOptions{})
+	assert.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("%PDF"), "application/pdf"), invoice.ExtractOptions{})
+	assert.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+}
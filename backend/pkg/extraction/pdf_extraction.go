@@ -0,0 +1,210 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+)
+
+const (
+	defaultPDFCommand  = "pdftoppm"
+	defaultPDFDPI      = 200
+	defaultPDFMaxPages = 20
+
+	pdfPageMIMEType = "image/png"
+)
+
+// PDFConfig configures how PDF documents are handled for a provider
+type PDFConfig struct {
+	// Command is the pdftoppm binary that rasterizes the pages of a document
+	// for providers that cannot read PDF
+	Command string
+	// DPI is the resolution pages are rasterized at
+	DPI int
+	// MaxPages rejects longer documents
+	MaxPages int
+}
+
+// pdfReader is implemented by providers that are sent PDF documents as they
+// are rather than page by page
+type pdfReader interface {
+	readsPDF() bool
+}
+
+// pageObjectPattern matches page objects, but not the /Pages tree nodes
+var pageObjectPattern = regexp.MustCompile(`/Type\s*/Page(?:[^s]|$)`)
+
+// countPDFPages counts the page objects of a document. It returns 0 when the
+// pages are hidden in compressed object streams.
+func countPDFPages(documentBytes []byte) int {
+	return len(pageObjectPattern.FindAllIndex(documentBytes, -1))
+}
+
+// countPages counts the pages of a request: its images and the pages of its
+// PDF documents. A document whose pages cannot be counted is one page at least.
+func countPages(images []invoice.Image) int {
+	pages := 0
+	for _, image := range images {
+		if domainstorage.IsPDF(image.MIMEType) {
+			pages += max(countPDFPages(image.Bytes), 1)
+		} else {
+			pages++
+		}
+	}
+	return pages
+}
+
+// PDFExtraction makes PDF documents extractable by any provider. Providers
+// that read PDF get the document as it is; for the others every page is
+// rasterized with pdftoppm and the pages are sent in one request. Images are
+// passed through. It also counts the pages of the request.
+type PDFExtraction struct {
+	service  invoice.ExtractionService
+	native   bool
+	command  string
+	dpi      int
+	maxPages int
+	run      commandRunner
+}
+
+func NewPDFExtraction(service invoice.ExtractionService, native bool, cfg PDFConfig) *PDFExtraction {
+	command := cfg.Command
+	if command == "" {
+		command = defaultPDFCommand
+	}
+
+	dpi := cfg.DPI
+	if dpi <= 0 {
+		dpi = defaultPDFDPI
+	}
+
+	maxPages := cfg.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultPDFMaxPages
+	}
+
+	return &PDFExtraction{
+		service:  service,
+		native:   native,
+		command:  command,
+		dpi:      dpi,
+		maxPages: maxPages,
+		run:      runCommand,
+	}
+}
+
+func (s *PDFExtraction) Close() error {
+	return s.service.Close()
+}
+
+func (s *PDFExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	pages := make([]invoice.Image, 0, len(images))
+	for i, image := range images {
+		if !domainstorage.IsPDF(image.MIMEType) || s.native {
+			pages = append(pages, image)
+			continue
+		}
+
+		rasterized, err := s.rasterize(ctx, image.Bytes)
+		if err != nil {
+			if len(images) > 1 {
+				err = fmt.Errorf("image %d: %w", i+1, err)
+			}
+			return invoice.ExtractedData{}, err
+		}
+		for _, page := range rasterized {
+			pages = append(pages, invoice.Image{Bytes: page, MIMEType: pdfPageMIMEType})
+		}
+	}
+
+	pageCount := countPages(pages)
+	if pageCount > s.maxPages {
+		return invoice.ExtractedData{}, s.tooManyPages()
+	}
+
+	data, err := s.service.Extract(ctx, pages, opts)
+	if err == nil && data.PageCount == 0 {
+		data.PageCount = pageCount
+	}
+	return data, err
+}
+
+// rasterize renders the pages of a document as PNG images, in page order
+func (s *PDFExtraction) rasterize(ctx context.Context, documentBytes []byte) ([][]byte, error) {
+	if _, err := validateDocument(documentBytes, domainstorage.ContentTypePDF); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "invoice-pdf-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for PDF pages: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// render one page more than allowed to tell that there are too many
+	if _, err := s.run(ctx, documentBytes, s.command,
+		"-r", strconv.Itoa(s.dpi), "-png", "-l", strconv.Itoa(s.maxPages+1), "-", filepath.Join(dir, "page"),
+	); err != nil {
+		err = fmt.Errorf("failed to rasterize PDF: %w", err)
+		if ctx.Err() != nil {
+			return nil, invoice.NewRetryableError(err)
+		}
+		// a missing binary or a document pdftoppm cannot read will fail the
+		// same way next time
+		return nil, invoice.NewPermanentError(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF pages: %w", err)
+	}
+
+	// pages are named page-<n>.png with n padded to the width of the last page
+	// number, sort numerically all the same
+	type pageFile struct {
+		number int
+		name   string
+	}
+	var files []pageFile
+	for _, entry := range entries {
+		number, ok := strings.CutPrefix(strings.TrimSuffix(entry.Name(), ".png"), "page-")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		files = append(files, pageFile{number: n, name: entry.Name()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].number < files[j].number })
+
+	if len(files) == 0 {
+		return nil, invoice.NewPermanentError(errors.New("no pages in PDF document"))
+	}
+	if len(files) > s.maxPages {
+		return nil, s.tooManyPages()
+	}
+
+	pages := make([][]byte, len(files))
+	for i, file := range files {
+		pages[i], err = os.ReadFile(filepath.Join(dir, file.name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PDF page %d: %w", file.number, err)
+		}
+	}
+	return pages, nil
+}
+
+func (s *PDFExtraction) tooManyPages() error {
+	return invoice.NewPermanentError(fmt.Errorf("document has too many pages (max %d)", s.maxPages))
+}
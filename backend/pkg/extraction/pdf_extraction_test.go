@@ -0,0 +1,177 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPDF = "%PDF-1.4\n" +
+	"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+	"2 0 obj << /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >> endobj\n" +
+	"3 0 obj << /Type /Page /Parent 2 0 R >> endobj\n" +
+	"4 0 obj << /Type/Page /Parent 2 0 R >> endobj\n" +
+	"%%EOF\n"
+
+// imagesService records the images it is sent
+type imagesService struct {
+	data   invoice.ExtractedData
+	err    error
+	images []invoice.Image
+}
+
+func (s *imagesService) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	s.images = images
+	return s.data, s.err
+}
+
+func (s *imagesService) Close() error {
+	return nil
+}
+
+// fakePDFToPPM writes pages page-01.png, page-02.png, ... with the content
+// "page <n>" into the output root, the last argument
+func fakePDFToPPM(pages int) commandRunner {
+	return func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		root := args[len(args)-1]
+		for i := 1; i <= pages; i++ {
+			if err := os.WriteFile(fmt.Sprintf("%s-%02d.png", root, i), []byte(fmt.Sprintf("page %d", i)), 0644); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+}
+
+func TestCountPDFPages(t *testing.T) {
+	assert.Equal(t, 2, countPDFPages([]byte(testPDF)))
+	assert.Equal(t, 0, countPDFPages([]byte("%PDF-1.7\n<compressed object streams>")))
+}
+
+func TestPDFExtraction_Rasterized(t *testing.T) {
+	service := &imagesService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số hóa đơn", Value: "0001234"}},
+	}}
+
+	s := NewPDFExtraction(service, false, PDFConfig{})
+	s.run = fakePDFToPPM(2)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte(testPDF), "application/pdf"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []invoice.Image{
+		{Bytes: []byte("page 1"), MIMEType: "image/png"},
+		{Bytes: []byte("page 2"), MIMEType: "image/png"},
+	}, service.images)
+	assert.Equal(t, 2, data.PageCount)
+	assert.Equal(t, "0001234", data.KeyValuePairs[0].Value)
+}
+
+func TestPDFExtraction_Rasterized_WithImages(t *testing.T) {
+	service := &imagesService{}
+
+	s := NewPDFExtraction(service, false, PDFConfig{})
+	s.run = fakePDFToPPM(2)
+
+	data, err := s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte(testPDF), MIMEType: "application/pdf"},
+	}, invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("page 1"), MIMEType: "image/png"},
+		{Bytes: []byte("page 2"), MIMEType: "image/png"},
+	}, service.images)
+	assert.Equal(t, 3, data.PageCount)
+}
+
+func TestPDFExtraction_RasterizeFails(t *testing.T) {
+	s := NewPDFExtraction(&imagesService{}, false, PDFConfig{})
+	s.run = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return nil, errors.New("exec: \"pdftoppm\": executable file not found in $PATH")
+	}
+
+	_, err := s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte(testPDF), MIMEType: "application/pdf"},
+	}, invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+	assert.ErrorContains(t, err, "image 2: failed to rasterize PDF")
+}
+
+func TestPDFExtraction_TooManyPages(t *testing.T) {
+	var args []string
+	s := NewPDFExtraction(&imagesService{}, false, PDFConfig{MaxPages: 2, DPI: 150})
+	s.run = func(ctx context.Context, stdin []byte, name string, a ...string) ([]byte, error) {
+		args = a
+		return fakePDFToPPM(3)(ctx, stdin, name, a...)
+	}
+
+	_, err := s.Extract(context.Background(), oneImage([]byte(testPDF), "application/pdf"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+	assert.ErrorContains(t, err, "too many pages")
+	assert.Equal(t, []string{"-r", "150", "-png", "-l", "3", "-"}, args[:6])
+
+	native := NewPDFExtraction(&imagesService{}, true, PDFConfig{MaxPages: 1})
+	_, err = native.Extract(context.Background(), oneImage([]byte(testPDF), "application/pdf"), invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "too many pages")
+
+	// the pages of a document and the images around it count together
+	native = NewPDFExtraction(&imagesService{}, true, PDFConfig{MaxPages: 2})
+	_, err = native.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte(testPDF), MIMEType: "application/pdf"},
+		{Bytes: []byte("back"), MIMEType: "image/jpeg"},
+	}, invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "too many pages")
+}
+
+func TestPDFExtraction_Native(t *testing.T) {
+	service := &imagesService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "1"}},
+	}}
+
+	s := NewPDFExtraction(service, true, PDFConfig{})
+	s.run = func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		t.Fatal("native PDF providers should not rasterize")
+		return nil, nil
+	}
+
+	data, err := s.Extract(context.Background(), oneImage([]byte(testPDF), "application/pdf"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, oneImage([]byte(testPDF), "application/pdf"), service.images)
+	assert.Equal(t, 2, data.PageCount)
+	assert.Equal(t, "1", data.KeyValuePairs[0].Value)
+}
+
+func TestPDFExtraction_PassesImagesThrough(t *testing.T) {
+	service := &imagesService{}
+	images := []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("back"), MIMEType: "image/jpeg"},
+	}
+
+	data, err := NewPDFExtraction(service, false, PDFConfig{}).Extract(context.Background(), images, invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, images, service.images)
+	assert.Equal(t, 2, data.PageCount)
+}
+
+func TestValidateImage_RejectsPDF(t *testing.T) {
+	_, err := validateImage([]byte(testPDF), "application/pdf")
+	assert.Error(t, err)
+
+	mimeType, err := validateDocument([]byte(testPDF), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", mimeType)
+}
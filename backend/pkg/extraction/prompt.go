@@ -0,0 +1,179 @@
+package extraction
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+const defaultPromptVersion = "v1"
+
+// builtinPrompts holds the prompt versions shipped with the binary
+//
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// promptName restricts versions and tenant IDs to names that are safe to use
+// as file names
+var promptName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// PromptData are the variables available to prompt templates
+type PromptData struct {
+	Language     string
+	Currency     string
+	CustomFields []string
+	// BoundingBoxes is set for providers that locate values on the page
+	BoundingBoxes bool
+}
+
+// PromptStore renders versioned prompt templates. A version is the template
+// file <version>.tmpl; a tenant overrides it with <tenant>/<version>.tmpl.
+// Templates are looked up in the configured directory first and then in the
+// prompts shipped with the binary, and are parsed once: publish a change as a
+// new version so results stay comparable.
+type PromptStore struct {
+	sources        []fs.FS
+	defaultVersion string
+
+	mu        sync.Mutex
+	templates map[string]*template.Template
+}
+
+// NewPromptStore creates a store reading templates from dir, which may be
+// empty to only use the built-in prompts. defaultVersion is used when an
+// extraction does not ask for a version.
+func NewPromptStore(dir, defaultVersion string) (*PromptStore, error) {
+	if defaultVersion == "" {
+		defaultVersion = defaultPromptVersion
+	}
+
+	builtin, err := fs.Sub(builtinPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []fs.FS
+	if dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("prompts directory %s not found", dir)
+		}
+		sources = append(sources, os.DirFS(dir))
+	}
+	sources = append(sources, builtin)
+
+	s := &PromptStore{
+		sources:        sources,
+		defaultVersion: defaultVersion,
+		templates:      make(map[string]*template.Template),
+	}
+
+	if _, err := s.lookup("", defaultVersion); err != nil {
+		return nil, fmt.Errorf("default prompt version: %w", err)
+	}
+	return s, nil
+}
+
+var defaultPromptStore = sync.OnceValue(func() *PromptStore {
+	s, err := NewPromptStore("", "")
+	if err != nil {
+		panic(err)
+	}
+	return s
+})
+
+// promptStoreOrDefault returns the configured store, or one with only the
+// built-in prompts
+func promptStoreOrDefault(s *PromptStore) *PromptStore {
+	if s == nil {
+		return defaultPromptStore()
+	}
+	return s
+}
+
+// DefaultVersion is the version rendered when none is asked for
+func (s *PromptStore) DefaultVersion() string {
+	return s.defaultVersion
+}
+
+// Render renders the prompt for opts and returns it with the version used.
+// boundingBoxes asks for the regions values are read from, which only
+// providers that locate them reliably should do.
+func (s *PromptStore) Render(opts invoice.ExtractOptions, boundingBoxes bool) (string, string, error) {
+	version := opts.PromptVersion
+	if version == "" {
+		version = s.defaultVersion
+	}
+
+	tmpl, err := s.lookup(opts.TenantID, version)
+	if err != nil {
+		return "", "", invoice.NewPermanentError(err)
+	}
+
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, PromptData{
+		Language:      opts.Language,
+		Currency:      opts.Currency,
+		CustomFields:  opts.CustomFields,
+		BoundingBoxes: boundingBoxes,
+	})
+	if err != nil {
+		return "", "", invoice.NewPermanentError(fmt.Errorf("failed to render prompt %s: %w", version, err))
+	}
+
+	return strings.TrimSpace(b.String()), version, nil
+}
+
+// lookup returns the parsed template of version, preferring the tenant's own
+func (s *PromptStore) lookup(tenantID, version string) (*template.Template, error) {
+	if !promptName.MatchString(version) {
+		return nil, fmt.Errorf("invalid prompt version: %s", version)
+	}
+
+	names := []string{version + ".tmpl"}
+	if tenantID != "" {
+		if !promptName.MatchString(tenantID) {
+			return nil, fmt.Errorf("invalid tenant: %s", tenantID)
+		}
+		names = []string{tenantID + "/" + version + ".tmpl", version + ".tmpl"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		if tmpl, ok := s.templates[name]; ok {
+			return tmpl, nil
+		}
+
+		for _, source := range s.sources {
+			text, err := fs.ReadFile(source, name)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read prompt %s: %w", name, err)
+			}
+
+			tmpl, err := template.New(name).
+				Funcs(template.FuncMap{"join": strings.Join}).
+				Option("missingkey=error").
+				Parse(string(text))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse prompt %s: %w", name, err)
+			}
+			s.templates[name] = tmpl
+			return tmpl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid prompt version: %s", version)
+}
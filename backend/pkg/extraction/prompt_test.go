@@ -0,0 +1,121 @@
+package extraction
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrompt(t *testing.T, dir, name, text string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(text), 0644))
+}
+
+func TestPromptStore_Render_Builtin(t *testing.T) {
+	s, err := NewPromptStore("", "")
+	require.NoError(t, err)
+
+	prompt, version, err := s.Render(invoice.ExtractOptions{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", version)
+	assert.Contains(t, prompt, `set "table" to {"headers": [], "rows": []}`)
+	assert.NotContains(t, prompt, "expected to be in")
+	assert.True(t, prompt[len(prompt)-1] != '\n', "prompt is trimmed")
+
+	prompt, _, err = s.Render(invoice.ExtractOptions{
+		Language:     "Vietnamese",
+		Currency:     "VND",
+		CustomFields: []string{"Mã số thuế", "Số tài khoản"},
+	}, false)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "- The invoice is expected to be in Vietnamese\n")
+	assert.Contains(t, prompt, "- Amounts are expected to be in VND")
+	assert.Contains(t, prompt, "using the names as keys: Mã số thuế, Số tài khoản")
+}
+
+func TestPromptStore_Render_BoundingBoxes(t *testing.T) {
+	s, err := NewPromptStore("", "")
+	require.NoError(t, err)
+
+	prompt, _, err := s.Render(invoice.ExtractOptions{PromptVersion: "v2"}, true)
+	require.NoError(t, err)
+	assert.Contains(t, prompt, `Add a "bounding_box" to every key/value pair`)
+	assert.Contains(t, prompt, `Add "cell_boxes" to "table"`)
+
+	// v1 results stay comparable, and providers that do not locate values are
+	// not asked to
+	for _, render := range []struct {
+		version       string
+		boundingBoxes bool
+	}{{"v1", true}, {"v2", false}} {
+		prompt, _, err := s.Render(invoice.ExtractOptions{PromptVersion: render.version}, render.boundingBoxes)
+		require.NoError(t, err)
+		assert.NotContains(t, prompt, "bounding_box")
+	}
+}
+
+func TestPromptStore_Render_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writePrompt(t, dir, "v2.tmpl", "shared v2 {{.Currency}}")
+	writePrompt(t, dir, "acme/v2.tmpl", "acme v2 {{.Currency}}")
+
+	s, err := NewPromptStore(dir, "v2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		opts     invoice.ExtractOptions
+		expected string
+		version  string
+	}{
+		{name: "default version", opts: invoice.ExtractOptions{Currency: "USD"}, expected: "shared v2 USD", version: "v2"},
+		{name: "tenant template", opts: invoice.ExtractOptions{TenantID: "acme", Currency: "VND"}, expected: "acme v2 VND", version: "v2"},
+		{name: "tenant without template", opts: invoice.ExtractOptions{TenantID: "globex"}, expected: "shared v2", version: "v2"},
+		{name: "builtin version", opts: invoice.ExtractOptions{TenantID: "acme", PromptVersion: "v1"}, version: "v1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prompt, version, err := s.Render(test.opts, false)
+			require.NoError(t, err)
+			assert.Equal(t, test.version, version)
+			if test.expected != "" {
+				assert.Equal(t, test.expected, prompt)
+			}
+		})
+	}
+}
+
+func TestPromptStore_Render_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	writePrompt(t, dir, "broken.tmpl", "{{.Missing}}")
+
+	s, err := NewPromptStore(dir, "")
+	require.NoError(t, err)
+
+	for _, opts := range []invoice.ExtractOptions{
+		{PromptVersion: "v999"},
+		{PromptVersion: "../v1"},
+		{PromptVersion: "v1", TenantID: "../acme"},
+		{PromptVersion: "broken"},
+	} {
+		_, _, err := s.Render(opts, false)
+		assert.Error(t, err, "%+v", opts)
+		assert.False(t, invoice.IsRetryable(err))
+	}
+}
+
+func TestNewPromptStore_Invalid(t *testing.T) {
+	_, err := NewPromptStore(filepath.Join(t.TempDir(), "missing"), "")
+	assert.Error(t, err)
+
+	_, err = NewPromptStore("", "v999")
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package extraction
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+const (
+	ProviderGemini    = "gemini"
+	ProviderOpenAI    = "openai"
+	ProviderTesseract = "tesseract"
+	ProviderComposite = "composite"
+	ProviderReplay    = "replay"
+
+	defaultTimeout = 90 * time.Second
+)
+
+// ProviderConfig configures a single extraction provider. Fields a provider
+// does not use are ignored.
+type ProviderConfig struct {
+	// Name is the registered provider name, used by composite providers
+	Name    string
+	Model   string
+	APIKey  string
+	BaseURL string
+	Timeout time.Duration
+	// MaxTokens caps the length of the model output, 0 uses the provider default
+	MaxTokens int
+	// JSONMode asks the server to constrain its output to JSON, for servers
+	// that support response_format
+	JSONMode bool
+	// Command is the path of a local binary, e.g. tesseract
+	Command string
+	// Language selects OCR language packs, e.g. vie+eng
+	Language string
+	// Prompts renders the prompt of model based providers, nil uses the
+	// built-in prompts
+	Prompts *PromptStore
+	// FixturesDir is the directory the replay provider serves results from
+	FixturesDir string
+	// DefaultFixture is a fixture file the replay provider serves for images
+	// without a fixture of their own, none when empty
+	DefaultFixture string
+	// Mode and Providers configure the composite provider: ModeFallback or
+	// ModeEnsemble over the listed providers
+	Mode      string
+	Providers []ProviderConfig
+	// Limits rate limits and caps the concurrent calls of the provider
+	Limits LimitConfig
+	// PDF configures how PDF documents are sent to the provider
+	PDF PDFConfig
+}
+
+// Factory creates an extraction service from its provider config
+type Factory func(cfg ProviderConfig) (invoice.ExtractionService, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available to New under name. Providers register
+// themselves from init.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("extraction provider %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// New creates the extraction service of the provider registered under name,
+// limited by cfg.Limits when any limit is set. PDF documents are rasterized
+// page by page for providers that cannot read them.
+func New(name string, cfg ProviderConfig) (invoice.ExtractionService, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown extraction provider %q (available: %v)", name, Providers())
+	}
+
+	service, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, ok := service.(pdfReader)
+	native := ok && reader.readsPDF()
+
+	if cfg.Limits.enabled() {
+		service = NewLimitedExtraction(name, service, cfg.Limits)
+	}
+	return NewPDFExtraction(service, native, cfg.PDF), nil
+}
+
+// Providers returns the names of all registered providers
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
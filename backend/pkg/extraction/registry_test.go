@@ -0,0 +1,26 @@
+package extraction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	s, err := New(ProviderOpenAI, ProviderConfig{Model: "qwen2.5-vl"})
+	require.NoError(t, err)
+	require.IsType(t, &PDFExtraction{}, s)
+	assert.IsType(t, &OpenAIExtraction{}, s.(*PDFExtraction).service)
+	assert.False(t, s.(*PDFExtraction).native)
+
+	_, err = New(ProviderGemini, ProviderConfig{})
+	assert.Error(t, err)
+
+	_, err = New("unknown", ProviderConfig{})
+	assert.ErrorContains(t, err, "unknown extraction provider")
+}
+
+func TestProviders(t *testing.T) {
+	assert.Subset(t, Providers(), []string{ProviderGemini, ProviderOpenAI})
+}
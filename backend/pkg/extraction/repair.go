@@ -0,0 +1,151 @@
+package extraction
+
+import (
+	"bytes"
+	"strings"
+)
+
+type jsonFrame struct {
+	// closer is '}' for objects and ']' for arrays
+	closer byte
+	// expectKey is set in objects between '{' or ',' and the next key
+	expectKey bool
+}
+
+// repairJSON fixes the mistakes models make in otherwise valid JSON output:
+// text around the object, trailing commas, and output cut off before the end.
+// Truncated output is cut back to the last complete value so that no
+// half-written value or array element object is kept, and the open brackets
+// are closed. truncated reports whether that happened.
+func repairJSON(text string) (repaired string, truncated bool) {
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return text, false
+	}
+
+	var (
+		out   []byte
+		stack []jsonFrame
+		// safeLen and safeDepth describe the last point where the output can
+		// be closed without keeping a partial value
+		safeLen, safeDepth int
+		// inString, escaped and isKey describe the string being scanned
+		inString, escaped, isKey bool
+		// inLiteral is set while scanning a number, true, false or null
+		inLiteral bool
+	)
+
+	// markSafe records the current point unless it is inside an object that
+	// is an array element, which would be kept half-written
+	markSafe := func() {
+		for i := 1; i < len(stack); i++ {
+			if stack[i].closer == '}' && stack[i-1].closer == ']' {
+				return
+			}
+		}
+		safeLen, safeDepth = len(out), len(stack)
+	}
+
+	// valueDone records a complete value in the innermost container
+	valueDone := func() {
+		if len(stack) > 0 {
+			markSafe()
+		}
+	}
+
+	endLiteral := func() {
+		if inLiteral {
+			inLiteral = false
+			valueDone()
+		}
+	}
+
+scan:
+	for i := start; i < len(text); i++ {
+		c := text[i]
+
+		if inString {
+			out = append(out, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if !isKey {
+					valueDone()
+				}
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			endLiteral()
+			inString = true
+			isKey = len(stack) > 0 && stack[len(stack)-1].expectKey
+			if isKey {
+				stack[len(stack)-1].expectKey = false
+			}
+			out = append(out, c)
+		case '{', '[':
+			endLiteral()
+			closer := byte(']')
+			if c == '{' {
+				closer = '}'
+			}
+			stack = append(stack, jsonFrame{closer: closer, expectKey: c == '{'})
+			out = append(out, c)
+			markSafe()
+		case '}', ']':
+			endLiteral()
+			if len(stack) == 0 || stack[len(stack)-1].closer != c {
+				// mismatched bracket: give up and let the decoder report it
+				return text, false
+			}
+			out = trimTrailingComma(out)
+			out = append(out, c)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				break scan
+			}
+			valueDone()
+		case ',':
+			endLiteral()
+			if len(stack) > 0 && stack[len(stack)-1].closer == '}' {
+				stack[len(stack)-1].expectKey = true
+			}
+			out = append(out, c)
+		case ' ', '\t', '\n', '\r', ':':
+			endLiteral()
+			out = append(out, c)
+		default:
+			inLiteral = true
+			out = append(out, c)
+		}
+	}
+
+	if len(stack) == 0 {
+		return string(out), false
+	}
+
+	if safeDepth == 0 {
+		return text, false
+	}
+
+	out = trimTrailingComma(out[:safeLen])
+	for i := safeDepth - 1; i >= 0; i-- {
+		out = append(out, stack[i].closer)
+	}
+	return string(out), true
+}
+
+// trimTrailingComma drops a comma, and the whitespace around it, at the end of out
+func trimTrailingComma(out []byte) []byte {
+	trimmed := bytes.TrimRight(out, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+		return bytes.TrimRight(trimmed[:len(trimmed)-1], " \t\r\n")
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package extraction
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  string
+		truncated bool
+	}{
+		{
+			name:     "valid",
+			input:    `{"summary": [{"key": "Total", "value": "50000"}]}`,
+			expected: `{"summary": [{"key": "Total", "value": "50000"}]}`,
+		},
+		{
+			name:     "trailing commas",
+			input:    `{"summary": [{"key": "Total", "value": "50,000",},], "table": {"headers": [], "rows": [],},}`,
+			expected: `{"summary": [{"key": "Total", "value": "50,000"}], "table": {"headers": [], "rows": []}}`,
+		},
+		{
+			name:     "text around the object",
+			input:    "Here is the data:\n{\"summary\": []}\nLet me know if you need more.",
+			expected: `{"summary": []}`,
+		},
+		{
+			name:      "truncated inside an object",
+			input:     `{"key_value_pairs": [{"key": "Số", "value": "0001234"}, {"key": "Ngày", "val`,
+			expected:  `{"key_value_pairs": [{"key": "Số", "value": "0001234"}]}`,
+			truncated: true,
+		},
+		{
+			name:      "truncated inside a row",
+			input:     `{"table": {"headers": ["Item", "Total"], "rows": [["Coffee", "50000"], ["Tea", "150`,
+			expected:  `{"table": {"headers": ["Item", "Total"], "rows": [["Coffee", "50000"], ["Tea"]]}}`,
+			truncated: true,
+		},
+		{
+			name:      "truncated number",
+			input:     `{"table": {"headers": [], "rows": []}, "confidence": 0.9`,
+			expected:  `{"table": {"headers": [], "rows": []}}`,
+			truncated: true,
+		},
+		{
+			name:      "truncated after a comma",
+			input:     `{"summary": [{"key": "Total", "value": "50000"}, `,
+			expected:  `{"summary": [{"key": "Total", "value": "50000"}]}`,
+			truncated: true,
+		},
+		{
+			name:      "escaped quotes",
+			input:     `{"summary": [{"key": "Note", "value": "say \"hi\", }"}], "key_value_pairs": [`,
+			expected:  `{"summary": [{"key": "Note", "value": "say \"hi\", }"}], "key_value_pairs": []}`,
+			truncated: true,
+		},
+		{
+			name:     "not json",
+			input:    "I cannot read this invoice",
+			expected: "I cannot read this invoice",
+		},
+		{
+			name:     "mismatched brackets",
+			input:    `{"summary": [}`,
+			expected: `{"summary": [}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repaired, truncated := repairJSON(test.input)
+			assert.Equal(t, test.expected, repaired)
+			assert.Equal(t, test.truncated, truncated)
+			if test.expected != test.input {
+				assert.True(t, json.Valid([]byte(repaired)), "repaired output is valid JSON")
+			}
+		})
+	}
+}
+
+func TestParseModelResponse_Repaired(t *testing.T) {
+	data, err := parseModelResponse(`{"key_value_pairs": [{"key": "Số", "value": "0001234", "confidence": 0.9},], "table": {"headers": [], "rows": []}, "summary": [{"key": "Tổng cộng", "value": "65.000"}, {"key": "Thu`)
+	require.NoError(t, err)
+	assert.Len(t, data.KeyValuePairs, 1)
+	require.Len(t, data.Summary, 1)
+	assert.Equal(t, "65.000", data.Summary[0].Value)
+
+	_, err = parseModelResponse(`{"key_value_pairs": [{"key": "Số", "value": "00`)
+	require.Error(t, err)
+	assert.True(t, isTruncatedJSON(err), "nothing left after repair keeps the truncation error")
+}
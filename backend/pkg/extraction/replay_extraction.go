@@ -0,0 +1,171 @@
+package extraction
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	"invoice-scan/backend/pkg/log"
+)
+
+func init() {
+	Register(ProviderReplay, func(cfg ProviderConfig) (invoice.ExtractionService, error) {
+		return NewReplayExtraction(cfg)
+	})
+}
+
+// imageHash returns the hex SHA-256 of an image
+func imageHash(imageBytes []byte) string {
+	sum := sha256.Sum256(imageBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// imagesHash identifies the images of a request: the hash of a single image,
+// or the hash of the image hashes in page order
+func imagesHash(images []invoice.Image) string {
+	if len(images) == 1 {
+		return imageHash(images[0].Bytes)
+	}
+
+	hashes := make([]string, len(images))
+	for i, image := range images {
+		hashes[i] = imageHash(image.Bytes)
+	}
+	return imageHash([]byte(strings.Join(hashes, "\n")))
+}
+
+// fixturePath returns the fixture file of the images of a request, named after
+// their hash
+func fixturePath(dir string, images []invoice.Image) string {
+	return filepath.Join(dir, imagesHash(images)+".json")
+}
+
+// ReplayExtraction serves extracted data recorded in a fixtures directory, so
+// the extraction flow runs without a model. Each fixture is the JSON of an
+// invoice.ExtractedData in <sha256 of the image>.json, or for several images
+// the sha256 of their hashes one per line. Other images fail, unless a
+// default fixture is configured to serve them.
+type ReplayExtraction struct {
+	dir            string
+	defaultFixture string
+}
+
+func NewReplayExtraction(cfg ProviderConfig) (*ReplayExtraction, error) {
+	if cfg.FixturesDir == "" {
+		return nil, fmt.Errorf("replay fixtures directory is required")
+	}
+	if info, err := os.Stat(cfg.FixturesDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("replay fixtures directory %s not found", cfg.FixturesDir)
+	}
+	if cfg.DefaultFixture != "" {
+		if info, err := os.Stat(cfg.DefaultFixture); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("replay default fixture %s not found", cfg.DefaultFixture)
+		}
+	}
+
+	return &ReplayExtraction{dir: cfg.FixturesDir, defaultFixture: cfg.DefaultFixture}, nil
+}
+
+// readsPDF: replay fixtures are looked up by the hash of the document
+func (s *ReplayExtraction) readsPDF() bool {
+	return true
+}
+
+func (s *ReplayExtraction) Close() error {
+	return nil
+}
+
+func (s *ReplayExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	if _, err := validateImages(images, validateDocument); err != nil {
+		return invoice.ExtractedData{}, err
+	}
+
+	path := fixturePath(s.dir, images)
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if s.defaultFixture == "" {
+			return invoice.ExtractedData{}, invoice.NewPermanentError(fmt.Errorf("no replay fixture %s", filepath.Base(path)))
+		}
+		path = s.defaultFixture
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return invoice.ExtractedData{}, invoice.NewPermanentError(fmt.Errorf("failed to read replay fixture: %w", err))
+	}
+
+	var data invoice.ExtractedData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return invoice.ExtractedData{}, invoice.NewPermanentError(fmt.Errorf("invalid replay fixture %s: %w", filepath.Base(path), err))
+	}
+
+	return data, nil
+}
+
+// RecordingExtraction wraps a provider and writes every successful result to
+// a fixtures directory that ReplayExtraction can serve later. Failing to write
+// a fixture is logged and does not fail the extraction.
+type RecordingExtraction struct {
+	service invoice.ExtractionService
+	dir     string
+}
+
+func NewRecordingExtraction(service invoice.ExtractionService, dir string) (*RecordingExtraction, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixtures directory: %w", err)
+	}
+
+	return &RecordingExtraction{
+		service: service,
+		dir:     dir,
+	}, nil
+}
+
+func (s *RecordingExtraction) Close() error {
+	return s.service.Close()
+}
+
+func (s *RecordingExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	data, err := s.service.Extract(ctx, images, opts)
+	if err != nil {
+		return data, err
+	}
+
+	if err := s.record(images, data); err != nil {
+		log.Warnf("failed to record extraction fixture: %v", err)
+	}
+	return data, nil
+}
+
+func (s *RecordingExtraction) record(images []invoice.Image, data invoice.ExtractedData) error {
+	// a replay makes no provider call
+	data.Usage = nil
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a replay never reads half a fixture
+	path := fixturePath(s.dir, images)
+	tmp, err := os.CreateTemp(s.dir, ".fixture-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,145 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayExtraction_Extract(t *testing.T) {
+	dir := t.TempDir()
+	image := []byte("invoice image")
+	require.NoError(t, os.WriteFile(fixturePath(dir, oneImage(image, "image/jpeg")), []byte(testInvoiceJSON), 0644))
+
+	s, err := New(ProviderReplay, ProviderConfig{FixturesDir: dir})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "INV-001", data.KeyValuePairs[0].Value)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("other image"), "image/jpeg"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+	assert.ErrorContains(t, err, "no replay fixture")
+}
+
+func TestReplayExtraction_Extract_Pages(t *testing.T) {
+	dir := t.TempDir()
+	pages := []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("back"), MIMEType: "image/jpeg"},
+	}
+	require.NoError(t, os.WriteFile(fixturePath(dir, pages), []byte(testInvoiceJSON), 0644))
+
+	s, err := NewReplayExtraction(ProviderConfig{FixturesDir: dir})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), pages, invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "INV-001", data.KeyValuePairs[0].Value)
+
+	// the order of the pages is part of the fixture name
+	_, err = s.Extract(context.Background(), []invoice.Image{pages[1], pages[0]}, invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "no replay fixture")
+
+	assert.Equal(t, imageHash(pages[0].Bytes), imagesHash(pages[:1]))
+}
+
+func TestReplayExtraction_Extract_Default(t *testing.T) {
+	dir := t.TempDir()
+	defaultFixture := filepath.Join(dir, "default.json")
+	require.NoError(t, os.WriteFile(defaultFixture, []byte(`{"summary": [{"key": "Total", "value": "1"}]}`), 0644))
+
+	// a default fixture is only served when configured
+	s, err := NewReplayExtraction(ProviderConfig{FixturesDir: dir})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("any image"), "image/png"), invoice.ExtractOptions{})
+	assert.ErrorContains(t, err, "no replay fixture")
+
+	s, err = NewReplayExtraction(ProviderConfig{FixturesDir: dir, DefaultFixture: defaultFixture})
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("any image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "1", data.Summary[0].Value)
+}
+
+func TestReplayExtraction_Extract_InvalidFixture(t *testing.T) {
+	dir := t.TempDir()
+	image := []byte("invoice image")
+	require.NoError(t, os.WriteFile(fixturePath(dir, oneImage(image, "image/jpeg")), []byte("not json"), 0644))
+
+	s, err := NewReplayExtraction(ProviderConfig{FixturesDir: dir})
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage(image, "image/png"), invoice.ExtractOptions{})
+	require.Error(t, err)
+	assert.False(t, invoice.IsRetryable(err))
+}
+
+func TestNewReplayExtraction_Invalid(t *testing.T) {
+	_, err := NewReplayExtraction(ProviderConfig{})
+	assert.Error(t, err)
+
+	_, err = NewReplayExtraction(ProviderConfig{FixturesDir: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	_, err = NewReplayExtraction(ProviderConfig{FixturesDir: dir, DefaultFixture: filepath.Join(dir, "default.json")})
+	assert.Error(t, err)
+}
+
+func TestRecordingExtraction_RecordsForReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	image := []byte("invoice image")
+	recorded := invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{{Key: "Số", Value: "0001234", Confidence: conf(0.9)}},
+		Table:         invoice.TableData{Headers: []string{}, Rows: [][]string{}},
+		Summary:       []invoice.KeyValuePair{},
+		PromptVersion: "v1",
+	}
+	inner := &stubService{data: recorded}
+
+	s, err := NewRecordingExtraction(inner, dir)
+	require.NoError(t, err)
+
+	data, err := s.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, recorded, data)
+
+	replay, err := NewReplayExtraction(ProviderConfig{FixturesDir: dir})
+	require.NoError(t, err)
+
+	replayed, err := replay.Extract(context.Background(), oneImage(image, "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+
+	require.NoError(t, s.Close())
+	assert.True(t, inner.closed)
+}
+
+func TestRecordingExtraction_DoesNotRecordErrors(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRecordingExtraction(&stubService{err: errors.New("gemini API error: 503")}, dir)
+	require.NoError(t, err)
+
+	_, err = s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	assert.Error(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
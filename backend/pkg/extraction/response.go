@@ -0,0 +1,210 @@
+package extraction
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"invoice-scan/backend/internal/domain/invoice"
+	domainstorage "invoice-scan/backend/internal/domain/storage"
+	"invoice-scan/backend/pkg/log"
+)
+
+const maxImageSize = 10 * 1024 * 1024
+
+// pagesNote follows the prompt when a request has several images
+const pagesNote = "The images are the pages of one invoice, in order. Extract them as a single invoice and continue the table across pages."
+
+// validateImages checks the images of a request with validate and returns them
+// with the MIME types to send them with
+func validateImages(images []invoice.Image, validate func([]byte, string) (string, error)) ([]invoice.Image, error) {
+	if len(images) == 0 {
+		return nil, invoice.NewPermanentError(fmt.Errorf("no image to extract"))
+	}
+
+	validated := make([]invoice.Image, len(images))
+	for i, image := range images {
+		mimeType, err := validate(image.Bytes, image.MIMEType)
+		if err != nil {
+			if len(images) > 1 {
+				err = fmt.Errorf("image %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+		validated[i] = invoice.Image{Bytes: image.Bytes, MIMEType: mimeType}
+	}
+	return validated, nil
+}
+
+// validateImage checks the image before it is sent to a provider and returns
+// the MIME type to send it with
+func validateImage(imageBytes []byte, mimeType string) (string, error) {
+	if len(imageBytes) > maxImageSize {
+		return "", invoice.NewPermanentError(fmt.Errorf("image too large (max 10MB)"))
+	}
+
+	if len(imageBytes) == 0 {
+		return "", invoice.NewPermanentError(fmt.Errorf("empty image data"))
+	}
+
+	if domainstorage.IsPDF(mimeType) {
+		return "", invoice.NewPermanentError(fmt.Errorf("invalid image type: %s, PDF documents must be rasterized first", mimeType))
+	}
+
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", invoice.NewPermanentError(fmt.Errorf("invalid image type: %s", mimeType))
+	}
+
+	return mimeType, nil
+}
+
+// validateDocument is validateImage for providers that also read PDF
+// documents
+func validateDocument(documentBytes []byte, mimeType string) (string, error) {
+	if !domainstorage.IsPDF(mimeType) {
+		return validateImage(documentBytes, mimeType)
+	}
+
+	if len(documentBytes) > maxImageSize {
+		return "", invoice.NewPermanentError(fmt.Errorf("document too large (max 10MB)"))
+	}
+	if len(documentBytes) == 0 {
+		return "", invoice.NewPermanentError(fmt.Errorf("empty document data"))
+	}
+	return domainstorage.ContentTypePDF, nil
+}
+
+// isRetryableStatus reports whether an HTTP status returned by a provider API
+// is worth retrying: timeouts, rate limits and server errors
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+// classifyTransportError marks timeouts as retryable. Other transport errors
+// (connection reset, DNS, ...) are left unclassified.
+func classifyTransportError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return invoice.NewRetryableError(err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return invoice.NewRetryableError(err)
+	}
+
+	return err
+}
+
+// isTruncatedJSON reports whether err comes from decoding JSON that ended early,
+// which happens when the model stops before finishing its output
+func isTruncatedJSON(err error) bool {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return strings.Contains(syntaxErr.Error(), "unexpected end of JSON input")
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parseModelResponse decodes the model output. Output that does not decode as
+// is goes through repairJSON first; truncated output is only accepted when the
+// repaired result still holds data.
+func parseModelResponse(text string) (invoice.ExtractedData, error) {
+	text = strings.TrimSpace(text)
+
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	var extractedData invoice.ExtractedData
+	err := json.Unmarshal([]byte(text), &extractedData)
+	if err == nil {
+		return extractedData, nil
+	}
+	err = fmt.Errorf("failed to unmarshal JSON: %w", err)
+
+	repaired, truncated := repairJSON(text)
+	var repairedData invoice.ExtractedData
+	if repaired == text || json.Unmarshal([]byte(repaired), &repairedData) != nil {
+		return invoice.ExtractedData{}, err
+	}
+	if truncated && isEmpty(repairedData) {
+		return invoice.ExtractedData{}, err
+	}
+
+	log.Warnf("repaired invalid JSON in model response (truncated: %t): %v", truncated, err)
+	return repairedData, nil
+}
+
+// boxTolerance lets boxes overflow the page by rounding errors
+const boxTolerance = 0.01
+
+// cleanBoundingBoxes drops the boxes a model placed outside the pages of the
+// request, e.g. in pixels rather than fractions, and aligns the cell boxes
+// with the table rows
+func cleanBoundingBoxes(data *invoice.ExtractedData, pages int) {
+	for _, pairs := range [][]invoice.KeyValuePair{data.KeyValuePairs, data.Summary} {
+		for i := range pairs {
+			pairs[i].BoundingBox = cleanBoundingBox(pairs[i].BoundingBox, pages)
+		}
+	}
+
+	if len(data.Table.CellBoxes) == 0 {
+		return
+	}
+
+	located := false
+	cellBoxes := make([][]*invoice.BoundingBox, len(data.Table.Rows))
+	for i, row := range data.Table.Rows {
+		cellBoxes[i] = make([]*invoice.BoundingBox, len(row))
+		if i >= len(data.Table.CellBoxes) {
+			continue
+		}
+		for j := range row {
+			if j < len(data.Table.CellBoxes[i]) {
+				cellBoxes[i][j] = cleanBoundingBox(data.Table.CellBoxes[i][j], pages)
+				located = located || cellBoxes[i][j] != nil
+			}
+		}
+	}
+
+	data.Table.CellBoxes = nil
+	if located {
+		data.Table.CellBoxes = cellBoxes
+	}
+}
+
+func cleanBoundingBox(box *invoice.BoundingBox, pages int) *invoice.BoundingBox {
+	if box == nil {
+		return nil
+	}
+
+	cleaned := *box
+	if cleaned.Page == 0 {
+		cleaned.Page = 1
+	}
+	if cleaned.Page < 0 || cleaned.Page > pages {
+		return nil
+	}
+
+	if cleaned.X < 0 || cleaned.Y < 0 || cleaned.X >= 1 || cleaned.Y >= 1 || cleaned.Width <= 0 || cleaned.Height <= 0 ||
+		cleaned.X+cleaned.Width > 1+boxTolerance || cleaned.Y+cleaned.Height > 1+boxTolerance {
+		return nil
+	}
+	cleaned.Width = min(cleaned.Width, 1-cleaned.X)
+	cleaned.Height = min(cleaned.Height, 1-cleaned.Y)
+	return &cleaned
+}
+
+func isEmpty(data invoice.ExtractedData) bool {
+	return len(data.KeyValuePairs) == 0 && len(data.Table.Rows) == 0 && len(data.Summary) == 0
+}
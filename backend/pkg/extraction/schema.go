@@ -0,0 +1,100 @@
+package extraction
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"google.golang.org/genai"
+)
+
+// extractionSchema constrains structured model output to invoice.ExtractedData
+var extractionSchema = mustSchemaFor(reflect.TypeOf(invoice.ExtractedData{}))
+
+func mustSchemaFor(t reflect.Type) *genai.Schema {
+	schema, err := schemaFor(t)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// schemaFor derives a response schema from a Go type. Struct fields are named
+// after their json tag and are required unless tagged omitempty; fields tagged
+// json:"-" or schema:"-" are left out. Pointers become nullable.
+func schemaFor(t reflect.Type) (*genai.Schema, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema.Nullable = genai.Ptr(true)
+		return schema, nil
+	case reflect.String:
+		return &genai.Schema{Type: genai.TypeString}, nil
+	case reflect.Bool:
+		return &genai.Schema{Type: genai.TypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &genai.Schema{Type: genai.TypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return &genai.Schema{Type: genai.TypeNumber}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &genai.Schema{Type: genai.TypeArray, Items: items}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return nil, fmt.Errorf("unsupported schema type %s", t)
+	}
+}
+
+func structSchema(t reflect.Type) (*genai.Schema, error) {
+	schema := &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: make(map[string]*genai.Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("schema") == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := schemaFor(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+
+		schema.Properties[name] = property
+		schema.PropertyOrdering = append(schema.PropertyOrdering, name)
+		if !hasOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema, nil
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
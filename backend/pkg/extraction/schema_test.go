@@ -0,0 +1,47 @@
+package extraction
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestExtractionSchema(t *testing.T) {
+	schema := extractionSchema
+	require.Equal(t, genai.TypeObject, schema.Type)
+	assert.Equal(t, []string{"key_value_pairs", "table", "summary", "confidence"}, schema.PropertyOrdering)
+	assert.Equal(t, []string{"key_value_pairs", "table", "summary"}, schema.Required)
+	assert.NotContains(t, schema.Properties, "provider")
+
+	table := schema.Properties["table"]
+	assert.Equal(t, genai.TypeObject, table.Type)
+	assert.Nil(t, table.Nullable, "table is an object, never null")
+	assert.Equal(t, genai.TypeArray, table.Properties["rows"].Type)
+	assert.Equal(t, genai.TypeArray, table.Properties["rows"].Items.Type)
+	assert.Equal(t, genai.TypeString, table.Properties["rows"].Items.Items.Type)
+
+	pair := schema.Properties["key_value_pairs"].Items
+	assert.Equal(t, []string{"key", "value", "confidence", "bounding_box"}, pair.PropertyOrdering)
+	assert.Equal(t, []string{"key", "value"}, pair.Required)
+	assert.Equal(t, genai.TypeNumber, pair.Properties["confidence"].Type)
+	assert.Equal(t, genai.Ptr(true), pair.Properties["confidence"].Nullable)
+
+	box := pair.Properties["bounding_box"]
+	assert.Equal(t, genai.TypeObject, box.Type)
+	assert.Equal(t, genai.Ptr(true), box.Nullable)
+	assert.Equal(t, []string{"x", "y", "width", "height"}, box.Required)
+
+	cellBoxes := table.Properties["cell_boxes"]
+	assert.NotContains(t, table.Required, "cell_boxes")
+	assert.Equal(t, genai.TypeObject, cellBoxes.Items.Items.Type)
+}
+
+func TestSchemaFor_Unsupported(t *testing.T) {
+	_, err := schemaFor(reflect.TypeOf(struct {
+		Values map[string]string `json:"values"`
+	}{}))
+	assert.Error(t, err)
+}
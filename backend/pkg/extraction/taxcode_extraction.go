@@ -0,0 +1,32 @@
+package extraction
+
+import (
+	"context"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+// TaxCodeExtraction post-processes extracted data with invoice.CheckTaxCodes:
+// the seller and buyer tax codes are filled from the pairs and pairs whose tax
+// code fails validation get a lowered confidence. It wraps the cache, so
+// cached results are checked too.
+type TaxCodeExtraction struct {
+	service invoice.ExtractionService
+}
+
+func NewTaxCodeExtraction(service invoice.ExtractionService) *TaxCodeExtraction {
+	return &TaxCodeExtraction{service: service}
+}
+
+func (s *TaxCodeExtraction) Close() error {
+	return s.service.Close()
+}
+
+func (s *TaxCodeExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	data, err := s.service.Extract(ctx, images, opts)
+	if err != nil {
+		return data, err
+	}
+	invoice.CheckTaxCodes(&data)
+	return data, nil
+}
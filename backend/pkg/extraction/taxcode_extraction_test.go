@@ -0,0 +1,40 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxCodeExtraction(t *testing.T) {
+	stub := &stubService{data: invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{
+			{Key: "Mã số thuế", Value: "0100109106", Confidence: conf(0.9)},
+			{Key: "Mã số thuế người mua", Value: "0312345678", Confidence: conf(0.9)},
+		},
+	}}
+	service := NewTaxCodeExtraction(stub)
+
+	data, err := service.Extract(context.Background(), oneImage([]byte("img"), "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "0100109106", data.SellerTaxCode)
+	assert.Empty(t, data.BuyerTaxCode)
+	assert.Equal(t, 0.9, *data.KeyValuePairs[0].Confidence)
+	assert.Equal(t, invoice.InvalidTaxCodeConfidence, *data.KeyValuePairs[1].Confidence)
+
+	require.NoError(t, service.Close())
+	assert.True(t, stub.closed)
+}
+
+func TestTaxCodeExtraction_Error(t *testing.T) {
+	stub := &stubService{err: errors.New("provider down")}
+	service := NewTaxCodeExtraction(stub)
+
+	_, err := service.Extract(context.Background(), oneImage([]byte("img"), "image/jpeg"), invoice.ExtractOptions{})
+	assert.EqualError(t, err, "provider down")
+}
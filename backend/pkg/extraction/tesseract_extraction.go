@@ -0,0 +1,460 @@
+package extraction
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+const (
+	defaultTesseractCommand  = "tesseract"
+	defaultTesseractLanguage = "vie+eng"
+	// page segmentation mode 4: a single column of text of variable sizes,
+	// which keeps table rows on one line
+	tesseractPageSegMode = "4"
+)
+
+func init() {
+	Register(ProviderTesseract, func(cfg ProviderConfig) (invoice.ExtractionService, error) {
+		return NewTesseractExtraction(cfg)
+	})
+}
+
+// commandRunner runs name with args, feeding stdin, and returns its stdout
+type commandRunner func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// TesseractExtraction extracts invoices offline with the tesseract CLI. The
+// recognized text is mapped to key/value pairs and a table with heuristics,
+// and confidences come from the OCR word confidences.
+type TesseractExtraction struct {
+	command  string
+	language string
+	timeout  time.Duration
+	run      commandRunner
+}
+
+func NewTesseractExtraction(cfg ProviderConfig) (*TesseractExtraction, error) {
+	command := cfg.Command
+	if command == "" {
+		command = defaultTesseractCommand
+	}
+
+	language := cfg.Language
+	if language == "" {
+		language = defaultTesseractLanguage
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &TesseractExtraction{
+		command:  command,
+		language: language,
+		timeout:  timeout,
+		run:      runCommand,
+	}, nil
+}
+
+func (s *TesseractExtraction) Close() error {
+	return nil
+}
+
+// Extract recognizes the images one after the other and reads their lines as
+// one document, so a table continued on the next photo stays one table
+func (s *TesseractExtraction) Extract(ctx context.Context, images []invoice.Image, opts invoice.ExtractOptions) (invoice.ExtractedData, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	images, err := validateImages(images, validateImage)
+	if err != nil {
+		return invoice.ExtractedData{}, err
+	}
+
+	start := time.Now()
+	// local OCR has no tokens, only the latency is accounted for
+	usage := newUsage(ProviderTesseract, ProviderTesseract, start)
+
+	var lines []ocrLine
+	for i, image := range images {
+		output, err := s.run(ctx, image.Bytes, s.command, "stdin", "stdout", "-l", s.language, "--psm", tesseractPageSegMode, "tsv")
+		usage.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			err = fmt.Errorf("tesseract error: %w", err)
+			if len(images) > 1 {
+				err = fmt.Errorf("image %d: %w", i+1, err)
+			}
+			if ctx.Err() != nil {
+				return usageOnly(usage), invoice.NewRetryableError(err)
+			}
+			// a missing binary or language pack, or an image tesseract cannot
+			// read, will fail the same way next time
+			return usageOnly(usage), invoice.NewPermanentError(err)
+		}
+
+		imageLines, err := parseTesseractTSV(output)
+		if err != nil {
+			return usageOnly(usage), invoice.NewPermanentError(fmt.Errorf("failed to parse tesseract output: %w", err))
+		}
+		for j := range imageLines {
+			imageLines[j].Page = i + 1
+		}
+		lines = append(lines, imageLines...)
+	}
+	if len(lines) == 0 {
+		return usageOnly(usage), invoice.NewPermanentError(errors.New("no text recognized in image"))
+	}
+
+	data := buildOCRData(lines)
+	data.Usage = []invoice.Usage{usage}
+	return data, nil
+}
+
+type (
+	ocrWord struct {
+		Text       string
+		Left       int
+		Top        int
+		Width      int
+		Height     int
+		Confidence float64
+	}
+
+	ocrLine struct {
+		Top   int
+		Words []ocrWord
+		// Page is the image the line is on, from 1, and PageWidth and
+		// PageHeight its size in pixels
+		Page       int
+		PageWidth  int
+		PageHeight int
+	}
+)
+
+func (l ocrLine) Text() string {
+	texts := make([]string, len(l.Words))
+	for i, w := range l.Words {
+		texts[i] = w.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// box returns the bounding box of words of the line, nil when the size of the
+// page is unknown
+func (l ocrLine) box(words []ocrWord) *invoice.BoundingBox {
+	if len(words) == 0 || l.PageWidth <= 0 || l.PageHeight <= 0 {
+		return nil
+	}
+
+	left, top := words[0].Left, words[0].Top
+	right, bottom := 0, 0
+	for _, w := range words {
+		left = min(left, w.Left)
+		top = min(top, w.Top)
+		right = max(right, w.Left+w.Width)
+		bottom = max(bottom, w.Top+w.Height)
+	}
+
+	width, height := float64(l.PageWidth), float64(l.PageHeight)
+	return &invoice.BoundingBox{
+		Page:   max(l.Page, 1),
+		X:      float64(left) / width,
+		Y:      float64(top) / height,
+		Width:  float64(right-left) / width,
+		Height: float64(bottom-top) / height,
+	}
+}
+
+// parseTesseractTSV groups the words of tesseract's TSV output into lines,
+// top to bottom. Word confidences are converted to the 0..1 range.
+func parseTesseractTSV(output []byte) ([]ocrLine, error) {
+	type lineKey struct{ page, block, par, line int }
+
+	var (
+		order []lineKey
+		byKey = make(map[lineKey]*ocrLine)
+
+		pageWidth, pageHeight int
+	)
+
+	rows := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i, row := range rows {
+		if i == 0 && strings.HasPrefix(row, "level") {
+			continue
+		}
+
+		cols := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(cols) < 12 {
+			continue
+		}
+
+		// level 1 rows describe pages, 5 words, the others blocks and lines
+		if cols[0] == "1" {
+			pageWidth, _ = strconv.Atoi(cols[8])
+			pageHeight, _ = strconv.Atoi(cols[9])
+			continue
+		}
+		if cols[0] != "5" {
+			continue
+		}
+
+		text := strings.TrimSpace(cols[11])
+		if text == "" {
+			continue
+		}
+
+		nums := make([]int, 10)
+		for j := 1; j <= 9; j++ {
+			n, err := strconv.Atoi(cols[j])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tsv row %d: %w", i+1, err)
+			}
+			nums[j] = n
+		}
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tsv row %d: %w", i+1, err)
+		}
+		if conf < 0 {
+			conf = 0
+		}
+
+		key := lineKey{page: nums[1], block: nums[2], par: nums[3], line: nums[4]}
+		line, ok := byKey[key]
+		if !ok {
+			line = &ocrLine{Top: nums[7]}
+			byKey[key] = line
+			order = append(order, key)
+		}
+		line.Words = append(line.Words, ocrWord{
+			Text:       text,
+			Left:       nums[6],
+			Top:        nums[7],
+			Width:      nums[8],
+			Height:     nums[9],
+			Confidence: conf / 100,
+		})
+	}
+
+	lines := make([]ocrLine, len(order))
+	for i, key := range order {
+		lines[i] = *byKey[key]
+		lines[i].PageWidth = pageWidth
+		lines[i].PageHeight = pageHeight
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Top < lines[j].Top })
+
+	return lines, nil
+}
+
+var (
+	// keyValueSeparator splits "Số hóa đơn: 0001234" style lines
+	keyValueSeparator = regexp.MustCompile(`^([^:]{2,60}?)\s*:\s*(.+)$`)
+
+	summaryKeywords = []string{
+		"tổng", "cộng", "thuế", "gtgt", "vat", "thanh toán", "chiết khấu",
+		"total", "subtotal", "tax", "amount due", "discount",
+	}
+)
+
+// buildOCRData maps OCR lines to extracted data: runs of lines split into at
+// least minTableColumns cells become the table, "key: value" lines become key
+// value pairs, and pairs about totals and taxes go to the summary
+func buildOCRData(lines []ocrLine) invoice.ExtractedData {
+	data := invoice.ExtractedData{
+		KeyValuePairs: []invoice.KeyValuePair{},
+		Table:         invoice.TableData{Headers: []string{}, Rows: [][]string{}},
+		Summary:       []invoice.KeyValuePair{},
+	}
+
+	tableStart, tableEnd := findTable(lines)
+	if tableEnd > tableStart {
+		header := splitCells(lines[tableStart])
+		data.Table.Headers = cellTexts(header)
+		for _, line := range lines[tableStart+1 : tableEnd] {
+			row, boxes := alignCells(header, line)
+			data.Table.Rows = append(data.Table.Rows, row)
+			data.Table.CellBoxes = append(data.Table.CellBoxes, boxes)
+		}
+	}
+
+	for i, line := range lines {
+		if i >= tableStart && i < tableEnd {
+			continue
+		}
+
+		match := keyValueSeparator.FindStringSubmatch(line.Text())
+		if match == nil {
+			continue
+		}
+
+		confidence := line.confidence()
+		pair := invoice.KeyValuePair{
+			Key:         strings.TrimSpace(match[1]),
+			Value:       strings.TrimSpace(match[2]),
+			Confidence:  &confidence,
+			BoundingBox: line.box(line.Words),
+		}
+		if isSummaryKey(pair.Key) {
+			data.Summary = append(data.Summary, pair)
+		} else {
+			data.KeyValuePairs = append(data.KeyValuePairs, pair)
+		}
+	}
+
+	overall := averageConfidence(lines)
+	data.Confidence = &overall
+
+	return data
+}
+
+const minTableColumns = 3
+
+// findTable returns the longest run [start, end) of consecutive lines that
+// split into at least minTableColumns cells. The run needs a header and at
+// least one row.
+func findTable(lines []ocrLine) (int, int) {
+	bestStart, bestEnd := 0, 0
+	start := -1
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && len(splitCells(lines[i])) >= minTableColumns {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= 2 && i-start > bestEnd-bestStart {
+			bestStart, bestEnd = start, i
+		}
+		start = -1
+	}
+	return bestStart, bestEnd
+}
+
+type ocrCell struct {
+	Left  int
+	Right int
+	Words []ocrWord
+}
+
+// splitCells splits a line into cells wherever the gap between two words is
+// wider than the line height, which is much wider than a regular space
+func splitCells(line ocrLine) []ocrCell {
+	if len(line.Words) == 0 {
+		return nil
+	}
+
+	height := 0
+	for _, w := range line.Words {
+		height = max(height, w.Height)
+	}
+
+	var cells []ocrCell
+	for i, w := range line.Words {
+		if i == 0 || w.Left-cells[len(cells)-1].Right > height {
+			cells = append(cells, ocrCell{Left: w.Left})
+		}
+		cell := &cells[len(cells)-1]
+		cell.Words = append(cell.Words, w)
+		cell.Right = w.Left + w.Width
+	}
+	return cells
+}
+
+// alignCells places each cell of a line under the header column it overlaps
+// the most so that rows with empty cells keep their columns. It returns the
+// row with the bounding box of every column.
+func alignCells(header []ocrCell, line ocrLine) ([]string, []*invoice.BoundingBox) {
+	row := make([]string, len(header))
+	words := make([][]ocrWord, len(header))
+	for _, cell := range splitCells(line) {
+		best, bestOverlap := -1, -1<<31
+		for i, h := range header {
+			overlap := min(cell.Right, h.Right) - max(cell.Left, h.Left)
+			if overlap > bestOverlap {
+				best, bestOverlap = i, overlap
+			}
+		}
+		text := ocrLine{Words: cell.Words}.Text()
+		if row[best] != "" {
+			text = row[best] + " " + text
+		}
+		row[best] = text
+		words[best] = append(words[best], cell.Words...)
+	}
+
+	boxes := make([]*invoice.BoundingBox, len(header))
+	for i := range boxes {
+		boxes[i] = line.box(words[i])
+	}
+	return row, boxes
+}
+
+func cellTexts(cells []ocrCell) []string {
+	texts := make([]string, len(cells))
+	for i, cell := range cells {
+		texts[i] = ocrLine{Words: cell.Words}.Text()
+	}
+	return texts
+}
+
+func isSummaryKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, keyword := range summaryKeywords {
+		if strings.Contains(key, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l ocrLine) confidence() float64 {
+	if len(l.Words) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, w := range l.Words {
+		total += w.Confidence
+	}
+	return total / float64(len(l.Words))
+}
+
+func averageConfidence(lines []ocrLine) float64 {
+	total, count := 0.0, 0
+	for _, line := range lines {
+		for _, w := range line.Words {
+			total += w.Confidence
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
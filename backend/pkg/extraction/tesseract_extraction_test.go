@@ -0,0 +1,214 @@
+package extraction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tsvWord struct {
+	line  int
+	left  int
+	width int
+	conf  float64
+	text  string
+}
+
+// buildTSV renders words the way `tesseract ... tsv` prints them, one line
+// every 40 pixels
+func buildTSV(words []tsvWord) []byte {
+	var b strings.Builder
+	b.WriteString("level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n")
+	b.WriteString("1\t1\t0\t0\t0\t0\t0\t0\t1000\t1000\t-1\t\n")
+	for i, w := range words {
+		fmt.Fprintf(&b, "5\t1\t1\t1\t%d\t%d\t%d\t%d\t%d\t20\t%.2f\t%s\n", w.line, i+1, w.left, w.line*40, w.width, w.conf, w.text)
+	}
+	return []byte(b.String())
+}
+
+var testInvoiceWords = []tsvWord{
+	{1, 10, 40, 96, "HÓA"},
+	{1, 55, 40, 94, "ĐƠN"},
+	{1, 100, 60, 92, "GTGT"},
+	{2, 10, 30, 90, "Số:"},
+	{2, 45, 80, 80, "0001234"},
+	{3, 10, 60, 95, "Ngày:"},
+	{3, 75, 100, 85, "15/03/2024"},
+	{4, 10, 40, 90, "Tên"},
+	{4, 55, 40, 90, "hàng"},
+	{4, 300, 30, 90, "SL"},
+	{4, 400, 60, 90, "Đơn"},
+	{4, 465, 40, 90, "giá"},
+	{4, 600, 60, 90, "Thành"},
+	{4, 665, 40, 90, "tiền"},
+	{5, 10, 40, 80, "Cà"},
+	{5, 55, 40, 80, "phê"},
+	{5, 300, 20, 70, "2"},
+	{5, 400, 80, 70, "25.000"},
+	{5, 600, 80, 70, "50.000"},
+	{6, 10, 40, 80, "Trà"},
+	{6, 300, 20, 70, "1"},
+	{6, 600, 80, 70, "15.000"},
+	{7, 10, 40, 90, "Tổng"},
+	{7, 55, 60, 90, "cộng:"},
+	{7, 120, 80, 60, "65.000"},
+}
+
+func newTestTesseract(t *testing.T, run commandRunner) *TesseractExtraction {
+	t.Helper()
+	s, err := NewTesseractExtraction(ProviderConfig{})
+	require.NoError(t, err)
+	s.run = run
+	return s
+}
+
+func TestTesseractExtraction_Extract(t *testing.T) {
+	var gotArgs []string
+	s := newTestTesseract(t, func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		assert.Equal(t, "tesseract", name)
+		assert.Equal(t, []byte("image"), stdin)
+		gotArgs = args
+		return buildTSV(testInvoiceWords), nil
+	})
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"stdin", "stdout", "-l", "vie+eng", "--psm", "4", "tsv"}, gotArgs)
+
+	require.Len(t, data.KeyValuePairs, 2)
+	assert.Equal(t, "Số", data.KeyValuePairs[0].Key)
+	assert.Equal(t, "0001234", data.KeyValuePairs[0].Value)
+	assert.InDelta(t, 0.85, *data.KeyValuePairs[0].Confidence, 0.001)
+	assert.Equal(t, "Ngày", data.KeyValuePairs[1].Key)
+	assert.Equal(t, "15/03/2024", data.KeyValuePairs[1].Value)
+
+	assert.Equal(t, []string{"Tên hàng", "SL", "Đơn giá", "Thành tiền"}, data.Table.Headers)
+	assert.Equal(t, [][]string{
+		{"Cà phê", "2", "25.000", "50.000"},
+		{"Trà", "1", "", "15.000"},
+	}, data.Table.Rows)
+
+	require.Len(t, data.Summary, 1)
+	assert.Equal(t, "Tổng cộng", data.Summary[0].Key)
+	assert.Equal(t, "65.000", data.Summary[0].Value)
+	assert.InDelta(t, 0.8, *data.Summary[0].Confidence, 0.001)
+
+	require.NotNil(t, data.Confidence)
+	assert.True(t, *data.Confidence > 0 && *data.Confidence <= 1)
+}
+
+func TestTesseractExtraction_Extract_BoundingBoxes(t *testing.T) {
+	s := newTestTesseract(t, func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return buildTSV(testInvoiceWords), nil
+	})
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	// "Số: 0001234" spans from 10 to 125 pixels on a 1000 pixel page
+	box := data.KeyValuePairs[0].BoundingBox
+	require.NotNil(t, box)
+	assert.Equal(t, 1, box.Page)
+	assert.InDelta(t, 0.01, box.X, 0.0001)
+	assert.InDelta(t, 0.08, box.Y, 0.0001)
+	assert.InDelta(t, 0.115, box.Width, 0.0001)
+	assert.InDelta(t, 0.02, box.Height, 0.0001)
+
+	require.Len(t, data.Table.CellBoxes, 2)
+	require.Len(t, data.Table.CellBoxes[0], 4)
+	assert.InDelta(t, 0.01, data.Table.CellBoxes[0][0].X, 0.0001)
+	assert.InDelta(t, 0.085, data.Table.CellBoxes[0][0].Width, 0.0001)
+	assert.InDelta(t, 0.6, data.Table.CellBoxes[0][3].X, 0.0001)
+	assert.Nil(t, data.Table.CellBoxes[1][2], "empty cells have no box")
+
+	require.NotNil(t, data.Summary[0].BoundingBox)
+	assert.InDelta(t, 0.28, data.Summary[0].BoundingBox.Y, 0.0001)
+}
+
+func TestTesseractExtraction_Extract_Pages(t *testing.T) {
+	// the front photo ends with the table header, the back one continues it
+	pages := map[string][]byte{
+		"front": buildTSV(testInvoiceWords[:14]),
+		"back":  buildTSV(testInvoiceWords[14:]),
+	}
+	s := newTestTesseract(t, func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return pages[string(stdin)], nil
+	})
+
+	data, err := s.Extract(context.Background(), []invoice.Image{
+		{Bytes: []byte("front"), MIMEType: "image/jpeg"},
+		{Bytes: []byte("back"), MIMEType: "image/jpeg"},
+	}, invoice.ExtractOptions{})
+	require.NoError(t, err)
+
+	assert.Len(t, data.KeyValuePairs, 2)
+	assert.Equal(t, []string{"Tên hàng", "SL", "Đơn giá", "Thành tiền"}, data.Table.Headers)
+	assert.Len(t, data.Table.Rows, 2)
+	require.Len(t, data.Summary, 1)
+	assert.Equal(t, "65.000", data.Summary[0].Value)
+	require.NotNil(t, data.Summary[0].BoundingBox)
+	assert.Equal(t, 2, data.Summary[0].BoundingBox.Page)
+	assert.Len(t, data.Usage, 1)
+}
+
+func TestTesseractExtraction_Extract_NoTable(t *testing.T) {
+	s := newTestTesseract(t, func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		return buildTSV(testInvoiceWords[:7]), nil
+	})
+
+	data, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/jpeg"), invoice.ExtractOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, data.Table.Headers)
+	assert.Empty(t, data.Table.Rows)
+	assert.Len(t, data.KeyValuePairs, 2)
+}
+
+func TestTesseractExtraction_Extract_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    []byte
+		err       error
+		retryable bool
+	}{
+		{name: "command failed", err: errors.New("exit status 1: Failed loading language 'vie'"), retryable: false},
+		{name: "no text", output: buildTSV(nil), retryable: false},
+		{name: "timeout", err: context.DeadlineExceeded, retryable: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestTesseract(t, func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+				if errors.Is(test.err, context.DeadlineExceeded) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return test.output, test.err
+			})
+			s.timeout = 10 * time.Millisecond
+
+			_, err := s.Extract(context.Background(), oneImage([]byte("image"), "image/png"), invoice.ExtractOptions{})
+			require.Error(t, err)
+			assert.Equal(t, test.retryable, invoice.IsRetryable(err))
+		})
+	}
+}
+
+func TestParseTesseractTSV(t *testing.T) {
+	lines, err := parseTesseractTSV(buildTSV(testInvoiceWords))
+	require.NoError(t, err)
+	require.Len(t, lines, 7)
+	assert.Equal(t, "HÓA ĐƠN GTGT", lines[0].Text())
+	assert.InDelta(t, 0.96, lines[0].Words[0].Confidence, 0.001)
+
+	_, err = parseTesseractTSV([]byte("5\t1\t1\t1\tx\t1\t0\t0\t10\t10\t90\tword"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package extraction
+
+import (
+	"time"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+// DefaultPricing holds the list prices of the default models in USD per
+// million tokens. Models missing here are recorded at no cost unless priced
+// in the config.
+var DefaultPricing = invoice.Pricing{
+	"gemini-2.5-flash":      {InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	"gemini-2.5-pro":        {InputPerMillion: 1.25, OutputPerMillion: 10.00},
+	"gemini-2.0-flash":      {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+}
+
+// newUsage starts the usage of a provider call that began at start
+func newUsage(provider, model string, start time.Time) invoice.Usage {
+	return invoice.Usage{
+		Provider:  provider,
+		Model:     model,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+}
+
+// usageOnly is the data returned with the error of a failed provider call, so
+// the call is still accounted for
+func usageOnly(usage invoice.Usage) invoice.ExtractedData {
+	return invoice.ExtractedData{Usage: []invoice.Usage{usage}}
+}
+
+// totalTokens sums the tokens of every provider call
+func totalTokens(usage []invoice.Usage) int {
+	var total int
+	for _, u := range usage {
+		total += u.TotalTokens
+	}
+	return total
+}
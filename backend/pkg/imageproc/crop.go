@@ -0,0 +1,68 @@
+package imageproc
+
+import "image"
+
+const (
+	// paperFraction is the share of bright pixels that makes a row or column
+	// part of the document
+	paperFraction = 0.5
+	// minCropArea is the smallest share of the image a crop may keep, below it
+	// the document is more likely misdetected than small
+	minCropArea = 0.2
+	// maxCropArea is the largest share of the image a crop may keep, above it
+	// the image is left alone
+	maxCropArea = 0.98
+)
+
+// cropDocument cuts the background around a document photographed on a darker
+// surface: the rows and columns mostly brighter than the ink are the paper
+func cropDocument(img *image.NRGBA) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	threshold := otsuThreshold(luminanceHistogram(img))
+
+	rows, cols := make([]int, h), make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			if luminance(img.Pix[i], img.Pix[i+1], img.Pix[i+2]) > threshold {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+
+	top, bottom, ok := paperSpan(rows, w)
+	if !ok {
+		return img
+	}
+	left, right, ok := paperSpan(cols, h)
+	if !ok {
+		return img
+	}
+
+	area := float64((right-left)*(bottom-top)) / float64(w*h)
+	if area < minCropArea || area > maxCropArea {
+		return img
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, right-left, bottom-top))
+	for y := top; y < bottom; y++ {
+		copy(dst.Pix[dst.PixOffset(0, y-top):], img.Pix[img.PixOffset(left, y):img.PixOffset(right, y)])
+	}
+	return dst
+}
+
+// paperSpan returns the first and past the last line of counts whose bright
+// pixels make up paperFraction of its length
+func paperSpan(counts []int, length int) (start, end int, ok bool) {
+	start = -1
+	for i, count := range counts {
+		if float64(count) >= paperFraction*float64(length) {
+			if start < 0 {
+				start = i
+			}
+			end = i + 1
+		}
+	}
+	return start, end, start >= 0
+}
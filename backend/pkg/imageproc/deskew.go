@@ -0,0 +1,118 @@
+package imageproc
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// skewStep is the precision in degrees the skew angle is searched with
+	skewStep = 0.25
+	// skewSampleSize is the longest side of the copy skew is measured on
+	skewSampleSize = 800
+)
+
+// deskew rotates img so that its text lines are horizontal. Angles beyond
+// maxSkew degrees are left alone: they are more likely a misreading of the
+// layout than a tilted photo.
+func deskew(img *image.NRGBA, maxSkew float64) *image.NRGBA {
+	angle := detectSkew(img, maxSkew)
+	if math.Abs(angle) < skewStep {
+		return img
+	}
+	return rotate(img, angle)
+}
+
+// detectSkew returns the angle in degrees the text lines of img go down by
+// from left to right. Rows of text are sharpest in the projection of the dark
+// pixels along the right angle.
+func detectSkew(img *image.NRGBA, maxSkew float64) float64 {
+	sample := downscale(img, skewSampleSize)
+	threshold := otsuThreshold(luminanceHistogram(sample))
+
+	var xs, ys []float64
+	w, h := sample.Bounds().Dx(), sample.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := sample.PixOffset(x, y)
+			if luminance(sample.Pix[i], sample.Pix[i+1], sample.Pix[i+2]) <= threshold {
+				xs = append(xs, float64(x))
+				ys = append(ys, float64(y))
+			}
+		}
+	}
+	// a blank page, or one that is mostly dark, has no lines to follow
+	if len(xs) == 0 || len(xs) > w*h/2 {
+		return 0
+	}
+
+	diagonal := int(math.Hypot(float64(w), float64(h))) + 1
+	bins := make([]int, 2*diagonal)
+
+	var best, bestScore float64
+	for angle := -maxSkew; angle <= maxSkew+skewStep/2; angle += skewStep {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		clear(bins)
+		for i := range xs {
+			bins[int(ys[i]*cos-xs[i]*sin)+diagonal]++
+		}
+
+		score := 0.0
+		for _, count := range bins {
+			score += float64(count) * float64(count)
+		}
+		// prefer the smallest rotation between equally sharp ones
+		if score > bestScore || (score == bestScore && math.Abs(angle) < math.Abs(best)) {
+			best, bestScore = angle, score
+		}
+	}
+	return math.Round(best/skewStep) * skewStep
+}
+
+// rotate turns img by angle degrees counterclockwise around its center,
+// keeping its size and filling the uncovered corners with white
+func rotate(img *image.NRGBA, angle float64) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	cx, cy := float64(w-1)/2, float64(h-1)/2
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u, v := float64(x)-cx, float64(y)-cy
+			sx, sy := u*cos-v*sin+cx, u*sin+v*cos+cy
+
+			i := dst.PixOffset(x, y)
+			sample := bilinear(img, sx, sy)
+			copy(dst.Pix[i:i+4], sample[:])
+		}
+	}
+	return dst
+}
+
+// bilinear interpolates the pixel of img at a fractional position, white
+// outside of it
+func bilinear(img *image.NRGBA, x, y float64) [4]uint8 {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	pixel := func(px, py int) [4]float64 {
+		if px < 0 || py < 0 || px >= w || py >= h {
+			return [4]float64{255, 255, 255, 255}
+		}
+		i := img.PixOffset(px, py)
+		return [4]float64{float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2]), float64(img.Pix[i+3])}
+	}
+
+	p00, p10 := pixel(x0, y0), pixel(x0+1, y0)
+	p01, p11 := pixel(x0, y0+1), pixel(x0+1, y0+1)
+
+	var out [4]uint8
+	for c := 0; c < 4; c++ {
+		top := p00[c]*(1-fx) + p10[c]*fx
+		bottom := p01[c]*(1-fx) + p11[c]*fx
+		out[c] = uint8(math.Round(top*(1-fy) + bottom*fy))
+	}
+	return out
+}
@@ -0,0 +1,184 @@
+// Package imageproc prepares photos of invoices for extraction: it straightens
+// them, shrinks them to what models actually read and evens out the lighting.
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"invoice-scan/backend/internal/domain/invoice"
+)
+
+// Steps of a pipeline, run in the order they are configured
+const (
+	// StepOrient applies the EXIF orientation of the photo to its pixels
+	StepOrient = "orient"
+	// StepDownscale shrinks images larger than Config.MaxDimension
+	StepDownscale = "downscale"
+	// StepGrayscale drops colors
+	StepGrayscale = "grayscale"
+	// StepContrast stretches the brightness to the full range
+	StepContrast = "contrast"
+	// StepDeskew rotates the text lines back to horizontal
+	StepDeskew = "deskew"
+	// StepCrop cuts the background around the document
+	StepCrop = "crop"
+)
+
+const (
+	DefaultMaxDimension = 2000
+	DefaultQuality      = 85
+	DefaultMaxSkew      = 5.0
+)
+
+// MaxPixels is the largest image decoded, 50 megapixels. Decoding needs
+// about 8 bytes a pixel, so larger images are left as they are rather than
+// risk running out of memory on a small but highly compressed upload.
+const MaxPixels = 50_000_000
+
+// ErrUnsupportedFormat is returned for images the pipeline cannot decode,
+// including images over MaxPixels
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+type Config struct {
+	// Steps are the names of the steps to run, in order
+	Steps []string
+	// MaxDimension is the longest side images are downscaled to
+	MaxDimension int
+	// Quality is the JPEG quality processed images are encoded with
+	Quality int
+	// MaxSkew is the largest rotation in degrees deskew corrects
+	MaxSkew float64
+}
+
+// frame is the image going through the pipeline
+type frame struct {
+	img *image.NRGBA
+	// orientation is the EXIF orientation the pixels still have to be
+	// turned by, 1 once they are upright
+	orientation int
+	gray        bool
+}
+
+type step func(f *frame)
+
+// Pipeline runs the configured steps on an image and re-encodes it as JPEG
+type Pipeline struct {
+	steps   []step
+	quality int
+}
+
+func NewPipeline(cfg Config) (*Pipeline, error) {
+	if cfg.MaxDimension <= 0 {
+		cfg.MaxDimension = DefaultMaxDimension
+	}
+	if cfg.Quality <= 0 || cfg.Quality > 100 {
+		cfg.Quality = DefaultQuality
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = DefaultMaxSkew
+	}
+
+	p := &Pipeline{quality: cfg.Quality}
+	for _, name := range cfg.Steps {
+		switch name {
+		case StepOrient:
+			p.steps = append(p.steps, orient)
+		case StepDownscale:
+			maxDimension := cfg.MaxDimension
+			p.steps = append(p.steps, func(f *frame) { f.img = downscale(f.img, maxDimension) })
+		case StepGrayscale:
+			p.steps = append(p.steps, func(f *frame) { grayscale(f.img); f.gray = true })
+		case StepContrast:
+			p.steps = append(p.steps, func(f *frame) { stretchContrast(f.img) })
+		case StepDeskew:
+			maxSkew := cfg.MaxSkew
+			p.steps = append(p.steps, func(f *frame) { f.img = deskew(f.img, maxSkew) })
+		case StepCrop:
+			p.steps = append(p.steps, func(f *frame) { f.img = cropDocument(f.img) })
+		default:
+			return nil, fmt.Errorf("unknown preprocessing step %q", name)
+		}
+	}
+	return p, nil
+}
+
+// Preprocess runs the steps of the pipeline on an image. The result is always
+// a JPEG, whatever the format of the original.
+func (p *Pipeline) Preprocess(ctx context.Context, img invoice.Image) (invoice.Image, error) {
+	data, err := p.Process(img.Bytes)
+	if err != nil {
+		return invoice.Image{}, err
+	}
+	return invoice.Image{Bytes: data, MIMEType: "image/jpeg"}, nil
+}
+
+// Process decodes an encoded image, runs the steps on it and returns it
+// encoded as JPEG
+func (p *Pipeline) Process(data []byte) ([]byte, error) {
+	f, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range p.steps {
+		s(f)
+	}
+
+	var out image.Image = f.img
+	if f.gray {
+		out = toGray(f.img)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: p.quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decode reads an image flattened onto white, so transparent areas of PNG
+// scans do not turn black in the JPEG
+func decode(data []byte) (*frame, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrUnsupportedFormat, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Over)
+
+	return &frame{img: img, orientation: readOrientation(data)}, nil
+}
+
+func toGray(img *image.NRGBA) *image.Gray {
+	gray := image.NewGray(img.Bounds())
+	for i := 0; i < len(gray.Pix); i++ {
+		gray.Pix[i] = img.Pix[i*4]
+	}
+	return gray
+}
@@ -0,0 +1,276 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"invoice-scan/backend/internal/domain/invoice"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// goldenTolerance absorbs floating point differences between platforms
+const goldenTolerance = 2
+
+// testDocument draws a page of text lines: black bars of varying lengths on
+// white paper
+func testDocument(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	lengths := []int{80, 95, 60, 90, 70, 85, 40, 75}
+	for i, y := 0, h/8; y+h/30 < h-h/10; i, y = i+1, y+h/10 {
+		length := (w - w/5) * lengths[i%len(lengths)] / 100
+		draw.Draw(img, image.Rect(w/10, y, w/10+length, y+h/30), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// testColors paints the corners in different colors so rotations and flips
+// are told apart
+func testColors(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, w/2, h/2), image.NewUniform(color.NRGBA{R: 220, G: 40, B: 40, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(w/2, 0, w, h/3), image.NewUniform(color.NRGBA{R: 40, G: 160, B: 60, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, h*3/4, w/4, h), image.NewUniform(color.NRGBA{R: 30, G: 60, B: 200, A: 255}), image.Point{}, draw.Src)
+	return img
+}
+
+// faded maps the brightness of img to low..high, like a receipt printed with
+// a worn ribbon and photographed in dim light
+func faded(img *image.NRGBA, low, high int) *image.NRGBA {
+	dst := image.NewNRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			dst.Pix[i+c] = uint8(low + int(img.Pix[i+c])*(high-low)/255)
+		}
+		dst.Pix[i+3] = 255
+	}
+	return dst
+}
+
+// onBackground places img in the middle of a dark surface
+func onBackground(img *image.NRGBA, margin int) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()+2*margin, bounds.Dy()+2*margin))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.NRGBA{R: 60, G: 50, B: 45, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds.Add(image.Pt(margin, margin)), img, image.Point{}, draw.Src)
+	return dst
+}
+
+// withOrientation encodes img as a JPEG carrying an EXIF orientation
+func withOrientation(t *testing.T, img image.Image, orientation int, order binary.ByteOrder) []byte {
+	t.Helper()
+
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}))
+
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	data := encoded.Bytes()
+	result := append([]byte{}, data[:2]...)
+	result = append(result, app1...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestSteps_Golden(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		input *frame
+	}{
+		{name: "orient", cfg: Config{Steps: []string{StepOrient}}, input: &frame{img: testColors(60, 40), orientation: 6}},
+		{name: "orient_mirrored", cfg: Config{Steps: []string{StepOrient}}, input: &frame{img: testColors(60, 40), orientation: 5}},
+		{name: "downscale", cfg: Config{Steps: []string{StepDownscale}, MaxDimension: 120}, input: &frame{img: testDocument(400, 300)}},
+		{name: "grayscale", cfg: Config{Steps: []string{StepGrayscale}}, input: &frame{img: testColors(60, 40)}},
+		{name: "contrast", cfg: Config{Steps: []string{StepContrast}}, input: &frame{img: faded(testDocument(200, 150), 90, 170)}},
+		{name: "deskew", cfg: Config{Steps: []string{StepDeskew}}, input: &frame{img: rotate(testDocument(300, 220), -3)}},
+		{name: "crop", cfg: Config{Steps: []string{StepCrop}}, input: &frame{img: onBackground(testDocument(200, 150), 30)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := NewPipeline(test.cfg)
+			require.NoError(t, err)
+			for _, s := range p.steps {
+				s(test.input)
+			}
+			assertGolden(t, test.name, test.input.img)
+		})
+	}
+}
+
+// assertGolden compares img with testdata/<name>.png, which -update rewrites
+func assertGolden(t *testing.T, name string, img *image.NRGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".png")
+
+	if *update {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, img))
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err, "run go test -update to create the golden image")
+	defer file.Close()
+	golden, err := png.Decode(file)
+	require.NoError(t, err)
+
+	require.Equal(t, golden.Bounds().Size(), img.Bounds().Size(), "size")
+	want := image.NewNRGBA(golden.Bounds())
+	draw.Draw(want, want.Bounds(), golden, golden.Bounds().Min, draw.Src)
+
+	mismatches := 0
+	for i := range want.Pix {
+		diff := int(want.Pix[i]) - int(img.Pix[i])
+		if diff > goldenTolerance || diff < -goldenTolerance {
+			mismatches++
+		}
+	}
+	assert.Zero(t, mismatches, "pixels differing from %s", path)
+}
+
+func TestDetectSkew(t *testing.T) {
+	doc := testDocument(300, 220)
+	assert.InDelta(t, 0, detectSkew(doc, DefaultMaxSkew), 0.01)
+	assert.InDelta(t, 3, detectSkew(rotate(doc, -3), DefaultMaxSkew), skewStep)
+	assert.InDelta(t, -2, detectSkew(rotate(doc, 2), DefaultMaxSkew), skewStep)
+
+	blank := testDocument(300, 220)
+	draw.Draw(blank, blank.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	assert.Zero(t, detectSkew(blank, DefaultMaxSkew))
+}
+
+func TestCropDocument_NoBackground(t *testing.T) {
+	doc := testDocument(200, 150)
+	assert.Same(t, doc, cropDocument(doc))
+}
+
+func TestReadOrientation(t *testing.T) {
+	img := testColors(16, 8)
+	assert.Equal(t, 6, readOrientation(withOrientation(t, img, 6, binary.BigEndian)))
+	assert.Equal(t, 8, readOrientation(withOrientation(t, img, 8, binary.LittleEndian)))
+
+	var plain bytes.Buffer
+	require.NoError(t, jpeg.Encode(&plain, img, nil))
+	assert.Equal(t, 1, readOrientation(plain.Bytes()))
+
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, img))
+	assert.Equal(t, 1, readOrientation(encoded.Bytes()))
+
+	assert.Equal(t, 1, readOrientation([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF}))
+}
+
+func TestPipeline_Preprocess(t *testing.T) {
+	p, err := NewPipeline(Config{Steps: []string{StepOrient, StepDownscale, StepGrayscale}, MaxDimension: 100})
+	require.NoError(t, err)
+
+	// a 400x200 photo taken with the phone turned
+	data := withOrientation(t, testColors(400, 200), 6, binary.BigEndian)
+	processed, err := p.Preprocess(context.Background(), invoice.Image{Bytes: data, MIMEType: "image/jpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", processed.MIMEType)
+
+	img, format, err := image.Decode(bytes.NewReader(processed.Bytes))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Pt(50, 100), img.Bounds().Size())
+	assert.IsType(t, &image.Gray{}, img)
+	assert.Equal(t, 1, readOrientation(processed.Bytes), "the orientation is applied once")
+}
+
+func TestPipeline_Preprocess_PNG(t *testing.T) {
+	p, err := NewPipeline(Config{Steps: []string{StepOrient}})
+	require.NoError(t, err)
+
+	// transparent areas become white rather than black
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, img))
+
+	processed, err := p.Preprocess(context.Background(), invoice.Image{Bytes: encoded.Bytes(), MIMEType: "image/png"})
+	require.NoError(t, err)
+	decoded, err := jpeg.Decode(bytes.NewReader(processed.Bytes))
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(10, 10).RGBA()
+	assert.Equal(t, []uint32{0xFFFF, 0xFFFF, 0xFFFF}, []uint32{r, g, b})
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	_, err := NewPipeline(Config{Steps: []string{StepOrient, "sharpen"}})
+	assert.ErrorContains(t, err, `unknown preprocessing step "sharpen"`)
+
+	p, err := NewPipeline(Config{Steps: []string{StepOrient}})
+	require.NoError(t, err)
+
+	_, err = p.Preprocess(context.Background(), invoice.Image{Bytes: []byte("%PDF-1.4"), MIMEType: "application/pdf"})
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = p.Preprocess(context.Background(), invoice.Image{Bytes: []byte{0xFF, 0xD8, 0xFF}, MIMEType: "image/jpeg"})
+	assert.Error(t, err)
+}
+
+func TestPipeline_TooManyPixels(t *testing.T) {
+	p, err := NewPipeline(Config{Steps: []string{StepOrient}})
+	require.NoError(t, err)
+
+	// a PNG header claiming 30000×30000 pixels, rejected before its pixels
+	// are read
+	_, err = p.Process(pngHeader(30000, 30000))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	_, err = p.Process(pngHeader(50001, 1000))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+// pngHeader returns the chunks of an 8-bit RGB PNG up to its first, empty,
+// image data chunk, as much as image.DecodeConfig reads
+func pngHeader(w, h uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	for _, chunk := range [][]byte{ihdr, []byte("IDAT")} {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(chunk)-4))
+		data = append(data, chunk...)
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(chunk))
+	}
+	return data
+}
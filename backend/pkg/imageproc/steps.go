@@ -0,0 +1,179 @@
+package imageproc
+
+import (
+	"image"
+)
+
+// orient turns the pixels the way the EXIF orientation says the photo should
+// be viewed, so models that ignore EXIF do not read it sideways
+func orient(f *frame) {
+	if f.orientation <= 1 || f.orientation > 8 {
+		return
+	}
+
+	src := f.img
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if f.orientation >= 5 {
+		// 5 to 8 swap the width and height
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch f.orientation {
+			case 2: // mirror
+				sx, sy = w-1-x, y
+			case 3: // rotate 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90° counterclockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	f.img = dst
+	f.orientation = 1
+}
+
+// downscale shrinks img so that its longest side is at most maxDimension,
+// averaging the pixels each output pixel covers
+func downscale(img *image.NRGBA, maxDimension int) *image.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxDimension && h <= maxDimension {
+		return img
+	}
+
+	dw, dh := maxDimension, h*maxDimension/w
+	if h > w {
+		dw, dh = w*maxDimension/h, maxDimension
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var sum [4]int
+			for sy := sy0; sy < sy1; sy++ {
+				row := img.Pix[img.PixOffset(sx0, sy):img.PixOffset(sx1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (sy1 - sy0) * (sx1 - sx0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// luminance is the perceived brightness of a pixel, ITU-R BT.601
+func luminance(r, g, b uint8) uint8 {
+	return uint8((299*int(r) + 587*int(g) + 114*int(b) + 500) / 1000)
+}
+
+func grayscale(img *image.NRGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		l := luminance(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2] = l, l, l
+	}
+}
+
+// contrastClip is the fraction of the darkest and brightest pixels that are
+// clipped so a few specks do not hold the range
+const contrastClip = 0.01
+
+// stretchContrast maps the brightness range of the image to 0..255, which
+// makes faded receipts and photos taken in dim light legible
+func stretchContrast(img *image.NRGBA) {
+	histogram := luminanceHistogram(img)
+	total := len(img.Pix) / 4
+	clip := int(float64(total) * contrastClip)
+
+	low, high := 0, 255
+	for count := 0; low < 255; low++ {
+		if count += histogram[low]; count > clip {
+			break
+		}
+	}
+	for count := 0; high > 0; high-- {
+		if count += histogram[high]; count > clip {
+			break
+		}
+	}
+	if high-low < 2 || (low == 0 && high == 255) {
+		return
+	}
+
+	var lookup [256]uint8
+	for v := range lookup {
+		scaled := (v - low) * 255 / (high - low)
+		lookup[v] = uint8(min(max(scaled, 0), 255))
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = lookup[img.Pix[i]]
+		img.Pix[i+1] = lookup[img.Pix[i+1]]
+		img.Pix[i+2] = lookup[img.Pix[i+2]]
+	}
+}
+
+func luminanceHistogram(img *image.NRGBA) [256]int {
+	var histogram [256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		histogram[luminance(img.Pix[i], img.Pix[i+1], img.Pix[i+2])]++
+	}
+	return histogram
+}
+
+// otsuThreshold is the brightness that best splits the histogram in two
+// classes, e.g. ink and paper
+func otsuThreshold(histogram [256]int) uint8 {
+	total, sum := 0, 0
+	for v, count := range histogram {
+		total += count
+		sum += v * count
+	}
+
+	var (
+		best                 uint8
+		bestVariance         float64
+		backCount, backTotal int
+	)
+	for v, count := range histogram {
+		backCount += count
+		backTotal += v * count
+		foreCount := total - backCount
+		if backCount == 0 || foreCount == 0 {
+			continue
+		}
+
+		backMean := float64(backTotal) / float64(backCount)
+		foreMean := float64(sum-backTotal) / float64(foreCount)
+		variance := float64(backCount) * float64(foreCount) * (backMean - foreMean) * (backMean - foreMean)
+		if variance > bestVariance {
+			best, bestVariance = uint8(v), variance
+		}
+	}
+	return best
+}
@@ -0,0 +1,89 @@
+package money
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// minorUnits are the digits after the decimal point of the ISO 4217
+// currencies recognized in text
+var minorUnits = map[string]int{
+	"VND": 0, "JPY": 0, "KRW": 0,
+	"USD": 2, "EUR": 2, "CNY": 2, "SGD": 2, "THB": 2, "GBP": 2, "AUD": 2,
+}
+
+// currencySymbols are checked in order, so longer symbols come before the
+// ones they contain
+var currencySymbols = []struct {
+	symbol string
+	code   string
+}{
+	{"US$", "USD"},
+	{"S$", "SGD"},
+	{"A$", "AUD"},
+	{"₫", "VND"},
+	{"đ", "VND"},
+	{"$", "USD"},
+	{"€", "EUR"},
+	{"£", "GBP"},
+	{"¥", "JPY"},
+	{"₩", "KRW"},
+	{"฿", "THB"},
+}
+
+// currencyNames are the currency words recognized besides the codes, without
+// diacritics
+var currencyNames = map[string]string{
+	"dong": "VND",
+	"vnd":  "VND",
+}
+
+// MinorUnits returns the digits after the decimal point currency is counted
+// in, 2 for unknown currencies
+func MinorUnits(currency string) int {
+	if units, ok := minorUnits[currency]; ok {
+		return units
+	}
+	return 2
+}
+
+// DetectCurrency returns the ISO 4217 code of the currency s is written in,
+// from a code such as "VND", a word such as "đồng" or a symbol such as "đ" or
+// "$", empty for none
+func DetectCurrency(s string) string {
+	for _, word := range words(s) {
+		code := strings.ToUpper(word)
+		if _, ok := minorUnits[code]; ok {
+			return code
+		}
+		if code, ok := currencyNames[word]; ok {
+			return code
+		}
+	}
+	lower := strings.ToLower(s)
+	for _, c := range currencySymbols {
+		if strings.Contains(lower, strings.ToLower(c.symbol)) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+// words splits s into lowercase letter runs without diacritics, "VNĐ"
+// becoming "vnd" and "đồng" becoming "dong"
+func words(s string) []string {
+	folded := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			return -1
+		case r == 'đ':
+			return 'd'
+		case unicode.IsLetter(r):
+			return r
+		}
+		return ' '
+	}, norm.NFD.String(strings.ToLower(s)))
+	return strings.Fields(folded)
+}
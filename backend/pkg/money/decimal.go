@@ -0,0 +1,200 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimal is an exact decimal number: an integer coefficient scaled down by
+// a power of ten. The zero value is 0. Decimals are immutable, the arithmetic
+// methods return new ones.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+var ten = big.NewInt(10)
+
+// NewDecimal returns coef×10^-scale, e.g. NewDecimal(125, 1) is 12.5
+func NewDecimal(coef int64, scale int32) Decimal {
+	return Decimal{coef: big.NewInt(coef), scale: scale}.normalize()
+}
+
+// ParseDecimal reads the canonical form String writes, e.g. "-1234567.5".
+// Use Parse or ParseNumber for numbers written for people.
+func ParseDecimal(s string) (Decimal, error) {
+	body := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(body, ".")
+	if whole == "" || strings.Trim(whole+fraction, "0123456789") != "" || strings.HasSuffix(body, ".") {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+	coef, _ := new(big.Int).SetString(whole+fraction, 10)
+	if body != s {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, scale: int32(len(fraction))}.normalize(), nil
+}
+
+// MustParseDecimal is ParseDecimal for constants, it panics on an error
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// normalize drops the trailing zeros of the coefficient, so equal decimals
+// have the same representation
+func (d Decimal) normalize() Decimal {
+	coef := new(big.Int).Set(d.int())
+	scale := d.scale
+	if coef.Sign() == 0 {
+		return Decimal{coef: coef}
+	}
+	q, r := new(big.Int), new(big.Int)
+	for scale > 0 {
+		q.QuoRem(coef, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		coef.Set(q)
+		scale--
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+// rescale returns the coefficients of a and b at their common scale
+func rescale(a, b Decimal) (*big.Int, *big.Int, int32) {
+	scale := max(a.scale, b.scale)
+	return shift(a.int(), scale-a.scale), shift(b.int(), scale-b.scale), scale
+}
+
+// shift multiplies coef by 10^n
+func shift(coef *big.Int, n int32) *big.Int {
+	return new(big.Int).Mul(coef, new(big.Int).Exp(ten, big.NewInt(int64(n)), nil))
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b, scale := rescale(d, e)
+	return Decimal{coef: a.Add(a, b), scale: scale}.normalize()
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+	a, b, scale := rescale(d, e)
+	return Decimal{coef: a.Sub(a, b), scale: scale}.normalize()
+}
+
+func (d Decimal) Mul(e Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.int(), e.int()), scale: d.scale + e.scale}.normalize()
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than e
+func (d Decimal) Cmp(e Decimal) int {
+	a, b, _ := rescale(d, e)
+	return a.Cmp(b)
+}
+
+func (d Decimal) Equal(e Decimal) bool {
+	return d.Cmp(e) == 0
+}
+
+// Sign returns -1, 0 or +1 as d is negative, zero or positive
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// IsInteger reports whether d has no fractional part
+func (d Decimal) IsInteger() bool {
+	return d.scale <= 0
+}
+
+// Scale returns the number of digits after the decimal point
+func (d Decimal) Scale() int32 {
+	return max(d.scale, 0)
+}
+
+// Round rounds d to places digits after the decimal point, halves away from
+// zero
+func (d Decimal) Round(places int32) Decimal {
+	if d.scale <= places {
+		return d
+	}
+	q, r := new(big.Int).QuoRem(d.int(), shift(big.NewInt(1), d.scale-places), new(big.Int))
+	// |r| >= half of the divisor
+	if r.Abs(r).Mul(r, big.NewInt(2)).Cmp(shift(big.NewInt(1), d.scale-places)) >= 0 {
+		q.Add(q, big.NewInt(int64(d.Sign())))
+	}
+	return Decimal{coef: q, scale: places}.normalize()
+}
+
+// BigInt returns the integer part of d, truncated towards zero
+func (d Decimal) BigInt() *big.Int {
+	if d.scale <= 0 {
+		return shift(d.int(), -d.scale)
+	}
+	return new(big.Int).Quo(d.int(), shift(big.NewInt(1), d.scale))
+}
+
+// String returns d with a dot decimal separator and without trailing zeros,
+// e.g. "-1234567.5"
+func (d Decimal) String() string {
+	if d.scale <= 0 {
+		return shift(d.int(), -d.scale).String()
+	}
+	digits := new(big.Int).Abs(d.int()).String()
+	if pad := int(d.scale) - len(digits) + 1; pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	sign := ""
+	if d.Sign() < 0 {
+		sign = "-"
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON writes d as a string, which JavaScript reads without losing
+// precision
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a decimal string or a JSON number without an exponent
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if err := json.Unmarshal(data, &s); err != nil {
+		var number json.Number
+		if err := json.Unmarshal(data, &number); err != nil {
+			return err
+		}
+		s = number.String()
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,147 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "0", expected: "0"},
+		{input: "-0", expected: "0"},
+		{input: "1234567", expected: "1234567"},
+		{input: "1234567.50", expected: "1234567.5"},
+		{input: "-0.05", expected: "-0.05"},
+		{input: "000120.000", expected: "120"},
+		{input: "99999999999999999999.9999", expected: "99999999999999999999.9999"},
+		{input: "", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: ".5", wantErr: true},
+		{input: "5.", wantErr: true},
+		{input: "+5", wantErr: true},
+		{input: "1,000", wantErr: true},
+		{input: "1e5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d, err := ParseDecimal(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidNumber)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, d.String())
+		})
+	}
+}
+
+func TestDecimal_Arithmetic(t *testing.T) {
+	a, b := MustParseDecimal("1234.5"), MustParseDecimal("0.75")
+
+	assert.Equal(t, "1235.25", a.Add(b).String())
+	assert.Equal(t, "1233.75", a.Sub(b).String())
+	assert.Equal(t, "-1233.75", b.Sub(a).String())
+	assert.Equal(t, "925.875", a.Mul(b).String())
+	assert.Equal(t, "-1234.5", a.Neg().String())
+	assert.Equal(t, "1234.5", a.Neg().Abs().String())
+
+	// exact where float64 is not
+	assert.True(t, MustParseDecimal("0.1").Add(MustParseDecimal("0.2")).Equal(MustParseDecimal("0.3")))
+}
+
+func TestDecimal_Compare(t *testing.T) {
+	assert.Equal(t, 0, MustParseDecimal("1.50").Cmp(MustParseDecimal("1.5")))
+	assert.Equal(t, -1, MustParseDecimal("-2").Cmp(MustParseDecimal("1")))
+	assert.Equal(t, 1, MustParseDecimal("10").Cmp(MustParseDecimal("9.999")))
+	assert.True(t, Decimal{}.IsZero())
+	assert.True(t, Decimal{}.Equal(MustParseDecimal("0.000")))
+	assert.Equal(t, -1, MustParseDecimal("-0.1").Sign())
+}
+
+func TestDecimal_Round(t *testing.T) {
+	tests := []struct {
+		input    string
+		places   int32
+		expected string
+	}{
+		{"1.2345", 2, "1.23"},
+		{"1.235", 2, "1.24"},
+		{"-1.235", 2, "-1.24"},
+		{"1.5", 0, "2"},
+		{"-2.5", 0, "-3"},
+		{"0.4", 0, "0"},
+		{"123", 2, "123"},
+		{"1250", -2, "1300"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, MustParseDecimal(tt.input).Round(tt.places).String(), "%s rounded to %d", tt.input, tt.places)
+	}
+}
+
+func TestDecimal_BigInt(t *testing.T) {
+	assert.Equal(t, "1234", MustParseDecimal("1234.99").BigInt().String())
+	assert.Equal(t, "-1234", MustParseDecimal("-1234.99").BigInt().String())
+	assert.Equal(t, "1200", NewDecimal(12, -2).BigInt().String())
+	assert.True(t, MustParseDecimal("12.000").IsInteger())
+	assert.False(t, MustParseDecimal("12.001").IsInteger())
+	assert.Equal(t, int32(3), MustParseDecimal("12.001").Scale())
+}
+
+func TestNewDecimal(t *testing.T) {
+	assert.Equal(t, "12.5", NewDecimal(125, 1).String())
+	assert.Equal(t, "0.005", NewDecimal(5, 3).String())
+	assert.Equal(t, "-0.5", NewDecimal(-50, 2).String())
+	assert.Equal(t, "1200", NewDecimal(12, -2).String())
+}
+
+func TestDecimal_JSON(t *testing.T) {
+	type line struct {
+		Amount Decimal  `json:"amount"`
+		Price  *Decimal `json:"price,omitempty"`
+	}
+
+	data, err := json.Marshal(line{Amount: MustParseDecimal("1234567.5")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1234567.5"}`, string(data))
+
+	var decoded line
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"1234567.5","price":99.95}`), &decoded))
+	assert.Equal(t, "1234567.5", decoded.Amount.String())
+	require.NotNil(t, decoded.Price)
+	assert.Equal(t, "99.95", decoded.Price.String())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1.234,5"}`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":1e3}`), &decoded))
+}
+
+func FuzzParseDecimal(f *testing.F) {
+	for _, seed := range []string{"0", "-1.5", "1234567.89", "000.100", "1e5", ".", "-"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := ParseDecimal(s)
+		if err != nil {
+			return
+		}
+		// the canonical form reads back as the same number
+		again, err := ParseDecimal(d.String())
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q) error = %v", d.String(), err)
+		}
+		if !again.Equal(d) || again.String() != d.String() {
+			t.Fatalf("%q read back as %q", d.String(), again.String())
+		}
+		if !d.Sub(d).IsZero() || !d.Add(d.Neg()).IsZero() {
+			t.Fatalf("%q minus itself is not zero", s)
+		}
+	})
+}
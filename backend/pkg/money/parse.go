@@ -0,0 +1,213 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidNumber = errors.New("invalid number")
+
+// Locale is how a locale writes numbers. Its separators only decide numbers
+// written either way: "1.234.567,5" and "1,234,567.5" read the same in any
+// locale, "1.500" is 1500 in vi-VN and 1.5 in en-US.
+type Locale struct {
+	Tag     string
+	Group   rune
+	Decimal rune
+}
+
+var (
+	Vietnamese = Locale{Tag: "vi-VN", Group: '.', Decimal: ','}
+	English    = Locale{Tag: "en-US", Group: ',', Decimal: '.'}
+)
+
+// LocaleFor returns the locale numbers in currency are usually written in:
+// Vietnamese for VND and unknown currencies, English otherwise
+func LocaleFor(currency string) Locale {
+	if currency == "" || currency == "VND" {
+		return Vietnamese
+	}
+	return English
+}
+
+// Money is an amount in a currency, an ISO 4217 code that is empty when the
+// text did not say
+type Money struct {
+	Amount   Decimal
+	Currency string
+}
+
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Amount.String()
+	}
+	return m.Amount.String() + " " + m.Currency
+}
+
+// Parse reads an amount written for people, e.g. "1.234.567 đ",
+// "1,234,567 VND" or "$1,980.00", and the currency it is written in. In a
+// currency without minor units such as VND a separator followed by three
+// digits can only group thousands, elsewhere loc decides.
+func Parse(s string, loc Locale) (Money, error) {
+	currency := DetectCurrency(s)
+	amount, err := ParseAmount(s, currency, loc)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// ParseAmount is Parse for amounts known to be in currency unless s names
+// another, such as the bare numbers of an invoice table
+func ParseAmount(s string, currency string, loc Locale) (Decimal, error) {
+	if written := DetectCurrency(s); written != "" {
+		currency = written
+	}
+	return parse(s, loc, MinorUnits(currency) == 0)
+}
+
+// ParseNumber reads a quantity or percentage written for people, e.g. "1,5",
+// "2 cái" or "12.5%". The text around the number is ignored, but there must
+// be exactly one number.
+func ParseNumber(s string, loc Locale) (Decimal, error) {
+	return parse(s, loc, false)
+}
+
+func parse(s string, loc Locale, wholeUnits bool) (Decimal, error) {
+	start, end := numberSpan(s)
+	if start < 0 {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+	if next, _ := numberSpan(s[end:]); next >= 0 {
+		return Decimal{}, fmt.Errorf("%w: more than one number in %q", ErrInvalidNumber, s)
+	}
+
+	var number strings.Builder
+	for _, r := range s[start:end] {
+		if !unicode.IsSpace(r) {
+			number.WriteRune(r)
+		}
+	}
+	whole, fraction, err := splitNumber(number.String(), loc, wholeUnits)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w: %q", err, s)
+	}
+
+	coef, _ := new(big.Int).SetString(whole+fraction, 10)
+	if negative(s[:start], s[end:]) {
+		coef.Neg(coef)
+	}
+	return Decimal{coef: coef, scale: int32(len(fraction))}.normalize(), nil
+}
+
+// numberSpan returns the bounds of the first number in s: digits, dots and
+// commas between digits, and spaces grouping thousands as in "1 234 567".
+// start is -1 when s has no digit.
+func numberSpan(s string) (start, end int) {
+	start = strings.IndexFunc(s, isDigit)
+	if start < 0 {
+		return -1, -1
+	}
+	end = start
+	for i, r := range s[start:] {
+		i += start
+		switch {
+		case isDigit(r):
+			end = i + 1
+		case r == '.' || r == ',':
+			if i+1 >= len(s) || !isDigit(rune(s[i+1])) {
+				return start, end
+			}
+		case unicode.IsSpace(r):
+			if !spaceGroup(s[start:i], s[i+len(string(r)):]) {
+				return start, end
+			}
+		default:
+			return start, end
+		}
+	}
+	return start, end
+}
+
+// spaceGroup reports whether a space between before and after groups
+// thousands: after starts with exactly three digits and before ends with one
+// to three digits grouped the same way
+func spaceGroup(before, after string) bool {
+	if len(after) < 3 || strings.IndexFunc(after[:3], func(r rune) bool { return !isDigit(r) }) >= 0 {
+		return false
+	}
+	if len(after) > 3 && isDigit(rune(after[3])) {
+		return false
+	}
+	last := strings.LastIndexFunc(before, func(r rune) bool { return !isDigit(r) })
+	if last < 0 {
+		return len(before) >= 1 && len(before) <= 3
+	}
+	r, size := utf8.DecodeRuneInString(before[last:])
+	return unicode.IsSpace(r) && len(before)-last-size == 3
+}
+
+// splitNumber splits a number of digits, dots and commas into its whole and
+// fractional digits
+func splitNumber(number string, loc Locale, wholeUnits bool) (whole, fraction string, err error) {
+	dots, commas := strings.Count(number, "."), strings.Count(number, ",")
+	decimal := -1
+	switch {
+	case dots > 0 && commas > 0:
+		// the last separator is the decimal one and must be the only one of
+		// its kind
+		decimal = strings.LastIndexAny(number, ".,")
+		if strings.Count(number, number[decimal:decimal+1]) > 1 {
+			return "", "", ErrInvalidNumber
+		}
+	case dots == 1 || commas == 1:
+		decimal = strings.LastIndexAny(number, ".,")
+		if thousands(number, decimal, loc, wholeUnits) {
+			decimal = -1
+		}
+	}
+
+	whole = number
+	if decimal >= 0 {
+		whole, fraction = number[:decimal], number[decimal+1:]
+	}
+	groups := strings.FieldsFunc(whole, func(r rune) bool { return r == '.' || r == ',' })
+	for i, group := range groups {
+		if i > 0 && len(group) != 3 || i == 0 && len(groups) > 1 && len(group) > 3 {
+			return "", "", ErrInvalidNumber
+		}
+	}
+	return strings.Join(groups, ""), fraction, nil
+}
+
+// thousands reports whether the only separator of number, at i, groups
+// thousands rather than separates decimals
+func thousands(number string, i int, loc Locale, wholeUnits bool) bool {
+	if len(number)-i-1 != 3 || i > 3 || strings.Trim(number[:i], "0") == "" {
+		return false
+	}
+	if wholeUnits {
+		return true
+	}
+	return rune(number[i]) == loc.Group
+}
+
+// negative reports whether the text around a number makes it negative: a
+// minus sign right before it or accounting parentheses around it
+func negative(before, after string) bool {
+	before = strings.TrimRightFunc(before, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Sc, r) || r == 'đ' || r == 'Đ'
+	})
+	if strings.HasSuffix(before, "-") || strings.HasSuffix(before, "−") {
+		return true
+	}
+	return strings.HasSuffix(before, "(") && strings.HasPrefix(strings.TrimSpace(after), ")")
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
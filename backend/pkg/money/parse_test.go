@@ -0,0 +1,207 @@
+package money
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		locale   Locale
+		amount   string
+		currency string
+		wantErr  bool
+	}{
+		{name: "vietnamese dong symbol", input: "1.234.567 đ", locale: Vietnamese, amount: "1234567", currency: "VND"},
+		{name: "vietnamese dong sign", input: "1.234.567₫", locale: Vietnamese, amount: "1234567", currency: "VND"},
+		{name: "vietnamese word", input: "75.600 đồng", locale: Vietnamese, amount: "75600", currency: "VND"},
+		{name: "vietnamese VNĐ", input: "5.600 VNĐ", locale: Vietnamese, amount: "5600", currency: "VND"},
+		{name: "vietnamese decimals", input: "1.234.567,50", locale: Vietnamese, amount: "1234567.5"},
+		{name: "vietnamese single group", input: "1.500", locale: Vietnamese, amount: "1500"},
+		{name: "vietnamese decimal comma", input: "0,5", locale: Vietnamese, amount: "0.5"},
+		{name: "english grouping in vietnamese locale", input: "1,234,567 VND", locale: Vietnamese, amount: "1234567", currency: "VND"},
+		{name: "english", input: "1,234,567.50", locale: English, amount: "1234567.5"},
+		{name: "english single dot", input: "1.500", locale: English, amount: "1.5"},
+		{name: "english single comma", input: "1,500", locale: English, amount: "1500"},
+		{name: "dong has no minor units", input: "50,000 đ", locale: Vietnamese, amount: "50000", currency: "VND"},
+		{name: "dong has no minor units in english", input: "25.000 VND", locale: English, amount: "25000", currency: "VND"},
+		{name: "dollar prefix", input: "$1,980.00", locale: English, amount: "1980", currency: "USD"},
+		{name: "code prefix", input: "USD 1,980.00", locale: Vietnamese, amount: "1980", currency: "USD"},
+		{name: "euro in vietnamese style", input: "1.234,56 €", locale: English, amount: "1234.56", currency: "EUR"},
+		{name: "leading zero", input: "0.500", locale: Vietnamese, amount: "0.5"},
+		{name: "space groups", input: "1 234 567 đ", locale: Vietnamese, amount: "1234567", currency: "VND"},
+		{name: "no-break space groups", input: "1 234 567,5", locale: Vietnamese, amount: "1234567.5"},
+		{name: "negative", input: "-20.000", locale: Vietnamese, amount: "-20000"},
+		{name: "negative after symbol", input: "-$5.25", locale: English, amount: "-5.25", currency: "USD"},
+		{name: "accounting negative", input: "(20.000)", locale: Vietnamese, amount: "-20000"},
+		{name: "hyphenated text is not a sign", input: "TM-CK 20.000", locale: Vietnamese, amount: "20000"},
+		{name: "zeros", input: "000", locale: Vietnamese, amount: "0"},
+		{name: "trailing separator", input: "1.000.", locale: Vietnamese, amount: "1000"},
+		{name: "too many digits before a group", input: "1234.567 VND", locale: Vietnamese, amount: "1234.567", currency: "VND"},
+		{name: "bad groups", input: "1.23.456", locale: Vietnamese, wantErr: true},
+		{name: "two decimal separators", input: "1,234.567.8", locale: English, wantErr: true},
+		{name: "no number", input: "TM/CK", locale: Vietnamese, wantErr: true},
+		{name: "words", input: "Bảy mươi lăm nghìn đồng", locale: Vietnamese, wantErr: true},
+		{name: "date", input: "15/03/2024", locale: Vietnamese, wantErr: true},
+		{name: "two amounts", input: "2 25.000", locale: Vietnamese, wantErr: true},
+		{name: "empty", input: "", locale: Vietnamese, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.input, tt.locale)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidNumber)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.amount, m.Amount.String())
+			assert.Equal(t, tt.currency, m.Currency)
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		locale   Locale
+		expected string
+	}{
+		{input: "50,000", currency: "VND", locale: Vietnamese, expected: "50000"},
+		{input: "50,000", currency: "", locale: Vietnamese, expected: "50"},
+		{input: "1.500", currency: "USD", locale: English, expected: "1.5"},
+		{input: "1.500 đ", currency: "USD", locale: English, expected: "1500"},
+		{input: "1,25", currency: "VND", locale: Vietnamese, expected: "1.25"},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseAmount(tt.input, tt.currency, tt.locale)
+		if assert.NoError(t, err, tt.input) {
+			assert.Equal(t, tt.expected, d.String(), "%s in %s", tt.input, tt.currency)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		input    string
+		locale   Locale
+		expected string
+		wantErr  bool
+	}{
+		{input: "12.5%", locale: Vietnamese, expected: "12.5"},
+		{input: "10 %", locale: Vietnamese, expected: "10"},
+		{input: "8,5%", locale: English, expected: "8.5"},
+		{input: "2 cái", locale: Vietnamese, expected: "2"},
+		{input: "1,5 kg", locale: Vietnamese, expected: "1.5"},
+		{input: "1.5", locale: English, expected: "1.5"},
+		{input: "1.500", locale: Vietnamese, expected: "1500"},
+		{input: "1.500", locale: English, expected: "1.5"},
+		{input: "KCT", locale: Vietnamese, wantErr: true},
+		{input: "5-10%", locale: Vietnamese, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseNumber(tt.input, tt.locale)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, ErrInvalidNumber, tt.input)
+			continue
+		}
+		if assert.NoError(t, err, tt.input) {
+			assert.Equal(t, tt.expected, d.String(), tt.input)
+		}
+	}
+}
+
+func TestDetectCurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"75.600 đ", "VND"},
+		{"75.600 VNĐ", "VND"},
+		{"vnd", "VND"},
+		{"Đồng Việt Nam", "VND"},
+		{"usd", "USD"},
+		{"US$ 10", "USD"},
+		{"S$ 10", "SGD"},
+		{"$10", "USD"},
+		{"10 €", "EUR"},
+		{"¥1000", "JPY"},
+		{"1.000", ""},
+		{"Dollar", ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, DetectCurrency(tt.input), tt.input)
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	assert.Equal(t, 0, MinorUnits("VND"))
+	assert.Equal(t, 2, MinorUnits("USD"))
+	assert.Equal(t, 2, MinorUnits(""))
+}
+
+func TestLocaleFor(t *testing.T) {
+	assert.Equal(t, Vietnamese, LocaleFor("VND"))
+	assert.Equal(t, Vietnamese, LocaleFor(""))
+	assert.Equal(t, English, LocaleFor("USD"))
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"1.234.567 đ", "1,234,567 VND", "12.5%", "$1,980.00", "1.234.567,50",
+		"(20.000)", "1 234 567", "0,5", "1.23.456", "TM/CK", "-", "1.000.",
+	} {
+		f.Add(seed, true)
+	}
+	f.Fuzz(func(t *testing.T, s string, vietnamese bool) {
+		loc := English
+		if vietnamese {
+			loc = Vietnamese
+		}
+		m, err := Parse(s, loc)
+		if err != nil {
+			return
+		}
+		// an amount parsed from text reads back from both locales' formatting
+		for _, l := range []Locale{Vietnamese, English} {
+			written := format(m.Amount, l)
+			again, err := Parse(written, l)
+			if err != nil {
+				t.Fatalf("Parse(%q) of %q error = %v", written, s, err)
+			}
+			if !again.Amount.Equal(m.Amount) {
+				t.Fatalf("%q parsed as %v, written as %q in %s parsed as %v", s, m.Amount, written, l.Tag, again.Amount)
+			}
+		}
+	})
+}
+
+// format writes d the way loc groups and separates numbers
+func format(d Decimal, loc Locale) string {
+	whole, fraction, _ := strings.Cut(d.String(), ".")
+	sign := ""
+	if strings.HasPrefix(whole, "-") {
+		sign, whole = "-", whole[1:]
+	}
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteRune(loc.Group)
+		}
+		b.WriteRune(r)
+	}
+	if fraction != "" {
+		b.WriteRune(loc.Decimal)
+		b.WriteString(fraction)
+	}
+	return sign + b.String()
+}
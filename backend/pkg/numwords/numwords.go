@@ -0,0 +1,264 @@
+// Package numwords reads and writes whole numbers in Vietnamese words, the way
+// invoices print their total: 1200000 is "một triệu hai trăm nghìn". Above a
+// billion (tỷ) the scales repeat, 10^12 is "một nghìn tỷ".
+package numwords
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrSyntax = errors.New("not a number in Vietnamese words")
+	ErrRange  = errors.New("number in words out of range")
+)
+
+const billion = 1_000_000_000
+
+var digits = [10]string{"không", "một", "hai", "ba", "bốn", "năm", "sáu", "bảy", "tám", "chín"}
+
+// digitWords are the digits with their spellings after mươi and mười, and
+// common variants
+var digitWords = map[string]uint64{
+	"không": 0, "một": 1, "mốt": 1, "hai": 2, "ba": 3, "bốn": 4, "tư": 4,
+	"năm": 5, "lăm": 5, "nhăm": 5, "sáu": 6, "bảy": 7, "bẩy": 7, "tám": 8, "chín": 9,
+}
+
+var scaleWords = map[string]uint64{
+	"nghìn": 1_000, "ngàn": 1_000, "triệu": 1_000_000, "tỷ": billion, "tỉ": billion,
+}
+
+// currencyWords end the number, "đồng chẵn" and "Việt Nam đồng" are ignored
+var currencyWords = map[string]bool{
+	"đồng": true, "việt": true, "vnđ": true, "vnd": true, "chẵn": true,
+}
+
+// Format writes n in words, in lower case and without a currency
+func Format(n int64) string {
+	if n == 0 {
+		return digits[0]
+	}
+	var words []string
+	abs := uint64(n)
+	if n < 0 {
+		words = append(words, "âm")
+		abs = uint64(-n)
+	}
+	return strings.Join(appendNumber(words, abs, false), " ")
+}
+
+// appendNumber appends the words of n > 0. Groups of three digits after the
+// first are read in full, so the 005 of 1005 is "không trăm lẻ năm".
+func appendNumber(words []string, n uint64, full bool) []string {
+	if n >= billion {
+		words = append(appendNumber(words, n/billion, full), "tỷ")
+		if n%billion == 0 {
+			return words
+		}
+		return appendNumber(words, n%billion, true)
+	}
+	for _, scale := range []uint64{1_000_000, 1_000, 1} {
+		group := n / scale % 1000
+		if group == 0 {
+			continue
+		}
+		words = appendGroup(words, group, full)
+		switch scale {
+		case 1_000_000:
+			words = append(words, "triệu")
+		case 1_000:
+			words = append(words, "nghìn")
+		}
+		full = true
+	}
+	return words
+}
+
+// appendGroup appends the words of a group of three digits
+func appendGroup(words []string, group uint64, full bool) []string {
+	hundreds, tens, units := group/100, group/10%10, group%10
+	if full || hundreds > 0 {
+		words = append(words, digits[hundreds], "trăm")
+	}
+	switch {
+	case tens == 0 && units > 0 && (full || hundreds > 0):
+		words = append(words, "lẻ")
+	case tens == 1:
+		words = append(words, "mười")
+	case tens > 1:
+		words = append(words, digits[tens], "mươi")
+	}
+	switch {
+	case units == 0:
+	case units == 1 && tens > 1:
+		words = append(words, "mốt")
+	case units == 4 && tens > 1:
+		words = append(words, "tư")
+	case units == 5 && tens > 0:
+		words = append(words, "lăm")
+	default:
+		words = append(words, digits[units])
+	}
+	return words
+}
+
+// Parse reads a number written in words, in any case and with or without
+// punctuation, such as "Một triệu hai trăm nghìn đồng chẵn./.". The words
+// from a currency on are ignored. Words that are not part of a number, or
+// are out of order, return ErrSyntax.
+func Parse(s string) (int64, error) {
+	words := strings.FieldsFunc(norm.NFC.String(strings.ToLower(s)), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, word := range words {
+		if currencyWords[word] {
+			words = words[:i]
+			break
+		}
+	}
+	negative := len(words) > 0 && words[0] == "âm"
+	if negative {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return 0, ErrSyntax
+	}
+
+	p := parser{digit: -1}
+	for _, word := range words {
+		if err := p.read(word); err != nil {
+			return 0, err
+		}
+	}
+	n, err := p.end()
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		return -n, nil
+	}
+	return n, nil
+}
+
+// scaled is the value of the words read before a scale word, multiplied by
+// it, and the scale of the words, 10^12 for "nghìn tỷ"
+type scaled struct {
+	value, scale uint64
+}
+
+// parser reads a number a group of three digits at a time. A scale word
+// multiplies the group before it and the scales before that up to its own,
+// so "hai nghìn ba trăm tỷ" is 2300 × 10^9 and "chín tỷ hai trăm tỷ" is
+// 9000000200 × 10^9. Right after another scale it multiplies that scale, as
+// in "một nghìn tỷ".
+type parser struct {
+	parts []scaled
+	// group is the value of the group read so far and digit the digit read
+	// last, -1 for none
+	group uint64
+	digit int
+	// stage is 0 at the start of a group, 1 after the hundreds and 2 after
+	// the tens
+	stage int
+}
+
+func (p *parser) read(word string) error {
+	if d, ok := digitWords[word]; ok {
+		if p.digit >= 0 {
+			return ErrSyntax
+		}
+		p.digit = int(d)
+		return nil
+	}
+	if scale, ok := scaleWords[word]; ok {
+		return p.scale(scale)
+	}
+
+	switch word {
+	case "trăm":
+		if p.digit < 0 || p.stage >= 1 {
+			return ErrSyntax
+		}
+		p.group += uint64(p.digit) * 100
+		p.stage = 1
+	case "mươi":
+		if p.digit < 1 || p.stage >= 2 {
+			return ErrSyntax
+		}
+		p.group += uint64(p.digit) * 10
+		p.stage = 2
+	case "mười":
+		if p.digit >= 0 || p.stage >= 2 {
+			return ErrSyntax
+		}
+		p.group += 10
+		p.stage = 2
+	case "lẻ", "linh":
+		// no tens, after the hundreds or a scale as in "một nghìn lẻ năm"
+		if p.digit >= 0 || p.stage >= 2 || (p.stage == 0 && len(p.parts) == 0) {
+			return ErrSyntax
+		}
+		p.stage = 2
+	default:
+		return ErrSyntax
+	}
+	p.digit = -1
+	return nil
+}
+
+// closeGroup adds the last digit to the group, reporting whether the group
+// has no words
+func (p *parser) closeGroup() bool {
+	empty := p.digit < 0 && p.stage == 0
+	if p.digit >= 0 {
+		p.group += uint64(p.digit)
+	}
+	return empty
+}
+
+func (p *parser) scale(scale uint64) error {
+	empty := p.closeGroup()
+	value, unit := p.group, scale
+	if empty {
+		// a scale of a scale, as in "một nghìn tỷ" or "một tỷ tỷ"
+		if len(p.parts) == 0 {
+			return ErrSyntax
+		}
+		last := p.parts[len(p.parts)-1]
+		if last.scale > math.MaxInt64/scale {
+			return ErrRange
+		}
+		p.parts = p.parts[:len(p.parts)-1]
+		value, unit = last.value, last.scale*scale
+	}
+	for len(p.parts) > 0 && p.parts[len(p.parts)-1].scale <= unit {
+		last := p.parts[len(p.parts)-1]
+		if value > math.MaxInt64-last.value {
+			return ErrRange
+		}
+		value += last.value
+		p.parts = p.parts[:len(p.parts)-1]
+	}
+	if value > math.MaxInt64/scale {
+		return ErrRange
+	}
+	p.parts = append(p.parts, scaled{value: value * scale, scale: unit})
+	p.group, p.digit, p.stage = 0, -1, 0
+	return nil
+}
+
+func (p *parser) end() (int64, error) {
+	p.closeGroup()
+	total := p.group
+	for _, part := range p.parts {
+		if total > math.MaxInt64-part.value {
+			return 0, ErrRange
+		}
+		total += part.value
+	}
+	return int64(total), nil
+}
@@ -0,0 +1,135 @@
+package numwords
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var spelled = []struct {
+	n     int64
+	words string
+}{
+	{0, "không"},
+	{5, "năm"},
+	{10, "mười"},
+	{11, "mười một"},
+	{15, "mười lăm"},
+	{21, "hai mươi mốt"},
+	{24, "hai mươi tư"},
+	{25, "hai mươi lăm"},
+	{100, "một trăm"},
+	{105, "một trăm lẻ năm"},
+	{110, "một trăm mười"},
+	{999, "chín trăm chín mươi chín"},
+	{1_005, "một nghìn không trăm lẻ năm"},
+	{1_050, "một nghìn không trăm năm mươi"},
+	{15_000, "mười lăm nghìn"},
+	{75_600, "bảy mươi lăm nghìn sáu trăm"},
+	{1_200_000, "một triệu hai trăm nghìn"},
+	{1_005_000, "một triệu không trăm lẻ năm nghìn"},
+	{20_000_001, "hai mươi triệu không trăm lẻ một"},
+	{1_000_000_000, "một tỷ"},
+	{3_250_000_000, "ba tỷ hai trăm năm mươi triệu"},
+	{1_000_000_000_000, "một nghìn tỷ"},
+	{2_300_000_005_000, "hai nghìn ba trăm tỷ không trăm lẻ năm nghìn"},
+	{1_000_000_000_000_000_000, "một tỷ tỷ"},
+	{-41, "âm bốn mươi mốt"},
+}
+
+func TestFormat(t *testing.T) {
+	for _, tt := range spelled {
+		assert.Equal(t, tt.words, Format(tt.n), tt.n)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range spelled {
+		n, err := Parse(tt.words)
+		require.NoError(t, err, tt.words)
+		assert.Equal(t, tt.n, n, tt.words)
+	}
+}
+
+func TestParse_Variants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{input: "Một triệu hai trăm nghìn đồng", expected: 1_200_000},
+		{input: "Một triệu hai trăm nghìn đồng chẵn./.", expected: 1_200_000},
+		{input: "Bảy mươi lăm nghìn sáu trăm Việt Nam đồng", expected: 75_600},
+		{input: "MỘT TRĂM LINH NĂM NGÀN ĐỒNG", expected: 105_000},
+		{input: "hai mươi bốn", expected: 24},
+		{input: "hai mươi nhăm", expected: 25},
+		{input: "bẩy tỉ", expected: 7_000_000_000},
+		{input: "một nghìn lẻ năm", expected: 1_005},
+		{input: "một nghìn triệu", expected: 1_000_000_000},
+		// decomposed diacritics
+		{input: "mo\u0323\u0302t trie\u0302\u0323u", expected: 1_000_000},
+	}
+
+	for _, tt := range tests {
+		n, err := Parse(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expected, n, tt.input)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{input: "", err: ErrSyntax},
+		{input: "đồng", err: ErrSyntax},
+		{input: "1.200.000 đồng", err: ErrSyntax},
+		{input: "one hundred dollars", err: ErrSyntax},
+		{input: "hai ba", err: ErrSyntax},
+		{input: "triệu", err: ErrSyntax},
+		{input: "trăm", err: ErrSyntax},
+		{input: "một trăm hai trăm", err: ErrSyntax},
+		{input: "hai mươi mười", err: ErrSyntax},
+		{input: "lẻ năm", err: ErrSyntax},
+		{input: "mươi", err: ErrSyntax},
+		{input: "mười tỷ tỷ", err: ErrRange},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		assert.ErrorIs(t, err, tt.err, tt.input)
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	for _, tt := range spelled {
+		f.Add(tt.n)
+	}
+	f.Add(int64(math.MaxInt64))
+	f.Fuzz(func(t *testing.T, n int64) {
+		if n == math.MinInt64 {
+			return
+		}
+		parsed, err := Parse(Format(n))
+		require.NoError(t, err, Format(n))
+		assert.Equal(t, n, parsed, Format(n))
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, tt := range spelled {
+		f.Add(tt.words)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		// any number read is written back to one that reads the same
+		n, err := Parse(s)
+		if err != nil {
+			return
+		}
+		parsed, err := Parse(Format(n))
+		require.NoError(t, err)
+		assert.Equal(t, n, parsed)
+	})
+}
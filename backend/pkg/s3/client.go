@@ -0,0 +1,248 @@
+// Package s3 is a small client for S3-compatible object storage, such as AWS
+// S3 and MinIO, covering the calls the file storage needs: single and
+// multipart uploads, downloads, deletes and presigned downloads.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload, save for
+// the last one
+const MinPartSize = 5 << 20
+
+// ErrNotFound is matched by the errors of requests for objects that do not
+// exist
+var ErrNotFound = errors.New("object not found")
+
+// Error is an error response of the storage
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && (e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey")
+}
+
+type Config struct {
+	// Endpoint is the base URL of the storage, e.g. https://s3.amazonaws.com
+	// or http://localhost:9000
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket in the path rather than as a subdomain
+	// of the endpoint, as MinIO expects
+	PathStyle  bool
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	endpoint   *url.URL
+	bucket     string
+	pathStyle  bool
+	signer     signer
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func New(cfg Config) (*Client, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 access key and secret key are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	return &Client{
+		endpoint:   endpoint,
+		bucket:     cfg.Bucket,
+		pathStyle:  cfg.PathStyle,
+		signer:     signer{accessKey: cfg.AccessKey, secretKey: cfg.SecretKey, region: region},
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+func (c *Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	_, _, err := c.do(ctx, http.MethodPut, key, nil, data, header)
+	return err
+}
+
+func (c *Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	return body, err
+}
+
+// DeleteObject removes the object at key. Deleting a missing object succeeds.
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	_, _, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	return err
+}
+
+// PutObjectMultipart uploads data in parts of partSize bytes, so a failed
+// part is retried alone rather than with the whole object. The upload is
+// aborted when a part fails, leaving no parts behind in the bucket.
+func (c *Client) PutObjectMultipart(ctx context.Context, key string, data []byte, contentType string, partSize int) error {
+	if partSize < MinPartSize {
+		return fmt.Errorf("s3 part size must be at least %d bytes", MinPartSize)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	body, _, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &created); err != nil || created.UploadID == "" {
+		return fmt.Errorf("failed to create multipart upload: invalid response: %s", body)
+	}
+
+	if err := c.uploadParts(ctx, key, created.UploadID, data, partSize); err != nil {
+		abort := url.Values{"uploadId": {created.UploadID}}
+		if _, _, abortErr := c.do(context.WithoutCancel(ctx), http.MethodDelete, key, abort, nil, nil); abortErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to abort multipart upload: %w", abortErr))
+		}
+		return err
+	}
+	return nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (c *Client) uploadParts(ctx context.Context, key, uploadID string, data []byte, partSize int) error {
+	var parts []completedPart
+	for offset := 0; offset < len(data) || len(parts) == 0; offset += partSize {
+		end := min(offset+partSize, len(data))
+		number := len(parts) + 1
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		_, header, err := c.do(ctx, http.MethodPut, key, query, data[offset:end], nil)
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		parts = append(parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	body, _, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, complete, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	// the storage answers 200 before assembling the parts, and reports a
+	// failure to assemble them in the body
+	if s3Err := parseError(http.StatusOK, body); s3Err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", s3Err)
+	}
+	return nil
+}
+
+// PresignGetObject returns a URL anyone can download the object at key with,
+// from signedAt until expires later
+func (c *Client) PresignGetObject(key string, signedAt time.Time, expires time.Duration) string {
+	return c.signer.presign(http.MethodGet, c.objectURL(key, nil), expires, signedAt).String()
+}
+
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	path := "/" + strings.TrimPrefix(key, "/")
+	if c.pathStyle {
+		path = "/" + c.bucket + path
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + path
+	// send the path encoded exactly as it is signed
+	u.RawPath = canonicalURI(&u)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do sends a signed request for the object at key and returns the body and
+// headers of a successful response
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.signer.signHeaders(req, sha256Hex(body), c.now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("s3 request failed: failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if s3Err := parseError(resp.StatusCode, respBody); s3Err != nil {
+			return nil, nil, s3Err
+		}
+		return nil, nil, &Error{StatusCode: resp.StatusCode}
+	}
+	return respBody, resp.Header, nil
+}
+
+// parseError returns the error in an XML response body, if any
+func parseError(statusCode int, body []byte) *Error {
+	if !bytes.Contains(body, []byte("<Error>")) {
+		return nil
+	}
+	s3Err := &Error{StatusCode: statusCode}
+	if err := xml.Unmarshal(body, s3Err); err != nil {
+		return nil
+	}
+	return s3Err
+}
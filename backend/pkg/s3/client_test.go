@@ -0,0 +1,218 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps the objects of a path-style bucket in memory
+type fakeS3 struct {
+	mu       sync.Mutex
+	bucket   string
+	objects  map[string][]byte
+	types    map[string]string
+	uploads  map[string]map[int][]byte
+	failPart int
+	aborted  int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *Client) {
+	fake := &fakeS3{
+		bucket:  "invoices",
+		objects: map[string][]byte{},
+		types:   map[string]string{},
+		uploads: map[string]map[int][]byte{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := New(Config{
+		Endpoint:  server.URL,
+		Bucket:    fake.bucket,
+		AccessKey: "minioadmin",
+		SecretKey: "minioadmin",
+		PathStyle: true,
+	})
+	require.NoError(t, err)
+	return fake, client
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), signAlgorithm+" Credential=minioadmin/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		f.error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[uploadID] = map[int][]byte{}
+		f.types[key] = r.Header.Get("Content-Type")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == http.MethodPut && uploadID != "":
+		var number int
+		fmt.Sscan(query.Get("partNumber"), &number)
+		if number == f.failPart {
+			f.error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		f.uploads[uploadID][number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && uploadID != "":
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			f.error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var object []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				// reported in a successful response, as S3 does
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>bad part</Message></Error>")
+				return
+			}
+			object = append(object, f.uploads[uploadID][part.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, uploadID)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && uploadID != "":
+		f.aborted++
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, http.StatusText(status))
+}
+
+func TestNew_Errors(t *testing.T) {
+	_, err := New(Config{Endpoint: "http://localhost:9000", AccessKey: "key", SecretKey: "secret"})
+	assert.ErrorContains(t, err, "bucket is required")
+
+	_, err = New(Config{Endpoint: "http://localhost:9000", Bucket: "invoices"})
+	assert.ErrorContains(t, err, "access key and secret key are required")
+
+	_, err = New(Config{Endpoint: "localhost:9000", Bucket: "invoices", AccessKey: "key", SecretKey: "secret"})
+	assert.ErrorContains(t, err, "invalid s3 endpoint")
+}
+
+func TestClient_Objects(t *testing.T) {
+	fake, client := newFakeS3(t)
+	ctx := context.Background()
+
+	key := "uploads/hóa đơn 1.jpg"
+	require.NoError(t, client.PutObject(ctx, key, []byte("image data"), "image/jpeg"))
+	assert.Equal(t, "image/jpeg", fake.types[key])
+
+	data, err := client.GetObject(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image data"), data)
+
+	require.NoError(t, client.DeleteObject(ctx, key))
+	_, err = client.GetObject(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+	var s3Err *Error
+	require.ErrorAs(t, err, &s3Err)
+	assert.Equal(t, "NoSuchKey", s3Err.Code)
+
+	// deleting a missing object is not an error
+	assert.NoError(t, client.DeleteObject(ctx, key))
+}
+
+func TestClient_PutObjectMultipart(t *testing.T) {
+	fake, client := newFakeS3(t)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789"), MinPartSize/10*2+7)
+	require.NoError(t, client.PutObjectMultipart(ctx, "uploads/scan.pdf", data, "application/pdf", MinPartSize))
+
+	assert.Equal(t, data, fake.objects["uploads/scan.pdf"])
+	assert.Equal(t, "application/pdf", fake.types["uploads/scan.pdf"])
+	assert.Empty(t, fake.uploads)
+
+	err := client.PutObjectMultipart(ctx, "uploads/scan.pdf", data, "application/pdf", MinPartSize-1)
+	assert.ErrorContains(t, err, "part size must be at least")
+}
+
+func TestClient_PutObjectMultipart_Abort(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.failPart = 2
+
+	data := bytes.Repeat([]byte{1}, MinPartSize*3)
+	err := client.PutObjectMultipart(context.Background(), "uploads/scan.pdf", data, "application/pdf", MinPartSize)
+
+	assert.ErrorContains(t, err, "failed to upload part 2")
+	assert.ErrorContains(t, err, "InternalError")
+	assert.Equal(t, 1, fake.aborted)
+	assert.Empty(t, fake.uploads)
+	assert.NotContains(t, fake.objects, "uploads/scan.pdf")
+}
+
+func TestClient_PresignGetObject(t *testing.T) {
+	client, err := New(Config{
+		Endpoint:  "https://s3.amazonaws.com",
+		Region:    "ap-southeast-1",
+		Bucket:    "invoices",
+		AccessKey: "key",
+		SecretKey: "secret",
+	})
+	require.NoError(t, err)
+
+	signedAt := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	presigned, err := url.Parse(client.PresignGetObject("uploads/a b.jpg", signedAt, time.Hour))
+	require.NoError(t, err)
+
+	assert.Equal(t, "invoices.s3.amazonaws.com", presigned.Host)
+	assert.Equal(t, "/uploads/a%20b.jpg", presigned.EscapedPath())
+	query := presigned.Query()
+	assert.Equal(t, "key/20261016/ap-southeast-1/s3/aws4_request", query.Get("X-Amz-Credential"))
+	assert.Equal(t, "20261016T090000Z", query.Get("X-Amz-Date"))
+	assert.Equal(t, "3600", query.Get("X-Amz-Expires"))
+	assert.Len(t, query.Get("X-Amz-Signature"), 64)
+}
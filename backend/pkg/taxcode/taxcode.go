@@ -0,0 +1,66 @@
+// Package taxcode validates Vietnamese tax codes (mã số thuế, MST). A tax code
+// is 10 digits, the last a check digit, or 13 digits for a branch: the code of
+// the parent company followed by a three digit branch number, usually written
+// 0100109106-001.
+package taxcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrFormat   = errors.New("tax code must have 10 or 13 digits")
+	ErrChecksum = errors.New("tax code check digit does not match")
+	ErrBranch   = errors.New("tax code branch must not be 000")
+)
+
+// weights of the first nine digits in the check digit
+var weights = [9]int{31, 29, 23, 19, 17, 13, 7, 5, 3}
+
+// Parse reads a tax code written with spaces, dots or a dash between its
+// digits, and returns it as 10 digits, or 10 digits, a dash and the branch
+func Parse(s string) (string, error) {
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '.' || r == '-':
+		default:
+			return "", ErrFormat
+		}
+	}
+
+	code := digits.String()
+	if len(code) != 10 && len(code) != 13 {
+		return "", ErrFormat
+	}
+	if code[9]-'0' != checkDigit(code[:9]) {
+		return "", ErrChecksum
+	}
+	if len(code) == 13 {
+		if code[10:] == "000" {
+			return "", ErrBranch
+		}
+		return code[:10] + "-" + code[10:], nil
+	}
+	return code, nil
+}
+
+// Valid reports whether s is a tax code Parse accepts
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// checkDigit returns the check digit of the first nine digits of a tax code:
+// ten minus their weighted sum modulo 11. Codes whose sum is a multiple of 11
+// have no check digit and are not issued, 10 never matches a digit.
+func checkDigit(digits string) byte {
+	sum := 0
+	for i, weight := range weights {
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte(10 - sum%11)
+}
@@ -0,0 +1,66 @@
+package taxcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      error
+	}{
+		{name: "company", input: "0100109106", expected: "0100109106"},
+		{name: "branch with dash", input: "0100109106-001", expected: "0100109106-001"},
+		{name: "branch without dash", input: "0100109106001", expected: "0100109106-001"},
+		{name: "spaces", input: " 0312 345 673 ", expected: "0312345673"},
+		{name: "dots", input: "0301.447.899", expected: "0301447899"},
+		{name: "check digit 0", input: "0100233939", expected: "0100233939"},
+		{name: "one digit misread", input: "0100109108", err: ErrChecksum},
+		{name: "swapped digits", input: "0101009106", err: ErrChecksum},
+		{name: "no check digit", input: "0310123450", err: ErrChecksum},
+		{name: "branch of an invalid code", input: "0100109107-001", err: ErrChecksum},
+		{name: "branch 000", input: "0100109106-000", err: ErrBranch},
+		{name: "too short", input: "010010910", err: ErrFormat},
+		{name: "too long", input: "01001091060012", err: ErrFormat},
+		{name: "letters", input: "01001O9106", err: ErrFormat},
+		{name: "empty", input: "", err: ErrFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Parse(tt.input)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Empty(t, code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, code)
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("0100109106"))
+	assert.True(t, Valid("0100109106-001"))
+	assert.False(t, Valid("0100109105"))
+	assert.False(t, Valid("MST"))
+}
+
+func TestCheckDigit_AllSingleDigitErrors(t *testing.T) {
+	// the weights are distinct modulo 11, so any one misread digit is caught
+	code := "0100109106"
+	for i := 0; i < 9; i++ {
+		for d := byte('0'); d <= '9'; d++ {
+			if d == code[i] {
+				continue
+			}
+			misread := code[:i] + string(d) + code[i+1:]
+			assert.False(t, Valid(misread), misread)
+		}
+	}
+}
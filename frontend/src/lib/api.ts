@@ -15,14 +15,18 @@ function getImageUrl(imagePath: string | null | undefined): string | null {
     return imagePath;
   }
 
-  if (imagePath.startsWith('/')) {
+  if (imagePath.startsWith('/') || /^https?:\/\//.test(imagePath)) {
     return imagePath;
   }
 
   return `/${imagePath}`;
 }
 
-export { getImageUrl };
+function isPdfPath(path: string | null | undefined): boolean {
+  return !!path && (path.startsWith('data:application/pdf') || path.toLowerCase().split('?')[0].endsWith('.pdf'));
+}
+
+export { getImageUrl, isPdfPath };
 
 class APIClient {
   private baseURL: string;
@@ -18,17 +18,40 @@ import {
   AlertTriangle
 } from 'lucide-react';
 import { useAppStore } from '@/stores/app-store';
-import { apiClient, getImageUrl } from '@/lib/api';
-import type { ExtractedData, InvoiceData } from '@/types';
+import { apiClient, getImageUrl, isPdfPath } from '@/lib/api';
+import type { BoundingBox, ExtractedData, Finding, InvoiceData, InvoiceStatus } from '@/types';
+
+// extracted invoices are shown whether or not their numbers need review
+function isExtracted(status: InvoiceStatus) {
+  return status === 'completed' || status === 'needs_review';
+}
+
+function describeFinding(finding: Finding) {
+  switch (finding.code) {
+    case 'line_amount_mismatch':
+      return `Dòng ${finding.row}: số lượng × đơn giá là ${finding.expected}, không phải ${finding.actual}`;
+    case 'subtotal_mismatch':
+      return `Tổng các dòng là ${finding.expected}, không khớp cộng tiền hàng ${finding.actual}`;
+    case 'total_mismatch':
+      return `Cộng tiền hàng và thuế là ${finding.expected}, không khớp tổng cộng ${finding.actual}`;
+    case 'invalid_vat_rate':
+      return `Thuế suất ${finding.actual}% không phải 0%, 5%, 8% hoặc 10%`;
+    case 'amount_in_words_mismatch':
+      return `Số tiền bằng chữ là ${finding.expected}, không khớp tổng cộng ${finding.actual}`;
+    default:
+      return finding.message;
+  }
+}
 
 interface AutoExpandTextareaProps {
   value: string;
   onChange: (value: string) => void;
   className?: string;
   placeholder?: string;
+  onFocus?: () => void;
 }
 
-function AutoExpandTextarea({ value, onChange, className = '', placeholder }: AutoExpandTextareaProps) {
+function AutoExpandTextarea({ value, onChange, className = '', placeholder, onFocus }: AutoExpandTextareaProps) {
   const textareaRef = useRef<HTMLTextAreaElement>(null);
 
   const adjustHeight = useCallback(() => {
@@ -48,6 +71,7 @@ function AutoExpandTextarea({ value, onChange, className = '', placeholder }: Au
       ref={textareaRef}
       value={value}
       onChange={(e) => onChange(e.target.value)}
+      onFocus={onFocus}
       placeholder={placeholder}
       rows={1}
       className={`w-full resize-none overflow-hidden bg-surface-50 dark:bg-surface-800 
@@ -64,10 +88,12 @@ interface LineItemCardProps {
   row: string[];
   headers: string[];
   rowIndex: number;
+  cellBoxes?: (BoundingBox | null)[];
   onCellChange: (rowIndex: number, cellIndex: number, value: string) => void;
+  onCellFocus: (box?: BoundingBox | null) => void;
 }
 
-function LineItemCard({ row, headers, rowIndex, onCellChange }: LineItemCardProps) {
+function LineItemCard({ row, headers, rowIndex, cellBoxes, onCellChange, onCellFocus }: LineItemCardProps) {
   const [isExpanded, setIsExpanded] = useState(false);
   const primaryField = row[0] || `Mục ${rowIndex + 1}`;
 
@@ -109,6 +135,7 @@ function LineItemCard({ row, headers, rowIndex, onCellChange }: LineItemCardProp
               <AutoExpandTextarea
                 value={row[cellIndex] || ''}
                 onChange={(value) => onCellChange(rowIndex, cellIndex, value)}
+                onFocus={() => onCellFocus(cellBoxes?.[cellIndex])}
               />
             </div>
           ))}
@@ -118,6 +145,35 @@ function LineItemCard({ row, headers, rowIndex, onCellChange }: LineItemCardProp
   );
 }
 
+interface HighlightedImageProps {
+  src: string;
+  box: BoundingBox | null;
+}
+
+// Shows the image with the region of the selected field outlined
+function HighlightedImage({ src, box }: HighlightedImageProps) {
+  return (
+    <div className="relative mx-auto w-fit">
+      <img
+        src={src}
+        alt="Hóa đơn"
+        className="block w-auto h-auto max-w-full max-h-52 object-contain"
+      />
+      {box && (
+        <div
+          className="absolute rounded-sm border-2 border-warning-500 bg-warning-500/20 pointer-events-none"
+          style={{
+            left: `${box.x * 100}%`,
+            top: `${box.y * 100}%`,
+            width: `${box.width * 100}%`,
+            height: `${box.height * 100}%`,
+          }}
+        />
+      )}
+    </div>
+  );
+}
+
 function convertExtractedDataToInvoiceData(extractedData: ExtractedData): InvoiceData {
   return {
     keyValuePairs: extractedData.key_value_pairs || [],
@@ -186,6 +242,7 @@ export default function ExtractInvoiceDataPage() {
   const [showConfirmDialog, setShowConfirmDialog] = useState(false);
   const [saveError, setSaveError] = useState<string | null>(null);
   const [saveSuccess, setSaveSuccess] = useState(false);
+  const [activeBox, setActiveBox] = useState<BoundingBox | null>(null);
   const {
     currentImage,
     selectedInvoiceId,
@@ -219,7 +276,7 @@ export default function ExtractInvoiceDataPage() {
       const data = query.state.data;
       if (!data?.data) return false;
       const status = data.data.status;
-      if (status === 'completed' || status === 'failed') {
+      if (status === 'completed' || status === 'needs_review' || status === 'failed') {
         return false;
       }
       return 2000;
@@ -303,6 +360,13 @@ export default function ExtractInvoiceDataPage() {
   const displayImage = getImageUrl(invoice?.image_path || currentImage);
   const displayData = extractedData || invoiceData;
 
+  // the selected field may be on another page than the first one
+  const activePage = activeBox?.page && activeBox.page > 1
+    ? invoice?.pages?.find((page) => page.number === activeBox.page)
+    : undefined;
+  const highlightImage = activePage ? getImageUrl(activePage.image_path) : displayImage;
+  const focusBox = (box?: BoundingBox | null) => setActiveBox(box ?? null);
+
   return (
     <div className="page-container">
       <header className="page-header safe-top">
@@ -364,7 +428,25 @@ export default function ExtractInvoiceDataPage() {
           </div>
         )}
 
-        {!isLoading && invoice && invoice.status !== 'completed' && invoice.status !== 'failed' && (
+        {invoice?.status === 'needs_review' && invoice.findings && invoice.findings.length > 0 && (
+          <div className="p-4 animate-fade-in">
+            <div className="card bg-warning-50 dark:bg-warning-500/10 border-warning-200 dark:border-warning-500/20">
+              <div className="flex items-start gap-3">
+                <AlertTriangle className="w-5 h-5 text-warning-500 shrink-0 mt-0.5" />
+                <div>
+                  <p className="font-medium text-warning-700 dark:text-warning-400">Cần kiểm tra lại số liệu</p>
+                  <ul className="text-sm text-warning-600 dark:text-warning-400/80 mt-0.5 space-y-0.5">
+                    {invoice.findings.map((finding, index) => (
+                      <li key={index}>{describeFinding(finding)}</li>
+                    ))}
+                  </ul>
+                </div>
+              </div>
+            </div>
+          </div>
+        )}
+
+        {!isLoading && invoice && !isExtracted(invoice.status) && invoice.status !== 'failed' && (
           <div className="flex flex-col items-center justify-center h-full animate-fade-in">
             <div className="w-16 h-16 rounded-2xl bg-primary-100 dark:bg-primary-900/30 flex items-center justify-center mb-4">
               <Loader2 className="w-8 h-8 text-primary-600 dark:text-primary-400 animate-spin" />
@@ -378,7 +460,7 @@ export default function ExtractInvoiceDataPage() {
           </div>
         )}
 
-        {displayData && displayImage && invoice?.status === 'completed' && (
+        {displayData && displayImage && invoice && isExtracted(invoice.status) && (
           <div className="flex flex-col h-full animate-fade-in">
             <div className="border-b border-surface-200 dark:border-surface-800 bg-surface-100 dark:bg-surface-900 p-4">
               <div className="flex items-center gap-2 mb-3">
@@ -386,11 +468,21 @@ export default function ExtractInvoiceDataPage() {
                 <span className="text-xs font-medium text-surface-500 uppercase tracking-wide">Hình ảnh hóa đơn</span>
               </div>
               <div className="rounded-xl overflow-hidden bg-white dark:bg-surface-800 shadow-inner-soft">
-                <img
-                  src={displayImage}
-                  alt="Hóa đơn"
-                  className="w-full h-auto object-contain max-h-52"
-                />
+                {isPdfPath(displayImage) ? (
+                  <a
+                    href={displayImage}
+                    target="_blank"
+                    rel="noreferrer"
+                    className="flex items-center gap-2 p-4 text-sm font-medium text-primary-600 dark:text-primary-400"
+                  >
+                    <FileText className="w-5 h-5" />
+                    <span>
+                      Tài liệu PDF{invoice?.page_count ? ` (${invoice.page_count} trang)` : ''}
+                    </span>
+                  </a>
+                ) : (
+                  <HighlightedImage src={highlightImage || displayImage} box={activeBox} />
+                )}
               </div>
             </div>
 
@@ -413,6 +505,7 @@ export default function ExtractInvoiceDataPage() {
                           <AutoExpandTextarea
                             value={pair.value}
                             onChange={(value) => updateKeyValue(index, pair.key, value)}
+                            onFocus={() => focusBox(pair.bounding_box)}
                           />
                         </div>
                       ))}
@@ -466,7 +559,9 @@ export default function ExtractInvoiceDataPage() {
                             row={row}
                             headers={displayData.table!.headers}
                             rowIndex={rowIndex}
+                            cellBoxes={displayData.table!.cell_boxes?.[rowIndex]}
                             onCellChange={updateTableCell}
+                            onCellFocus={focusBox}
                           />
                         ))}
                       </div>
@@ -495,6 +590,7 @@ export default function ExtractInvoiceDataPage() {
                                         <AutoExpandTextarea
                                           value={cell}
                                           onChange={(value) => updateTableCell(rowIndex, cellIndex, value)}
+                                        onFocus={() => focusBox(displayData.table!.cell_boxes?.[rowIndex]?.[cellIndex])}
                                           className="border-0 bg-transparent p-0 min-h-[24px] text-xs focus:ring-0"
                                         />
                                       </td>
@@ -528,6 +624,7 @@ export default function ExtractInvoiceDataPage() {
                             <AutoExpandTextarea
                               value={item.value}
                               onChange={(value) => updateSummary(index, item.key, value)}
+                            onFocus={() => focusBox(item.bounding_box)}
                               className="text-right font-semibold flex-1 bg-white dark:bg-surface-800"
                             />
                           </div>
@@ -10,7 +10,8 @@ import {
   XCircle,
   Loader2,
   Clock,
-  Trash2
+  Trash2,
+  AlertTriangle
 } from 'lucide-react';
 import { apiClient, getImageUrl } from '@/lib/api';
 import type { InvoiceStatus } from '@/types';
@@ -111,6 +112,12 @@ export default function ListInvoicesPage() {
           text: 'Hoàn thành',
           className: 'badge-success',
         };
+      case 'needs_review':
+        return {
+          icon: <AlertTriangle className="w-4 h-4" />,
+          text: 'Cần kiểm tra',
+          className: 'badge-warning',
+        };
       case 'failed':
         return {
           icon: <XCircle className="w-4 h-4" />,
@@ -129,6 +136,12 @@ export default function ListInvoicesPage() {
           text: 'Chờ xử lý',
           className: 'badge-warning',
         };
+      case 'draft':
+        return {
+          icon: <Clock className="w-4 h-4" />,
+          text: 'Bản nháp',
+          className: 'badge',
+        };
       default:
         return {
           icon: <Clock className="w-4 h-4" />,
@@ -238,11 +251,12 @@ export default function ListInvoicesPage() {
                         onTouchEnd={() => handleTouchEnd(invoice.id, cardRef)}
                       >
                         <div className="w-14 h-14 rounded-xl bg-surface-100 dark:bg-surface-800 overflow-hidden shrink-0 flex items-center justify-center">
-                          {invoice.image_path ? (
+                          {invoice.thumbnail_url ? (
                             <img
                               alt="Ảnh đại diện hóa đơn"
                               className="w-full h-full object-cover"
-                              src={getImageUrl(invoice.image_path) || ''}
+                              loading="lazy"
+                              src={getImageUrl(invoice.thumbnail_url) || ''}
                               onError={(e) => {
                                 const target = e.target as HTMLImageElement;
                                 target.style.display = 'none';
@@ -250,7 +264,7 @@ export default function ListInvoicesPage() {
                               }}
                             />
                           ) : null}
-                          <FileText className={`w-6 h-6 text-surface-400 ${invoice.image_path ? 'hidden' : ''}`} />
+                          <FileText className={`w-6 h-6 text-surface-400 ${invoice.thumbnail_url ? 'hidden' : ''}`} />
                         </div>
 
                         <div className="flex-grow min-w-0">
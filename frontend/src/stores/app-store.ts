@@ -76,6 +76,7 @@ export const useAppStore = create<AppStore>((set, get) => ({
     const table = updated.table;
     if (table) {
       updated.table = {
+        ...table,
         headers: [...table.headers],
         rows: table.rows.map((row, idx) =>
           idx === rowIndex
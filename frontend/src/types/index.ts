@@ -3,11 +3,30 @@ export interface KeyValuePair {
   key: string;
   value: string;
   confidence?: number;
+  provider?: string;
+  disagreements?: Candidate[];
+  bounding_box?: BoundingBox;
+}
+
+// Region of a page a value was read from, as fractions of the page size
+export interface BoundingBox {
+  page?: number;
+  x: number;
+  y: number;
+  width: number;
+  height: number;
+}
+
+export interface Candidate {
+  value: string;
+  confidence?: number;
+  provider: string;
 }
 
 export interface TableData {
   headers: string[];
   rows: string[][];
+  cell_boxes?: (BoundingBox | null)[][];
 }
 
 export interface ExtractedData {
@@ -15,6 +34,29 @@ export interface ExtractedData {
   table: TableData;
   summary: KeyValuePair[];
   confidence?: number;
+  provider?: string;
+  prompt_version?: string;
+  page_count?: number;
+  cache?: CacheInfo;
+  seller_tax_code?: string;
+  buyer_tax_code?: string;
+  usage?: ExtractionUsage[];
+}
+
+export interface CacheInfo {
+  hit: boolean;
+  image_hash: string;
+  cached_at?: string;
+}
+
+export interface ExtractionUsage {
+  provider: string;
+  model?: string;
+  prompt_tokens: number;
+  output_tokens: number;
+  total_tokens: number;
+  latency_ms: number;
+  error?: string;
 }
 
 export interface InvoiceData {
@@ -36,15 +78,75 @@ export interface ExtractResponse {
   processingTime?: number;
 }
 
-export type InvoiceStatus = 'pending' | 'processing' | 'completed' | 'failed';
+export type InvoiceStatus = 'draft' | 'pending' | 'processing' | 'completed' | 'needs_review' | 'failed';
+
+export type FindingCode =
+  | 'line_amount_mismatch'
+  | 'subtotal_mismatch'
+  | 'total_mismatch'
+  | 'invalid_vat_rate'
+  | 'amount_in_words_mismatch';
+
+// An inconsistency between the numbers of an invoice, which flags it for
+// review
+export interface Finding {
+  code: FindingCode;
+  message: string;
+  // line item the finding is about, from 1
+  row?: number;
+  expected?: string;
+  actual?: string;
+}
+
+export interface InvoicePage {
+  number: number;
+  image_path: string;
+  mime_type?: string;
+  original_path?: string;
+  // SHA-256 of the image, equal for pages of identical images
+  image_hash?: string;
+}
+
+export interface LineItem {
+  description?: string;
+  unit?: string;
+  quantity?: string;
+  unit_price?: string;
+  amount?: string;
+}
+
+// Fields normalized from the extracted data; amounts are decimal strings
+// with a dot separator
+export interface InvoiceFields {
+  invoice_number?: string;
+  issue_date?: string;
+  seller_name?: string;
+  seller_tax_code?: string;
+  buyer_name?: string;
+  buyer_tax_code?: string;
+  currency?: string;
+  subtotal?: string;
+  vat_rate?: string;
+  vat?: string;
+  total?: string;
+  amount_in_words?: string;
+  line_items?: LineItem[];
+}
 
 export interface InvoiceListItem {
   id: string;
   status: InvoiceStatus;
+  tenant_id?: string;
   image_path: string;
+  thumbnail_url?: string;
   created_at: string;
   updated_at?: string;
   extracted_data?: ExtractedData;
+  fields?: InvoiceFields;
+  findings?: Finding[];
+  prompt_version?: string;
+  page_count?: number;
+  pages?: InvoicePage[];
   error_message?: string;
 }
 